@@ -0,0 +1,16 @@
+//go:build !unix
+
+package diary
+
+import "os"
+
+// writable fails unless the current user may create files in dir, tried by
+// creating and removing a temporary file.
+func writable(dir string) error {
+	f, err := os.CreateTemp(dir, ".diary-doctor-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
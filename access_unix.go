@@ -0,0 +1,10 @@
+//go:build unix
+
+package diary
+
+import "golang.org/x/sys/unix"
+
+// writable fails unless the current user may create files in dir.
+func writable(dir string) error {
+	return unix.Access(dir, unix.W_OK)
+}
@@ -0,0 +1,45 @@
+package diary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const archiveDir = "archive"
+
+func isArchived(fn string) bool {
+	return strings.HasPrefix(fn, archiveDir+"/")
+}
+
+func (j *Journal) Archive() error {
+	var moves []string
+	err := j.walkNotes(func(n *Note) error {
+		if n.Type == Diary && !j.inDiaryWindow(n.Time) {
+			moves = append(moves, n.Path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, fn := range moves {
+		dst := filepath.Join(archiveDir, fn)
+		fmt.Printf("%s -> %s\n", fn, dst)
+		if j.dryRun {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Join(j.path, filepath.Dir(dst)), os.ModePerm); err != nil {
+			return fmt.Errorf("error create path '%s': %w", filepath.Dir(dst), err)
+		}
+		if err := j.moveFile(fn, dst); err != nil {
+			return err
+		}
+		j.purge(fn)
+	}
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	return j.Write()
+}
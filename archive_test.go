@@ -0,0 +1,47 @@
+package diary
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveMovesOldDiaryNotes(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		"2023/11/2023-11-02.md": "# Note\n- *TODO* old\n",
+		"2024/03/2024-03-01.md": "# Note\n- *TODO* recent\n",
+		"notes/plan.md":         "- *TODO* not a diary note\n",
+	})
+	git.noRepo = true
+	j.NoCommit = true
+	setNow(t, j, "2024-03-10T12:00:00Z")
+	out := captureStdout(t, j.Archive)
+	if out != "2023/11/2023-11-02.md -> archive/2023/11/2023-11-02.md\n" {
+		t.Errorf("output = %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(j.path, "archive/2023/11/2023-11-02.md")); err != nil {
+		t.Errorf("note not archived: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(j.path, "2023/11/2023-11-02.md")); !os.IsNotExist(err) {
+		t.Errorf("note still in place: %v", err)
+	}
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Todos) != 2 || j.Todos["archive/2023/11/2023-11-02.md"] != nil {
+		t.Errorf("walk did not skip the archive: %v", j.Todos)
+	}
+}
+
+func TestArchiveUsesGitMove(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		"2023/11/2023-11-02.md": "# Note\n",
+	})
+	j.NoCommit = true
+	git.tracked = []string{"2023/11/2023-11-02.md"}
+	setNow(t, j, "2024-03-10T12:00:00Z")
+	captureStdout(t, j.Archive)
+	if calls := git.called("mv"); len(calls) != 1 || calls[0] != "mv 2023/11/2023-11-02.md archive/2023/11/2023-11-02.md" {
+		t.Errorf("mv calls = %q", calls)
+	}
+}
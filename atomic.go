@@ -0,0 +1,45 @@
+package diary
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileChanged writes data to fn unless fn already holds exactly data,
+// so an unchanged index keeps its modification time and git sees no change.
+func writeFileChanged(fn string, data []byte) error {
+	if old, err := os.ReadFile(fn); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	return writeFileAtomic(fn, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to fn and renames it
+// over fn, so an interrupted write never leaves fn truncated.
+func writeFileAtomic(fn string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fn), "."+filepath.Base(fn)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), fn); err != nil {
+		return fmt.Errorf("error rename '%s': %w", tmp.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package diary
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "index.md")
+	writeFiles(t, dir, map[string]string{"index.md": "old index, longer than the new one\n"})
+	if err := writeFileAtomic(fn, []byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, fn); got != "new\n" {
+		t.Errorf("content = %q", got)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files left: %v", entries)
+	}
+}
+
+func TestWriteFileChangedKeepsModTime(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "index.md")
+	writeFiles(t, dir, map[string]string{"index.md": "same\n"})
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(fn, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileChanged(fn, []byte("same\n")); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := os.Stat(fn); err != nil || !st.ModTime().Equal(old) {
+		t.Errorf("unchanged file rewritten: %v %v", st.ModTime(), err)
+	}
+}
+
+func TestCorruptConfig(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := `{"Hash": "1111111", "Todos": {"a.md": [{"LineNo": 1,`
+	writeFiles(t, dir, map[string]string{".journal.json": corrupt, "a.md": "- *TODO* a\n"})
+	var j *Journal
+	stderr := captureStderr(t, func() {
+		var err error
+		if j, err = OpenJournal(dir, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(stderr, "warning: invalid config file '.journal.json', saved as '.journal.json.bad' and using defaults") {
+		t.Errorf("stderr:\n%s", stderr)
+	}
+	if got := readFile(t, filepath.Join(dir, ".journal.json.bad")); got != corrupt {
+		t.Errorf("backup = %q", got)
+	}
+	if j.Hash != "" || len(j.Todos) != 0 || j.TagStyle != "asterisk" {
+		t.Errorf("not the defaults: hash %q, todos %v, tag style %q", j.Hash, j.Todos, j.TagStyle)
+	}
+	j.SetGit(&fakeGit{})
+	j.SetNoCommit(true)
+	j.SetLog(&strings.Builder{}, LogQuiet)
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenJournal(dir, ""); err != nil || len(j.Todos["a.md"]) != 1 {
+		t.Errorf("rebuilt journal: %v, todos %v", err, j.Todos)
+	}
+}
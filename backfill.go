@@ -0,0 +1,59 @@
+package diary
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Backfill creates the missing diary notes from start to end, both
+// YYYY-MM-DD and inclusive, from template.md or the built-in scaffold as a
+// new day would get. The entry time is the start of the day. Existing notes
+// are left alone.
+func (j *Journal) Backfill(start, end string) error {
+	from, err := time.ParseInLocation("2006-01-02", start, j.location())
+	if err != nil {
+		return fmt.Errorf("invalid start date '%s', expected YYYY-MM-DD", start)
+	}
+	to, err := time.ParseInLocation("2006-01-02", end, j.location())
+	if err != nil {
+		return fmt.Errorf("invalid end date '%s', expected YYYY-MM-DD", end)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("end date %s is before start date %s", end, start)
+	}
+	tmpl, ok, err := j.diaryTemplate(false)
+	if err != nil {
+		return err
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		fn := j.diaryPath(day)
+		ff := filepath.Join(j.path, fn)
+		if _, err := os.Stat(ff); err == nil {
+			continue
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error stat '%s': %w", fn, err)
+		}
+		fmt.Println(fn)
+		if j.dryRun {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(ff), os.ModePerm); err != nil {
+			return fmt.Errorf("error create path '%s': %w", filepath.Dir(fn), err)
+		}
+		if ok {
+			_, err = j.appendTemplate(ff, day, false, tmpl)
+		} else {
+			_, err = j.appendScaffold(ff, day, false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	return j.Write()
+}
@@ -0,0 +1,45 @@
+package diary
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackfill(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"Timezone": "UTC"}`,
+		"2024/02/2024-02-29.md": "# on paper\n",
+	})
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-05T12:00:00Z")
+	out := captureStdout(t, func() error { return j.Backfill("2024-02-28", "2024-03-01") })
+	if out != "2024/02/2024-02-28.md\n2024/03/2024-03-01.md\n" {
+		t.Errorf("out = %q", out)
+	}
+	if got := readFile(t, filepath.Join(j.path, "2024/02/2024-02-28.md")); got != "# Note 2024-02-28\n\n## 00:00:00\n\n\n" {
+		t.Errorf("2024-02-28 = %q", got)
+	}
+	if got := readFile(t, filepath.Join(j.path, "2024/03/2024-03-01.md")); got != "# Note 2024-03-01\n\n## 00:00:00\n\n\n" {
+		t.Errorf("2024-03-01 = %q", got)
+	}
+	if got := readFile(t, filepath.Join(j.path, "2024/02/2024-02-29.md")); got != "# on paper\n" {
+		t.Errorf("existing note overwritten: %q", got)
+	}
+	if len(j.Diary["2024-02"]) != 2 || len(j.Diary["2024-03"]) != 1 {
+		t.Errorf("diary = %v", j.Diary)
+	}
+}
+
+func TestBackfillInvalidRange(t *testing.T) {
+	j, _, _ := newTestJournal(t, nil)
+	for _, tc := range []struct{ start, end, want string }{
+		{"2024-03-02", "2024-03-01", "end date 2024-03-01 is before start date 2024-03-02"},
+		{"2024-3-1", "2024-03-01", "invalid start date '2024-3-1'"},
+		{"2024-03-01", "tomorrow", "invalid end date 'tomorrow'"},
+	} {
+		if err := j.Backfill(tc.start, tc.end); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Backfill(%s, %s) = %v", tc.start, tc.end, err)
+		}
+	}
+}
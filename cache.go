@@ -0,0 +1,161 @@
+package diary
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const cacheFile = ".journal.cache.json"
+
+type cacheEntry struct {
+	Key   string
+	Tags  []Tag
+	Meta  map[string]string `json:",omitempty"`
+	Links []string          `json:",omitempty"`
+	// Unordered are the time header warnings, raised again on a hit
+	Unordered []string `json:",omitempty"`
+}
+
+type tagCache struct {
+	Signature string
+	Files     map[string]cacheEntry
+	dirty     bool
+	blobs     map[string]string
+	mu        sync.Mutex
+}
+
+// cacheVersion is part of the signature and is bumped whenever parsing
+// changes what ends up in a Tag.
+const cacheVersion = 13
+
+func (j *Journal) parseSignature() string {
+	return fmt.Sprintf("%d|%s|%s|%s|%s|%t|%v|%s|%s", cacheVersion, j.TagStyle, strings.Join(j.Priorities, ","), strings.Join(j.CustomTags, ","), j.Timezone, j.MultilineTags, j.LinkFormats, j.TimeFormat, j.diaryLayout())
+}
+
+func (j *Journal) tagCache() *tagCache {
+	if j.cache != nil {
+		return j.cache
+	}
+	c := &tagCache{Signature: j.parseSignature(), Files: make(map[string]cacheEntry)}
+	data, err := ioutil.ReadFile(filepath.Join(j.path, cacheFile))
+	if err == nil {
+		var stored tagCache
+		if json.Unmarshal(data, &stored) == nil && stored.Signature == c.Signature && stored.Files != nil {
+			c.Files = stored.Files
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		j.warnf("error read cache file: %v", err)
+	}
+	c.blobs = j.blobHashes()
+	j.cache = c
+	return c
+}
+
+func (j *Journal) blobHashes() map[string]string {
+	blobs := make(map[string]string)
+	if !j.hasRepo() {
+		return blobs
+	}
+	out, err := j.git.LsFiles("-s")
+	if err != nil {
+		return blobs
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		meta := strings.Fields(fields[0])
+		if len(meta) == 3 {
+			blobs[fields[1]] = meta[1]
+		}
+	}
+	out, err = j.git.LsFiles("-m")
+	if err != nil {
+		return make(map[string]string)
+	}
+	for _, fn := range strings.Split(out, "\n") {
+		delete(blobs, fn)
+	}
+	return blobs
+}
+
+// cacheKey is the key the tags of fn are cached under, empty when fn is not
+// cached. Encrypted notes never are, the cache file being plain text.
+func (j *Journal) cacheKey(fn string) string {
+	if strings.HasSuffix(fn, ageExt) {
+		return ""
+	}
+	c := j.tagCache()
+	if blob, ok := c.blobs[fn]; ok {
+		return "blob:" + blob
+	}
+	st, err := os.Stat(filepath.Join(j.path, fn))
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("mtime:%d:%d", st.ModTime().UnixNano(), st.Size())
+}
+
+func (j *Journal) cachedTags(n *Note) ([]Tag, string, bool) {
+	key := j.cacheKey(n.Path)
+	if key == "" {
+		return nil, "", false
+	}
+	c := j.tagCache()
+	c.mu.Lock()
+	e, ok := c.Files[n.Path]
+	c.mu.Unlock()
+	if !ok || e.Key != key {
+		return nil, key, false
+	}
+	n.Meta = e.Meta
+	n.Links = e.Links
+	n.unordered = e.Unordered
+	tags := make([]Tag, len(e.Tags))
+	for i, t := range e.Tags {
+		t.note = n
+		tags[i] = t
+	}
+	return tags, key, true
+}
+
+func (j *Journal) storeTags(n *Note, key string, tags []Tag) {
+	if key == "" {
+		return
+	}
+	c := j.tagCache()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Files[n.Path] = cacheEntry{Key: key, Tags: tags, Meta: n.Meta, Links: n.Links, Unordered: n.unordered}
+	c.dirty = true
+}
+
+func (j *Journal) forgetTags(fn string) {
+	c := j.tagCache()
+	if _, ok := c.Files[fn]; ok {
+		delete(c.Files, fn)
+		c.dirty = true
+	}
+}
+
+func (j *Journal) saveCache() error {
+	if j.cache == nil || !j.cache.dirty || j.dryRun {
+		return nil
+	}
+	data, err := json.Marshal(j.cache)
+	if err != nil {
+		return fmt.Errorf("error marshal cache: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(j.path, cacheFile), data); err != nil {
+		return fmt.Errorf("error write cache file: %w", err)
+	}
+	j.cache.dirty = false
+	return nil
+}
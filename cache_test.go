@@ -0,0 +1,202 @@
+package diary
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// reopen opens the journal of j again, as the next run would.
+func reopen(t testing.TB, j *Journal) (*Journal, *bytes.Buffer) {
+	t.Helper()
+	if err := j.saveCache(); err != nil {
+		t.Fatal(err)
+	}
+	next, err := OpenJournal(j.path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next.SetGit(&fakeGit{})
+	var log bytes.Buffer
+	next.SetLog(&log, LogVerbose)
+	return next, &log
+}
+
+func TestCachedNoteIsNotReread(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"a.md": "- *TODO* cached\n",
+		"b.md": "- *TODO* changed\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(j.path, cacheFile)); err != nil {
+		t.Fatalf("cache not written: %v", err)
+	}
+	writeFiles(t, j.path, map[string]string{"b.md": "- *TODO* changed again\n"})
+	next, log := reopen(t, j)
+	if err := next.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(log.String(), "cached a.md\n") || strings.Contains(log.String(), "parse a.md\n") {
+		t.Errorf("a.md re-read:\n%s", log)
+	}
+	if !strings.Contains(log.String(), "parse b.md\n") {
+		t.Errorf("modified b.md not re-read:\n%s", log)
+	}
+	if tags := next.Todos["b.md"]; len(tags) != 1 || !strings.HasSuffix(tags[0].Text, "changed again") {
+		t.Errorf("b.md tags = %v", tags)
+	}
+	if tags := next.Todos["a.md"]; len(tags) != 1 || tags[0].Path() != "a.md" {
+		t.Errorf("cached tags = %v", tags)
+	}
+}
+
+func TestCacheKeyedByBlobHash(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{"a.md": "- *TODO* a\n"})
+	git.staged = "100644 0123abcd 0\ta.md\n"
+	if key := j.cacheKey("a.md"); key != "blob:0123abcd" {
+		t.Errorf("key = %q", key)
+	}
+	j.cache = nil
+	git.modified = "a.md\n"
+	if key := j.cacheKey("a.md"); !strings.HasPrefix(key, "mtime:") {
+		t.Errorf("key of modified note = %q", key)
+	}
+}
+
+func TestCacheDroppedOnParseSignatureChange(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{"a.md": "- #todo a\n"})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, j.path, map[string]string{".journal.json": `{"TagStyle": "hashtag"}`})
+	next, log := reopen(t, j)
+	if err := next.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(log.String(), "cached a.md") || len(next.Todos["a.md"]) != 1 {
+		t.Errorf("stale cache used after TagStyle change:\n%s", log)
+	}
+}
+
+func TestWarmCacheKeepsHeaderOrderChecks(t *testing.T) {
+	j, _, log := newTestJournal(t, map[string]string{"2024/01/2024-01-05.md": outOfOrderNote})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(log.String(), "is earlier than previous") {
+		t.Fatalf("no warning on a cold cache: %q", log)
+	}
+	next, log := reopen(t, j)
+	if err := next.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(log.String(), "cached 2024/01/2024-01-05.md") || !strings.Contains(log.String(), "is earlier than previous") {
+		t.Errorf("no warning on a warm cache:\n%s", log)
+	}
+	strict, _ := reopen(t, next)
+	strict.SetStrict(true)
+	if err := strict.ProcessAll(); err == nil || !strings.Contains(err.Error(), "is earlier than previous") {
+		t.Errorf("strict passed on a warm cache: %v", err)
+	}
+}
+
+func benchmarkJournal(b testing.TB, notes int) *Journal {
+	b.Helper()
+	dir := b.TempDir()
+	for i := 0; i < notes; i++ {
+		var note strings.Builder
+		note.WriteString("# Note\n")
+		for h := 0; h < 10; h++ {
+			fmt.Fprintf(&note, "\n## %02d:00:00\n- *TODO* task %d of note %d with a [link](notes/other.md)\n- plain line\n", h+8, h, i)
+		}
+		fn := filepath.Join(dir, fmt.Sprintf("2024/%02d/2024-%02d-%02d.md", i%12+1, i%12+1, i%28+1))
+		if i >= 12*28 {
+			fn = filepath.Join(dir, fmt.Sprintf("notes/note-%d.md", i))
+		}
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(note.String()), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	j, err := OpenJournal(dir, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	j.SetGit(&fakeGit{})
+	j.SetLog(&bytes.Buffer{}, LogQuiet)
+	return j
+}
+
+func BenchmarkProcessAllColdCache(b *testing.B) {
+	j := benchmarkJournal(b, 200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		coldProcessAll(b, j)
+	}
+}
+
+func BenchmarkProcessAllWarmCache(b *testing.B) {
+	j := benchmarkJournal(b, 200)
+	if err := j.ProcessAll(); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := j.ProcessAll(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// coldProcessAll processes every note with an empty cache.
+func coldProcessAll(tb testing.TB, j *Journal) {
+	tb.Helper()
+	j.cache = &tagCache{Signature: j.parseSignature(), Files: make(map[string]cacheEntry), blobs: map[string]string{}}
+	if err := j.ProcessAll(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestProcessAllSameForAnyWorkerCount(t *testing.T) {
+	j := benchmarkJournal(t, 400)
+	state := func(workers int) string {
+		j.Workers = workers
+		coldProcessAll(t, j)
+		data, err := json.Marshal([]interface{}{j.Doings, j.Todos, j.Laters, j.Waitings, j.Done, j.Custom, j.Meta, j.Links, j.Diary})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var index bytes.Buffer
+		if err := j.RenderIndex(&index); err != nil {
+			t.Fatal(err)
+		}
+		return string(data) + index.String()
+	}
+	want := state(1)
+	for _, workers := range []int{2, 8, 32} {
+		if got := state(workers); got != want {
+			t.Errorf("%d workers differ from one", workers)
+		}
+	}
+}
+
+func BenchmarkProcessAllWorkers(b *testing.B) {
+	for _, workers := range []int{1, 2, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			j := benchmarkJournal(b, 400)
+			j.Workers = workers
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				coldProcessAll(b, j)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package diary
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultInbox = "inbox.md"
+
+// Capture appends text to the Inbox note, inbox.md by default, under a time
+// header for the current time, and rebuilds the index. The header is only
+// added when the last one in the inbox is for another time.
+func (j *Journal) Capture(text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fmt.Errorf("nothing to capture")
+	}
+	inbox := j.Inbox
+	if inbox == "" {
+		inbox = defaultInbox
+	}
+	if err := j.appendEntry(j.resolve(inbox), inbox, text); err != nil || j.dryRun {
+		return err
+	}
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	return j.Write()
+}
+
+// Append adds text to the diary note of today as Capture does to the inbox,
+// creating the note from the template, or with just the title, when it is
+// missing. An encrypted note is appended to through a plain copy.
+func (j *Journal) Append(text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fmt.Errorf("nothing to append")
+	}
+	now := j.now()
+	fn := j.diaryPath(now)
+	ff := filepath.Join(j.path, fn)
+	if !j.dryRun {
+		if err := os.MkdirAll(filepath.Dir(ff), os.ModePerm); err != nil {
+			return fmt.Errorf("error create path '%s': %w", filepath.Dir(fn), err)
+		}
+	}
+	encrypted, err := j.encryptedDiary(ff)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		err = j.editPlain(ff+ageExt, func(ff string) error {
+			return j.appendDiary(ff, fn, text)
+		})
+	} else {
+		err = j.appendDiary(ff, fn, text)
+	}
+	if err != nil || j.dryRun {
+		return err
+	}
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	return j.Write()
+}
+
+// appendDiary creates the diary note ff, named fn in errors, when it is
+// missing and adds text to it.
+func (j *Journal) appendDiary(ff, fn, text string) error {
+	if _, err := os.Stat(ff); errors.Is(err, os.ErrNotExist) && !j.dryRun {
+		tmpl, ok, err := j.diaryTemplate(false)
+		if err != nil {
+			return err
+		}
+		if ok {
+			_, err = j.appendTemplate(ff, j.now(), false, tmpl)
+		} else if err = ioutil.WriteFile(ff, []byte(fmt.Sprintf("# %s\n", j.title(j.now()))), 0644); err != nil {
+			err = fmt.Errorf("error write file '%s': %w", fn, err)
+		}
+		if err != nil {
+			return err
+		}
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error stat '%s': %w", fn, err)
+	}
+	return j.appendEntry(ff, fn, text)
+}
+
+// appendEntry adds text as a list item to the note ff, named fn in errors,
+// below a time header for now unless the last header already is for now.
+// In dry-run mode the addition is printed instead.
+func (j *Journal) appendEntry(ff, fn, text string) error {
+	data, err := ioutil.ReadFile(ff)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error read file '%s': %w", fn, err)
+	}
+	last := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		if ms := mdTimePattern.FindStringSubmatch(strings.TrimSuffix(line, "\r")); ms != nil {
+			last = j.headerTime(ms[1], j.now())
+		}
+	}
+	var sb strings.Builder
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		sb.WriteString("\n")
+	}
+	if now := j.now().Format(j.timeLayout()); last != now {
+		if len(data) > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "## %s\n\n", now)
+	}
+	if !listItemPattern.MatchString(text + " ") {
+		sb.WriteString("- ")
+	}
+	sb.WriteString(text + "\n")
+	if j.dryRun {
+		fmt.Print(sb.String())
+		return nil
+	}
+	if err := ioutil.WriteFile(ff, append(data, sb.String()...), 0644); err != nil {
+		return fmt.Errorf("error write file '%s': %w", fn, err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package diary
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{".journal.json": `{"Timezone": "UTC"}`})
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-04T09:30:00Z")
+	if err := j.Capture("buy milk *TODO*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Capture("- call mom *TODO*"); err != nil {
+		t.Fatal(err)
+	}
+	setNow(t, j, "2024-03-04T10:00:00Z")
+	if err := j.Capture("  "); err == nil {
+		t.Error("empty capture succeeded")
+	}
+	if err := j.Capture("an idea"); err != nil {
+		t.Fatal(err)
+	}
+	want := "## 09:30:00\n\n- buy milk *TODO*\n- call mom *TODO*\n\n## 10:00:00\n\n- an idea\n"
+	if got := readFile(t, filepath.Join(j.path, "inbox.md")); got != want {
+		t.Errorf("inbox:\n%s\nwant:\n%s", got, want)
+	}
+	if todos := j.Todos["inbox.md"]; len(todos) != 2 || todos[0].Time.Format("15:04") != "09:30" {
+		t.Errorf("todos = %v", todos)
+	}
+	index := readFile(t, filepath.Join(j.path, "index.md"))
+	if !strings.Contains(index, "- buy milk *[TODO](inbox.md#09:30:00)*") || !strings.Contains(index, "- call mom *[TODO](inbox.md#09:30:00)*") {
+		t.Errorf("index:\n%s", index)
+	}
+}
+
+func TestCaptureToConfiguredInbox(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"Timezone": "UTC", "Inbox": "notes/in.md"}`,
+		"notes/in.md":   "# Inbox\n\n## 09:30:00\n- old *TODO*",
+	})
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-04T09:30:00Z")
+	if err := j.Capture("new *TODO*"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readFile(t, filepath.Join(j.path, "notes/in.md")), "# Inbox\n\n## 09:30:00\n- old *TODO*\n- new *TODO*\n"; got != want {
+		t.Errorf("inbox:\n%q\nwant:\n%q", got, want)
+	}
+	if len(j.Todos["notes/in.md"]) != 2 {
+		t.Errorf("todos = %v", j.Todos)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{".journal.json": `{"Timezone": "UTC"}`})
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-04T09:30:00Z")
+	if err := j.Append("first *TODO*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append("- second"); err != nil {
+		t.Fatal(err)
+	}
+	setNow(t, j, "2024-03-04T09:30:01Z")
+	if err := j.Append("later *DOING*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append(" \n"); err == nil {
+		t.Error("empty append succeeded")
+	}
+	want := "# Note 2024-03-04\n\n## 09:30:00\n\n- first *TODO*\n- second\n\n## 09:30:01\n\n- later *DOING*\n"
+	if got := readFile(t, filepath.Join(j.path, "2024/03/2024-03-04.md")); got != want {
+		t.Errorf("diary:\n%q\nwant:\n%q", got, want)
+	}
+	fn := "2024/03/2024-03-04.md"
+	if todos, doings := j.Todos[fn], j.Doings[fn]; len(todos) != 1 || len(doings) != 1 || doings[0].Time.Format("15:04:05") != "09:30:01" {
+		t.Errorf("todos %v, doings %v", todos, doings)
+	}
+	index := readFile(t, filepath.Join(j.path, "index.md"))
+	if !strings.Contains(index, "- later *[DOING](2024/03/2024-03-04.md#09:30:01)*") {
+		t.Errorf("index:\n%s", index)
+	}
+}
+
+func TestAppendAfterEditedHeader(t *testing.T) {
+	// an entry at the same time as the last header written by hand joins it
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"Timezone": "UTC"}`,
+		"2024/03/2024-03-04.md": "# Today\n\n## 08:00:00\n- morning\n\n## 09:30:00\n- by hand",
+	})
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-04T09:30:00Z")
+	if err := j.Append("appended"); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Today\n\n## 08:00:00\n- morning\n\n## 09:30:00\n- by hand\n- appended\n"
+	if got := readFile(t, filepath.Join(j.path, "2024/03/2024-03-04.md")); got != want {
+		t.Errorf("diary:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestAppendEncrypted(t *testing.T) {
+	identity, recipient := fakeAge(t)
+	j, _, _ := newTestJournal(t, map[string]string{".journal.json": ageConfig(identity, recipient, `, "EncryptDiary": true`)})
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-04T09:30:00Z")
+	if err := j.Append("secret *TODO*"); err != nil {
+		t.Fatal(err)
+	}
+	setNow(t, j, "2024-03-04T10:00:00Z")
+	if err := j.Append("more"); err != nil {
+		t.Fatal(err)
+	}
+	fn := "2024/03/2024-03-04.md"
+	if _, err := os.Stat(filepath.Join(j.path, fn)); !os.IsNotExist(err) {
+		t.Errorf("plain note written: %v", err)
+	}
+	want := "# Note 2024-03-04\n\n## 09:30:00\n\n- secret *TODO*\n\n## 10:00:00\n\n- more\n"
+	if got := decrypted(t, j, fn+ageExt); got != want {
+		t.Errorf("diary:\n%q\nwant:\n%q", got, want)
+	}
+	if todos := j.Todos[fn+ageExt]; len(todos) != 1 {
+		t.Errorf("todos = %v", j.Todos)
+	}
+}
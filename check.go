@@ -0,0 +1,126 @@
+package diary
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var headerDatePattern = regexp.MustCompile(`\d\d\d\d-\d\d-\d\d`)
+
+// Check verifies that no note has merge conflict markers, that diary notes
+// are at the DiaryLayout path of their date and, with headers set, that the
+// first header names the same date. Each mismatch is printed with the
+// expected value. Stored tags whose line no longer has the tag are reported too, and
+// with prune set dropped by reprocessing their notes.
+func (j *Journal) Check(headers, prune bool) error {
+	problems := 0
+	err := j.walkFiles(true, false, func(n *Note) error {
+		data, err := n.read()
+		if err != nil {
+			return err
+		}
+		if lines := conflictLines(data); len(lines) > 0 {
+			fmt.Println(conflictError(n.Path, lines))
+			problems++
+		}
+		fn := filepath.ToSlash(strings.TrimSuffix(strings.TrimPrefix(n.Path, archiveDir+"/"), ageExt))
+		day, ok := j.diaryDate(fn)
+		if !ok {
+			if !diaryNamePattern.MatchString(filepath.Base(fn)) {
+				return nil
+			}
+			day, err = time.ParseInLocation("2006-01-02", strings.TrimSuffix(filepath.Base(fn), ".md"), j.location())
+			if err != nil {
+				return nil
+			}
+			expected := j.diaryPath(day)
+			if isArchived(n.Path) {
+				expected = archiveDir + "/" + expected
+			}
+			if n.Encrypted() {
+				expected += ageExt
+			}
+			fmt.Printf("%s: path does not match the date, expected %s\n", n.Path, expected)
+			problems++
+			return nil
+		}
+		name := day.Format("2006-01-02")
+		if !headers {
+			return nil
+		}
+		date, err := n.headerDate()
+		if err != nil {
+			return err
+		}
+		if date != "" && date != name {
+			fmt.Printf("%s: header date %s does not match the file, expected %s\n", n.Path, date, name)
+			problems++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	orphans, count, err := j.orphanTags()
+	if err != nil {
+		return err
+	}
+	if len(orphans) > 0 && prune {
+		if err := j.pruneOrphans(orphans); err != nil {
+			return err
+		}
+	} else {
+		problems += count
+	}
+	if problems > 0 {
+		return fmt.Errorf("check found %d problem(s)", problems)
+	}
+	return nil
+}
+
+// titlePattern matches a title rendered from TitleFormat, capturing the
+// date when the format has one.
+func (j *Journal) titlePattern() *regexp.Regexp {
+	format := j.TitleFormat
+	if format == "" {
+		format = defaultTitleFormat
+	}
+	return regexp.MustCompile("^" + strings.NewReplacer(
+		`\{\{date\}\}`, `(\d\d\d\d-\d\d-\d\d)`,
+		`\{\{time\}\}`, `\d\d:\d\d(?::\d\d)?`,
+		`\{\{weekday\}\}`, `\pL+`,
+		`\{\{isoweek\}\}`, `\d\d\d\d-W\d\d`,
+	).Replace(regexp.QuoteMeta(format)) + "$")
+}
+
+// headerDate returns the date in the first header of the note, if any: the
+// date of the TitleFormat when the header follows it, otherwise the first
+// date in the header.
+func (n *Note) headerDate() (string, error) {
+	title := n.journal.titlePattern()
+	data, err := n.read()
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if ms := headerPattern.FindStringSubmatch(scanner.Text()); ms != nil {
+			if ts := title.FindStringSubmatch(strings.TrimSpace(ms[1])); ts != nil {
+				if len(ts) > 1 {
+					return ts[1], nil
+				}
+				return "", nil
+			}
+			return headerDatePattern.FindString(ms[1]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error read file '%s': %w", n.Path, err)
+	}
+	return "", nil
+}
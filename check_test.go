@@ -0,0 +1,95 @@
+package diary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckDiaryPaths(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/03/2024-03-01.md":         "# Note 2024-03-01\n",
+		"2024/04/2024-03-02.md":         "# Note 2024-03-02\n",
+		"2023/03/2024-03-03.md":         "# Note 2024-03-03\n",
+		"2024/03/2024-03-04.md":         "# Note 2024-03-05\n",
+		"2024/03/2024-03-06.md":         "# Standup on 2024-03-07\n",
+		"2024/03/2024-03-08.md":         "no header\n",
+		"archive/2023/01/2023-02-01.md": "# Note 2023-02-01\n",
+		"notes/plan.md":                 "# Plan for 2020-01-01\n",
+	})
+	var err error
+	out := captureStdout(t, func() error {
+		err = j.Check(true, false)
+		return nil
+	})
+	want := []string{
+		"2023/03/2024-03-03.md: path does not match the date, expected 2024/03/2024-03-03.md",
+		"2024/03/2024-03-04.md: header date 2024-03-05 does not match the file, expected 2024-03-04",
+		"2024/03/2024-03-06.md: header date 2024-03-07 does not match the file, expected 2024-03-06",
+		"2024/04/2024-03-02.md: path does not match the date, expected 2024/03/2024-03-02.md",
+		"archive/2023/01/2023-02-01.md: path does not match the date, expected archive/2023/02/2023-02-01.md",
+	}
+	if got := strings.Split(strings.TrimSpace(out), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("check:\n%s\nwant:\n%s", out, strings.Join(want, "\n"))
+	}
+	if err == nil || err.Error() != "check found 5 problem(s)" {
+		t.Errorf("err = %v", err)
+	}
+
+	out = captureStdout(t, func() error {
+		err = j.Check(false, false)
+		return nil
+	})
+	if strings.Contains(out, "header date") || err == nil || err.Error() != "check found 3 problem(s)" {
+		t.Errorf("check without headers: %v\n%s", err, out)
+	}
+}
+
+func TestCheckClean(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/03/2024-03-01.md": "# Note 2024-03-01\n- *TODO* a\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if out := captureStdout(t, func() error { return j.Check(true, false) }); out != "" {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestCheckCustomTitleFormat(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"Timezone": "UTC", "TitleFormat": "{{weekday}} {{isoweek}} {{date}}"}`,
+		"2024/03/2024-03-04.md": "# Monday 2024-W10 2024-03-04\n",
+		"2024/03/2024-03-05.md": "# Tuesday 2024-W10 2024-03-06\n",
+		"2024/03/2024-03-06.md": "# Wednesday, notes from 2024-03-01\n",
+	})
+	var err error
+	out := captureStdout(t, func() error {
+		err = j.Check(true, false)
+		return nil
+	})
+	want := "2024/03/2024-03-05.md: header date 2024-03-06 does not match the file, expected 2024-03-05\n" +
+		"2024/03/2024-03-06.md: header date 2024-03-01 does not match the file, expected 2024-03-06\n"
+	if out != want || err == nil {
+		t.Errorf("check: %v\n%s\nwant:\n%s", err, out, want)
+	}
+}
+
+func TestTitleFormatWithoutDate(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"Timezone": "UTC", "TitleFormat": "Week {{isoweek}}"}`,
+		"2024/03/2024-03-04.md": "# Week 2024-W10\n",
+	})
+	j.SetNoEdit(true)
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-05T09:00:00Z")
+	if err := j.CreateDiary(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, j.resolve("2024/03/2024-03-05.md")); !strings.HasPrefix(got, "# Week 2024-W10\n") {
+		t.Errorf("new day = %q", got)
+	}
+	if out := captureStdout(t, func() error { return j.Check(true, false) }); out != "" {
+		t.Errorf("check = %q", out)
+	}
+}
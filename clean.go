@@ -0,0 +1,25 @@
+package diary
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Clean drops every tracked path that is no longer on disk and rewrites the
+// config and index.
+func (j *Journal) Clean() error {
+	for _, fn := range j.knownPaths() {
+		_, err := os.Stat(filepath.Join(j.path, fn))
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error stat '%s': %w", fn, err)
+		}
+		fmt.Printf("removed %s\n", fn)
+		j.purge(fn)
+	}
+	return j.Write()
+}
@@ -0,0 +1,36 @@
+package diary
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCleanPrunesBogusPaths(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{
+			"Todos": {"bogus.md": [{"LineNo": 1, "Tag": "TODO", "Text": "- *TODO* gone"}]},
+			"Meta": {"old/meta.md": {"title": "x"}},
+			"Links": {"old/links.md": ["a.md"]},
+			"Diary": {"2024-01": [["05", "2024/01/2024-01-05.md"]]}
+		}`,
+		"a.md": "- *TODO* real\n",
+	})
+	j.Todos["a.md"] = []Tag{{LineNo: 1, Tag: "TODO", Text: "- *TODO* real"}}
+	out := captureStdout(t, j.Clean)
+	for _, fn := range []string{"bogus.md", "old/meta.md", "old/links.md", "2024/01/2024-01-05.md"} {
+		if !strings.Contains(out, "removed "+fn+"\n") {
+			t.Errorf("%s not reported in %q", fn, out)
+		}
+	}
+	if got := j.knownPaths(); len(got) != 1 || got[0] != "a.md" {
+		t.Errorf("known paths = %v", got)
+	}
+	data, err := os.ReadFile(j.configPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "bogus.md") || !strings.Contains(string(data), "a.md") {
+		t.Errorf("config not rewritten:\n%s", data)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/senomas/diary"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func journalDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("DIARY_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error resolve home dir: %w", err)
+	}
+	return filepath.Join(home, "journal"), nil
+}
+
+func run() (err error) {
+	dirFlag := flag.String("dir", "", "journal directory (default $DIARY_HOME or $HOME/journal)")
+	monthsFlag := flag.Int("months", 0, "diary recency window in months for this run")
+	maxDepth := flag.Int("max-depth", 0, "walk at most N directories deep for this run, 0 uses MaxDepth from the config")
+	dryRun := false
+	flag.BoolVar(&dryRun, "n", false, "render the index to stdout without writing or committing")
+	flag.BoolVar(&dryRun, "dry-run", false, "render the index to stdout without writing or committing")
+	noCommit := flag.Bool("no-commit", false, "write the index without committing it")
+	noEdit := flag.Bool("no-edit", false, "create the day note without starting the editor, implied when stdin is not a terminal")
+	strict := flag.Bool("strict", false, "fail on time headers that go backwards")
+	since := flag.String("since", "", "only diary notes dated on or after YYYY-MM-DD")
+	until := flag.String("until", "", "only diary notes dated on or before YYYY-MM-DD")
+	rangeNotes := flag.Bool("notes", false, "include non-diary notes when -since or -until is given")
+	diaryOnly := flag.Bool("diary-only", false, "reports and lists cover dated diary notes only")
+	allNotes := flag.Bool("all-notes", false, "reports and lists cover every note, the default")
+	verbose := flag.Bool("v", false, "log git commands, parsed notes and written counts")
+	quiet := flag.Bool("q", false, "log nothing but errors")
+	jsonOut := flag.Bool("json", false, "print report results as JSON")
+	plain := flag.Bool("plain", false, "tab separated report output without color or header")
+	configFlag := flag.String("config", os.Getenv("DIARY_CONFIG"), "config file name, relative to the journal directory (default $DIARY_CONFIG or .journal.json)")
+	indexFlag := flag.String("index", os.Getenv("DIARY_INDEX"), "index file name, relative to the journal directory (default $DIARY_INDEX or index.md)")
+	flag.Parse()
+	args := flag.Args()
+
+	// the level is set before the journal is opened, for the warnings of
+	// loading its config
+	switch {
+	case *verbose && *quiet:
+		return fmt.Errorf("-v and -q are mutually exclusive")
+	case *verbose:
+		diary.SetDefaultLog(os.Stderr, diary.LogVerbose)
+	case *quiet:
+		diary.SetDefaultLog(os.Stderr, diary.LogQuiet)
+	}
+	dir, err := journalDir(*dirFlag)
+	if err != nil {
+		return err
+	}
+	dir, err = diary.ExpandPath(dir)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 && args[0] == "doctor" {
+		return diary.Doctor(dir, *configFlag, *plain)
+	}
+	if len(args) == 0 || (args[0] != "new" && args[0] != "init") {
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("journal directory '%s' does not exist", dir)
+		} else if err != nil {
+			return fmt.Errorf("error open journal directory '%s': %w", dir, err)
+		}
+	}
+	open := diary.OpenJournal
+	if locked(args) {
+		open = diary.OpenLockedJournal
+	}
+	journal, err := open(dir, *configFlag)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := journal.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if *diaryOnly && *allNotes {
+		return fmt.Errorf("-diary-only and -all-notes are mutually exclusive")
+	}
+	journal.SetDiaryOnly(*diaryOnly)
+	journal.SetDiaryMonths(*monthsFlag)
+	journal.SetMaxDepth(*maxDepth)
+	journal.SetDryRun(dryRun)
+	journal.SetStrict(*strict)
+	journal.SetNoCommit(*noCommit)
+	journal.SetNoEdit(*noEdit)
+	journal.SetPlain(*plain)
+	journal.SetJSON(*jsonOut)
+	if err := journal.SetRange(*since, *until, *rangeNotes); err != nil {
+		return err
+	}
+	journal.SetIndex(*indexFlag)
+
+	// commit stays local so it works offline, even with AutoPush set
+	if len(args) == 0 || (args[0] != "today" && args[0] != "commit") {
+		if err := journal.StartAutoPush(); err != nil {
+			return err
+		}
+	}
+	return dispatch(journal, args)
+}
+
+var mutating = map[string]bool{"init": true, "index": true, "new": true, "push": true, "commit": true, "all": true, "archive": true, "clean": true, "undo": true, "move": true, "report": true, "encrypt": true, "backfill": true, "gc": true, "capture": true, "append": true, "fix": true, "tag": true}
+
+// writeFlags are the flags that make an otherwise read-only command write
+// files, and so take the lock.
+var writeFlags = map[string]string{"fmt": "w", "check": "prune"}
+
+// locked reports whether the command of args writes to the journal and must
+// hold the lock.
+func locked(args []string) bool {
+	if len(args) == 0 || mutating[args[0]] {
+		return true
+	}
+	name, ok := writeFlags[args[0]]
+	if !ok {
+		return false
+	}
+	for _, arg := range args[1:] {
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if arg == name {
+			return true
+		}
+		if v, ok := strings.CutPrefix(arg, name+"="); ok {
+			set, err := strconv.ParseBool(v)
+			return set || err != nil
+		}
+	}
+	return false
+}
+
+func dispatch(journal *diary.Journal, args []string) error {
+	if len(args) == 0 {
+		if err := journal.ProcessChanges(); err != nil {
+			return err
+		}
+		return journal.Write()
+	}
+	switch args[0] {
+	case "init":
+		return journal.Init()
+	case "index":
+		return journal.OpenIndex()
+	case "new":
+		return journal.CreateDiary()
+	case "today":
+		return journal.OpenToday()
+	case "commit":
+		return journal.Commit()
+	case "push":
+		fs := flag.NewFlagSet("push", flag.ExitOnError)
+		noRebase := fs.Bool("no-rebase", false, "merge upstream changes with plain git pull")
+		fs.Parse(args[1:])
+		journal.SetNoRebase(*noRebase)
+		return journal.Push()
+	case "all":
+		if err := journal.ProcessAll(); err != nil {
+			return err
+		}
+		return journal.Write()
+	case "watch":
+		return journal.Watch()
+	case "list":
+		fs := flag.NewFlagSet("list", flag.ExitOnError)
+		project := fs.String("project", "", "only tags with this +project")
+		context := fs.String("context", "", "only tags with this @context")
+		kind := fs.String("type", "", "only tags of this type, DONE included")
+		since := fs.String("since", "", "only tags dated on or after YYYY-MM-DD")
+		until := fs.String("until", "", "only tags dated on or before YYYY-MM-DD")
+		sortBy := fs.String("sort", "path", "sort by path, time, priority or due")
+		fs.Parse(args[1:])
+		return journal.PrintList(diary.ListFilter{Type: *kind, Project: *project, Context: *context, Since: *since, Until: *until, Sort: *sortBy})
+	case "snoozed":
+		return journal.PrintSnoozed()
+	case "overdue":
+		return journal.PrintOverdue()
+	case "stale":
+		return journal.PrintStale()
+	case "remind":
+		return journal.Remind()
+	case "tags":
+		fs := flag.NewFlagSet("tags", flag.ExitOnError)
+		since := fs.Int("since", 0, "only include the last N weeks")
+		fs.Parse(args[1:])
+		return journal.PrintWeeklyTags(*since)
+	case "stats":
+		if len(args) > 1 && args[1] == "streak" {
+			return journal.PrintStreaks()
+		}
+		return journal.PrintStats()
+	case "leadtime":
+		return journal.PrintLeadTime()
+	case "hours":
+		return journal.PrintHours()
+	case "search":
+		fs := flag.NewFlagSet("search", flag.ExitOnError)
+		ignoreCase := fs.Bool("i", false, "case-insensitive match")
+		archive := fs.Bool("archive", false, "include archived notes")
+		porcelain := fs.Bool("porcelain", false, "print file:line:time:text records")
+		null := fs.Bool("0", false, "like -porcelain with records ended by a NUL byte")
+		limit := fs.Int("limit", 0, "print at most N lines, all when 0")
+		offset := fs.Int("offset", 0, "skip the first N lines, newest first")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: search [-i] [-archive] [-porcelain|-0] [-offset N] [-limit N] <query>")
+		}
+		return journal.PrintSearch(fs.Arg(0), *ignoreCase, *archive, *porcelain, *null, *offset, *limit)
+	case "feed":
+		fs := flag.NewFlagSet("feed", flag.ExitOnError)
+		limit := fs.Int("limit", 0, "maximum number of entries")
+		output := fs.String("o", "", "write the feed to this file instead of stdout")
+		fs.Parse(args[1:])
+		if err := journal.ProcessChanges(); err != nil {
+			return err
+		}
+		if *output == "" {
+			return journal.WriteFeed(os.Stdout, *limit)
+		}
+		fout, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("error write feed file: %w", err)
+		}
+		defer fout.Close()
+		if err := journal.WriteFeed(fout, *limit); err != nil {
+			return err
+		}
+		return fout.Close()
+	case "links":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: links <note>")
+		}
+		return journal.PrintBacklinks(args[1])
+	case "archive":
+		return journal.Archive()
+	case "clean":
+		return journal.Clean()
+	case "move":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: move <src> <dst>")
+		}
+		return journal.Move(args[1], args[2])
+	case "capture":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: capture <text>")
+		}
+		return journal.Capture(strings.Join(args[1:], " "))
+	case "append":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: append <text>")
+		}
+		return journal.Append(strings.Join(args[1:], " "))
+	case "backfill":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: backfill <YYYY-MM-DD> <YYYY-MM-DD>")
+		}
+		return journal.Backfill(args[1], args[2])
+	case "encrypt":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: encrypt <note>")
+		}
+		return journal.Encrypt(args[1])
+	case "check":
+		fs := flag.NewFlagSet("check", flag.ExitOnError)
+		headers := fs.Bool("headers", false, "also check that the first header date matches the file")
+		prune := fs.Bool("prune", false, "drop stored tags whose line no longer has the tag")
+		fs.Parse(args[1:])
+		return journal.Check(*headers, *prune)
+	case "wc":
+		fs := flag.NewFlagSet("wc", flag.ExitOnError)
+		perFile := fs.Bool("file", false, "print the count of every note, largest first")
+		fs.Parse(args[1:])
+		return journal.PrintWordStats(*perFile)
+	case "report":
+		fs := flag.NewFlagSet("report", flag.ExitOnError)
+		stdout := fs.Bool("stdout", false, "print the report instead of writing reports/YYYY-MM.md")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: report [-stdout] <YYYY-MM>")
+		}
+		return journal.WriteReport(fs.Arg(0), *stdout)
+	case "tag":
+		fs := flag.NewFlagSet("tag rename", flag.ExitOnError)
+		force := fs.Bool("force", false, "merge into an existing tag type")
+		if len(args) > 1 && args[1] == "rename" {
+			fs.Parse(args[2:])
+		}
+		if len(args) < 2 || args[1] != "rename" || fs.NArg() != 2 {
+			return fmt.Errorf("usage: tag rename [-force] <old> <new>")
+		}
+		return journal.RenameTag(fs.Arg(0), fs.Arg(1), *force)
+	case "gc":
+		fs := flag.NewFlagSet("gc", flag.ExitOnError)
+		repack := fs.Bool("repack", false, "also repack all objects after gc")
+		fs.Parse(args[1:])
+		return journal.GC(*repack)
+	case "fix":
+		return journal.Fix()
+	case "undo":
+		return journal.Undo()
+	case "diff":
+		return journal.PrintChanges()
+	case "fmt":
+		fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+		write := fs.Bool("w", false, "rewrite notes in place instead of printing them")
+		fs.Parse(args[1:])
+		return journal.Format(fs.Args(), *write)
+	case "export":
+		if len(args) != 3 || args[1] != "html" {
+			return fmt.Errorf("usage: export html <outdir>")
+		}
+		return journal.ExportHTML(args[2])
+	default:
+		return fmt.Errorf("UNKNOWN COMMAND '%s'", args[0])
+	}
+}
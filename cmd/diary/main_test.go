@@ -0,0 +1,67 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestJournalDirFromDiaryHome(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DIARY_HOME", dir)
+	got, err := journalDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != dir {
+		t.Errorf("journalDir = %q, want $DIARY_HOME %q", got, dir)
+	}
+}
+
+func TestJournalDirFlagOverridesDiaryHome(t *testing.T) {
+	t.Setenv("DIARY_HOME", t.TempDir())
+	flagDir := t.TempDir()
+	got, err := journalDir(flagDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != flagDir {
+		t.Errorf("journalDir = %q, want -dir %q", got, flagDir)
+	}
+}
+
+func TestJournalDirDefaultsToHomeJournal(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("DIARY_HOME", "")
+	t.Setenv("HOME", home)
+	got, err := journalDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "journal"); got != want {
+		t.Errorf("journalDir = %q, want %q", got, want)
+	}
+}
+
+func TestLocked(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"init"}, true},
+		{[]string{"index"}, true},
+		{[]string{"fmt"}, false},
+		{[]string{"fmt", "-w"}, true},
+		{[]string{"fmt", "--w", "a.md"}, true},
+		{[]string{"fmt", "-w=false", "a.md"}, false},
+		{[]string{"fmt", "a.md", "-w"}, false},
+		{[]string{"check", "-headers"}, false},
+		{[]string{"check", "-headers", "-prune"}, true},
+		{[]string{"check", "-prune=true"}, true},
+		{[]string{"list"}, false},
+	} {
+		if got := locked(c.args); got != c.want {
+			t.Errorf("locked(%q) = %t, want %t", c.args, got, c.want)
+		}
+	}
+}
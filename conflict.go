@@ -0,0 +1,39 @@
+package diary
+
+import (
+	"fmt"
+	"strings"
+)
+
+// conflictLines returns the line numbers of merge conflict markers left by
+// git. A "=======" line only counts inside a conflict, elsewhere it is a
+// setext header underline.
+func conflictLines(data []byte) []int {
+	var result []int
+	open := false
+	for i, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		switch {
+		case isMarker(line, "<<<<<<<"):
+			open = true
+		case open && line == "=======", open && isMarker(line, "|||||||"):
+		case open && isMarker(line, ">>>>>>>"):
+			open = false
+		default:
+			continue
+		}
+		result = append(result, i+1)
+	}
+	return result
+}
+
+func isMarker(line, marker string) bool {
+	return line == marker || strings.HasPrefix(line, marker+" ")
+}
+
+func conflictError(fn string, lines []int) error {
+	nums := make([]string, len(lines))
+	for i, l := range lines {
+		nums[i] = fmt.Sprint(l)
+	}
+	return fmt.Errorf("merge conflict markers in '%s' line %s, resolve them first", fn, strings.Join(nums, ", "))
+}
@@ -0,0 +1,43 @@
+package diary
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const conflictedNote = "# Note\n- *TODO* kept\n<<<<<<< HEAD\n- *TODO* mine\n=======\n- *TODO* theirs\n>>>>>>> 1234567 (2024-03-01 09:00:00)\n"
+
+func TestConflictLines(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		want []int
+	}{
+		{conflictedNote, []int{3, 5, 7}},
+		{"<<<<<<< HEAD\r\na\r\n||||||| base\r\nb\r\n=======\r\nc\r\n>>>>>>> x\r\n", []int{1, 3, 5, 7}},
+		{"Title\n=======\n\ntext\n", nil},
+		{"<<<<<<<< not a marker\n>>>>>>>no space\n", nil},
+	} {
+		if got := conflictLines([]byte(c.text)); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("conflictLines(%q) = %v, want %v", c.text, got, c.want)
+		}
+	}
+}
+
+func TestConflictMarkersStopIndexBuild(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"a.md": "- *TODO* fine\n",
+		"b.md": conflictedNote,
+	})
+	err := j.ProcessAll()
+	if err == nil || err.Error() != "merge conflict markers in 'b.md' line 3, 5, 7, resolve them first" {
+		t.Fatalf("err = %v", err)
+	}
+	out := captureStdout(t, func() error {
+		j.Check(false, false)
+		return nil
+	})
+	if !strings.Contains(out, "merge conflict markers in 'b.md' line 3, 5, 7") {
+		t.Errorf("check output:\n%s", out)
+	}
+}
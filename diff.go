@@ -0,0 +1,104 @@
+package diary
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+var tagLinkPattern = regexp.MustCompile(`\]\([^)]*\)`)
+
+type TagChange struct {
+	Path    string
+	Status  string
+	Added   []Tag
+	Removed []Tag
+}
+
+// Changes compares the tags stored in the config with the current content of
+// every note changed since the last index build.
+func (j *Journal) Changes() ([]TagChange, error) {
+	var changes []change
+	if j.Hash == "" || !j.hasRepo() {
+		err := j.walkNotes(func(n *Note) error {
+			changes = append(changes, change{Path: n.Path, Status: "?", note: n})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if changes, err = j.changedNotes(); err != nil {
+			return nil, err
+		}
+	}
+	var result []TagChange
+	for _, c := range changes {
+		if j.isIndex(c.Path) {
+			continue
+		}
+		stored := j.storedTags(c.Path)
+		var current []Tag
+		if c.note != nil {
+			tags, err := c.note.parse()
+			if err != nil {
+				return nil, err
+			}
+			current = tags
+		}
+		result = append(result, TagChange{Path: c.Path, Status: c.Status, Added: tagsMissing(current, stored), Removed: tagsMissing(stored, current)})
+	}
+	return result, nil
+}
+
+// tagsMissing returns the tags of a that have no tag of the same kind and
+// text in b. Link targets are ignored since they carry the note time, which
+// follows the modification time for plain notes.
+func tagsMissing(a, b []Tag) []Tag {
+	have := make(map[string]int)
+	for _, t := range b {
+		have[tagKey(t)]++
+	}
+	var result []Tag
+	for _, t := range a {
+		k := tagKey(t)
+		if have[k] > 0 {
+			have[k]--
+			continue
+		}
+		result = append(result, t)
+	}
+	sort.SliceStable(result, func(x, y int) bool {
+		return result[x].LineNo < result[y].LineNo
+	})
+	return result
+}
+
+func (j *Journal) PrintChanges() error {
+	changes, err := j.Changes()
+	if err != nil {
+		return err
+	}
+	if j.json {
+		result := []ChangeResult{}
+		for _, c := range changes {
+			result = append(result, ChangeResult{Path: c.Path, Status: c.Status, Added: tagResults(c.Added), Removed: tagResults(c.Removed)})
+		}
+		return writeResult(result)
+	}
+	for _, c := range changes {
+		fmt.Printf("%s %s\n", c.Status, c.Path)
+		for _, t := range c.Removed {
+			fmt.Printf("  - %s\n", t.Text)
+		}
+		for _, t := range c.Added {
+			fmt.Printf("  + %s\n", t.Text)
+		}
+	}
+	return nil
+}
+
+func tagKey(t Tag) string {
+	return t.Tag + " " + tagLinkPattern.ReplaceAllString(t.Text, "]")
+}
@@ -0,0 +1,53 @@
+package diary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChangesFromGitDiff(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		"a.md":    "# A\n- *TODO* kept\n- *TODO* added today\n",
+		"new.md":  "- *DOING* untracked\n",
+		"same.md": "- *TODO* same\n",
+	})
+	j.Hash = "1111111"
+	j.Todos["a.md"] = []Tag{
+		{LineNo: 2, Tag: "TODO", Text: "- *[TODO](a.md#L2)* kept"},
+		{LineNo: 3, Tag: "TODO", Text: "- *[TODO](a.md#L3)* dropped"},
+	}
+	j.Todos["gone.md"] = []Tag{{LineNo: 1, Tag: "TODO", Text: "- *TODO* deleted"}}
+	git.others = "new.md\n"
+	git.diff = "M\ta.md\nD\tgone.md\nM\tindex.md\n"
+
+	changes, err := j.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		line := c.Status + " " + c.Path
+		for _, t := range c.Removed {
+			line += " -" + t.Text[strings.LastIndex(t.Text, " ")+1:]
+		}
+		for _, t := range c.Added {
+			line += " +" + t.Text[strings.LastIndex(t.Text, " ")+1:]
+		}
+		got = append(got, line)
+	}
+	want := []string{"M a.md -dropped +today", "D gone.md -deleted", "? new.md +untracked"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if calls := git.called("diff"); len(calls) != 1 || calls[0] != "diff 1111111 --name-status -M" {
+		t.Errorf("diff calls = %q", calls)
+	}
+	if len(j.Todos["gone.md"]) != 1 {
+		t.Errorf("diff changed the stored tags: %v", j.Todos)
+	}
+
+	out := captureStdout(t, j.PrintChanges)
+	if !strings.Contains(out, "M a.md\n  - - *[TODO](a.md#L3)* dropped\n  + ") || !strings.Contains(out, "D gone.md\n  - - *TODO* deleted\n") {
+		t.Errorf("output:\n%s", out)
+	}
+}
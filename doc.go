@@ -0,0 +1,15 @@
+// Package diary keeps a markdown journal indexed: it collects the DOING,
+// TODO, LATER and WAITING tags of every note into index.md and commits the
+// result with git. The diary command in cmd/diary is a thin wrapper around
+// it.
+//
+//	j, err := diary.OpenJournal(dir, "")
+//	if err != nil {
+//		return err
+//	}
+//	defer j.Close()
+//	if err := j.ProcessChanges(); err != nil {
+//		return err
+//	}
+//	return j.Write()
+package diary
@@ -0,0 +1,126 @@
+package diary
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+// doctor prints a checklist of the setup, colored when stdout is a
+// terminal, with a hint for every failed check.
+type doctor struct {
+	color  bool
+	failed int
+}
+
+func (d *doctor) check(name string, err error, hint string) bool {
+	mark, code := "ok", "32"
+	if err != nil {
+		mark, code = "FAIL", "31"
+		d.failed++
+	}
+	if d.color {
+		mark = fmt.Sprintf("\x1b[%sm%s\x1b[0m", code, mark)
+	}
+	if err != nil {
+		fmt.Printf("%-4s %s: %v\n", mark, name, err)
+		fmt.Printf("     %s\n", hint)
+		return false
+	}
+	fmt.Printf("%-4s %s\n", mark, name)
+	return true
+}
+
+func (d *doctor) result() error {
+	if d.failed > 0 {
+		return fmt.Errorf("%d problems found", d.failed)
+	}
+	return nil
+}
+
+// Doctor checks that the journal in dir can be used: the directory is
+// writable, the config is valid, git is set up with a remote and the editor
+// is found. It only reads, so it also runs on a journal OpenJournal rejects.
+func Doctor(dir, config string, plain bool) error {
+	d := &doctor{color: !plain && isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""}
+	if st, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		d.check("journal directory "+dir, errors.New("does not exist"), "run 'diary init' to create it")
+		return d.result()
+	} else if err == nil && !st.IsDir() {
+		err = errors.New("not a directory")
+		d.check("journal directory "+dir, err, "point -dir or $DIARY_HOME at a directory")
+		return d.result()
+	} else if !d.check("journal directory "+dir, err, "check the path given by -dir or $DIARY_HOME") {
+		return d.result()
+	}
+	d.check("journal directory writable", writable(dir), "fix the permissions of "+dir)
+
+	if config == "" {
+		config = defaultConfig
+	}
+	probe := &Journal{path: dir, config: config}
+	// OpenJournal sets a corrupt config aside, so the JSON is checked first
+	var journal *Journal
+	data, err := ioutil.ReadFile(probe.configPath())
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	} else if err == nil {
+		var stored Journal
+		if err = json.Unmarshal(data, &stored); err != nil {
+			d.check("config "+config, err, "fix the JSON, or remove the file to rebuild the index from the notes")
+		}
+	} else {
+		d.check("config "+config, err, "fix the permissions of "+probe.configPath())
+	}
+	if err == nil {
+		journal, err = OpenJournal(dir, config)
+		if d.check("config "+config, err, "fix the value in "+probe.configPath()) {
+			defer journal.Close()
+		}
+	}
+
+	var git GitRunner = &execGit{dir: dir, log: newLogger()}
+	if journal != nil {
+		git = journal.git
+	}
+	if _, err := exec.LookPath("git"); !d.check("git installed", err, "install git and put it on PATH") {
+		return d.result()
+	}
+	var repo error
+	if !git.IsRepo() {
+		repo = errors.New("not a git repository")
+	}
+	if d.check("git repository", repo, fmt.Sprintf("run 'git init' in %s, changes are not committed until then", dir)) {
+		remotes, err := git.Remotes()
+		if err == nil && len(remotes) == 0 {
+			err = errors.New("no remote")
+		}
+		if d.check("git remote", err, "run 'git remote add origin <url>' to push and AutoPush") {
+			ahead, upstream, err := git.Unpushed()
+			if err == nil && !upstream {
+				err = errors.New("no upstream branch")
+			}
+			if d.check("git upstream", err, "run 'git push -u origin HEAD' once to set it") {
+				var unpushed error
+				if ahead > 0 {
+					unpushed = fmt.Errorf("%d commits not pushed", ahead)
+				}
+				d.check("everything pushed", unpushed, "run 'diary push'")
+			}
+		}
+	}
+
+	editor := defaultEditor()
+	if journal != nil {
+		editor = journal.Editor
+	}
+	parts, err := splitCommand(editor)
+	if err == nil {
+		_, err = exec.LookPath(parts[0])
+	}
+	d.check("editor "+editor, err, "install it, or set Editor in the config or $EDITOR")
+	return d.result()
+}
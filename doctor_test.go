@@ -0,0 +1,70 @@
+package diary
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoctorMissingEditorAndRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".journal.json": `{"Editor": "no-such-editor --wait"}`})
+	var err error
+	out := captureStdout(t, func() error {
+		err = Doctor(dir, "", true)
+		return nil
+	})
+	for _, want := range []string{
+		"ok   journal directory " + dir + "\n",
+		"ok   journal directory writable\n",
+		"ok   config .journal.json\n",
+		"FAIL git repository: not a git repository\n     run 'git init' in " + dir,
+		"FAIL editor no-such-editor --wait: ",
+		"     install it, or set Editor in the config or $EDITOR\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("doctor without %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "git remote") || strings.Contains(out, "\x1b[") {
+		t.Errorf("doctor:\n%s", out)
+	}
+	if err == nil || err.Error() != "2 problems found" {
+		t.Errorf("err = %v", err)
+	}
+	// nothing is written, not even the lock or cache
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("doctor wrote files: %v", entries)
+	}
+}
+
+func TestDoctorMissingDirAndBadConfig(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "none")
+	var err error
+	out := captureStdout(t, func() error {
+		err = Doctor(missing, "", true)
+		return nil
+	})
+	if !strings.Contains(out, "FAIL journal directory "+missing+": does not exist\n     run 'diary init' to create it\n") || err == nil {
+		t.Errorf("doctor: %v\n%s", err, out)
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".journal.json": "{not json"})
+	out = captureStdout(t, func() error {
+		err = Doctor(dir, "", true)
+		return nil
+	})
+	if !strings.Contains(out, "FAIL config .journal.json: ") || err == nil {
+		t.Errorf("doctor: %v\n%s", err, out)
+	}
+	if _, serr := os.Stat(filepath.Join(dir, ".journal.json.bad")); !os.IsNotExist(serr) {
+		t.Errorf("corrupt config set aside: %v", serr)
+	}
+}
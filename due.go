@@ -0,0 +1,70 @@
+package diary
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// daysBetween is the number of calendar days from from to to, rounded so a
+// day of 23 or 25 hours across a DST change still counts as one.
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+func (j *Journal) Overdue(now time.Time) ([]Tag, error) {
+	return j.dueBefore(startOfDay(now))
+}
+
+// dueBefore returns the open tags due before end, earliest first.
+func (j *Journal) dueBefore(end time.Time) ([]Tag, error) {
+	tags, err := j.collectTags()
+	if err != nil {
+		return nil, err
+	}
+	var result []Tag
+	for _, t := range tags {
+		if t.Tag != "DONE" && t.Due != nil && t.Due.Before(end) {
+			result = append(result, t)
+		}
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		if !result[a].Due.Equal(*result[b].Due) {
+			return result[a].Due.Before(*result[b].Due)
+		}
+		if result[a].Path() != result[b].Path() {
+			return result[a].Path() < result[b].Path()
+		}
+		return result[a].LineNo < result[b].LineNo
+	})
+	return result, nil
+}
+
+func (j *Journal) PrintOverdue() error {
+	now := j.now()
+	tags, err := j.Overdue(now)
+	if err != nil {
+		return err
+	}
+	today := startOfDay(now)
+	if j.json {
+		result := []OverdueResult{}
+		for _, t := range tags {
+			result = append(result, OverdueResult{TagResult: tagResult(t), DaysLate: daysBetween(*t.Due, today)})
+		}
+		return writeResult(result)
+	}
+	tt := j.newTable("LATE", "DUE", "NOTE", "TEXT")
+	tt.alignRight(0)
+	for _, t := range tags {
+		days := daysBetween(*t.Due, today)
+		tt.add(fmt.Sprintf("%dd", days), t.Due.Format("2006-01-02"), fmt.Sprintf("%s:%d", t.Path(), t.LineNo), t.Text)
+	}
+	return tt.write(os.Stdout)
+}
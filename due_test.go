@@ -0,0 +1,57 @@
+package diary
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDueDate(t *testing.T) {
+	j, _, log := newTestJournal(t, map[string]string{
+		"due.md": "- *TODO* pay rent @due:2024-03-01\n- *TODO* bad @due:2024-13-01\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	tags := j.Todos["due.md"]
+	if len(tags) != 2 {
+		t.Fatalf("tags = %v", tags)
+	}
+	if tags[0].Due == nil || tags[0].Due.Format("2006-01-02") != "2024-03-01" {
+		t.Errorf("due = %v", tags[0].Due)
+	}
+	if !strings.HasSuffix(tags[0].Text, "pay rent `@due:2024-03-01`") {
+		t.Errorf("text = %q", tags[0].Text)
+	}
+	if tags[1].Due != nil || !strings.Contains(log.String(), "invalid due date '2024-13-01'") {
+		t.Errorf("invalid due %v, log %q", tags[1].Due, log.String())
+	}
+}
+
+func TestOverdue(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"due.md": "- *TODO* late @due:2024-02-28\n- *TODO* today @due:2024-03-01\n- *TODO* no due date\n- *DONE* finished @due:2024-01-01\n- *LATER* later late @due:2024-02-01\n",
+	})
+	now := time.Date(2024, 3, 1, 18, 0, 0, 0, time.Local)
+	tags, err := j.Overdue(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0].LineNo != 5 || tags[1].LineNo != 1 {
+		t.Errorf("overdue = %v, want lines 5 and 1", tags)
+	}
+}
+
+func TestOverdueAcrossDST(t *testing.T) {
+	// Berlin moves to summer time on 2024-03-31, making the two days from
+	// the due date 47 hours long
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"Timezone": "Europe/Berlin"}`,
+		"due.md":        "- *TODO* across the change @due:2024-03-30\n",
+	})
+	setNow(t, j, "2024-04-01T10:00:00+02:00")
+	j.SetJSON(true)
+	if out := captureStdout(t, j.PrintOverdue); !strings.Contains(out, `"DaysLate": 2`) {
+		t.Errorf("overdue:\n%s", out)
+	}
+}
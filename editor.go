@@ -0,0 +1,140 @@
+package diary
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var vimEditors = map[string]bool{"vi": true, "vim": true, "nvim": true, "lvim": true, "gvim": true, "mvim": true}
+
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("error parse command '%s': unterminated quote or escape", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("error parse command '%s': empty command", s)
+	}
+	return args, nil
+}
+
+// defaultEditor is $EDITOR, or lvim when unset. Editor in the config takes
+// precedence.
+func defaultEditor() string {
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		return editor
+	}
+	return "lvim"
+}
+
+func isVim(editor string) bool {
+	return vimEditors[filepath.Base(editor)]
+}
+
+func (j *Journal) editorCommand(file string, line int, vimArgs ...string) (*exec.Cmd, error) {
+	parts, err := splitCommand(j.Editor)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath(parts[0]); err != nil {
+		return nil, fmt.Errorf("editor '%s' not found in PATH; set Editor in %s or $EDITOR", parts[0], j.config)
+	}
+	args := parts[1:]
+	if len(j.EditorArgs) > 0 {
+		for _, a := range j.EditorArgs {
+			a = strings.ReplaceAll(a, "{file}", file)
+			a = strings.ReplaceAll(a, "{line}", strconv.Itoa(line))
+			args = append(args, a)
+		}
+	} else if isVim(parts[0]) {
+		args = append(args, vimArgs...)
+		args = append(args, file)
+	} else {
+		args = append(args, file)
+	}
+	cmd := exec.Command(parts[0], args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd, nil
+}
+
+func (j *Journal) useVimArgs() bool {
+	if len(j.EditorArgs) > 0 {
+		return false
+	}
+	parts, err := splitCommand(j.Editor)
+	return err == nil && isVim(parts[0])
+}
+
+func (j *Journal) nvimSocket() string {
+	if j.NvimSocket != "" {
+		return j.NvimSocket
+	}
+	return os.Getenv("NVIM")
+}
+
+// remoteCommand sends cmds to the Neovim listening on nvimSocket after
+// opening file there. It is nil when no socket is configured, or the socket
+// is a path that does not exist.
+func (j *Journal) remoteCommand(file string, cmds []string) *exec.Cmd {
+	socket := j.nvimSocket()
+	if socket == "" {
+		return nil
+	}
+	if strings.ContainsRune(socket, os.PathSeparator) {
+		if _, err := os.Stat(socket); err != nil {
+			return nil
+		}
+	}
+	keys := `<C-\><C-N>:edit ` + strings.ReplaceAll(vimKeys(file), " ", `\ `) + "<CR>"
+	for _, c := range cmds {
+		keys += ":" + vimKeys(c) + "<CR>"
+	}
+	return exec.Command("nvim", "--server", socket, "--remote-send", keys)
+}
+
+// vimKeys escapes s for --remote-send, where "<" starts a key name.
+func vimKeys(s string) string {
+	return strings.ReplaceAll(s, "<", "<lt>")
+}
@@ -0,0 +1,117 @@
+package diary
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeEditors puts executables of the given names first on PATH.
+func fakeEditors(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestSplitCommand(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"vim", []string{"vim"}},
+		{"code --wait", []string{"code", "--wait"}},
+		{`emacsclient -c -a ""`, []string{"emacsclient", "-c", "-a", ""}},
+		{`"/opt/my editor/bin/ed" 'a b' c\ d`, []string{"/opt/my editor/bin/ed", "a b", "c d"}},
+	} {
+		got, err := splitCommand(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") || len(got) != len(tc.want) {
+			t.Errorf("splitCommand(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	for _, in := range []string{"", "  ", `vim "unterminated`} {
+		if _, err := splitCommand(in); err == nil {
+			t.Errorf("splitCommand(%q) succeeded", in)
+		}
+	}
+}
+
+func TestEditorCommandArgs(t *testing.T) {
+	dir := fakeEditors(t, "nvim", "code", "nano")
+	for _, tc := range []struct {
+		editor string
+		args   []string
+		want   []string
+	}{
+		{"nvim", nil, []string{"+3", "-c", "startinsert", "note.md"}},
+		{"code --wait", []string{"--goto", "{file}:{line}"}, []string{"--wait", "--goto", "note.md:3"}},
+		{"nano", nil, []string{"note.md"}},
+	} {
+		j := &Journal{Editor: tc.editor, EditorArgs: tc.args}
+		cmd, err := j.editorCommand("note.md", 3, "+3", "-c", "startinsert")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name := strings.Fields(tc.editor)[0]; cmd.Path != filepath.Join(dir, name) {
+			t.Errorf("%s: path = %q", tc.editor, cmd.Path)
+		}
+		if got := cmd.Args[1:]; strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("%s: args = %q, want %q", tc.editor, got, tc.want)
+		}
+	}
+}
+
+func TestRemoteCommand(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "nvim.sock")
+	if err := os.WriteFile(socket, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NVIM", "")
+	j := &Journal{NvimSocket: socket}
+	cmd := j.remoteCommand("/j/my notes/2024-03-01.md", []string{"norm Go## 09:00:00", "norm Gi<br>", "startinsert"})
+	if cmd == nil {
+		t.Fatal("no remote command with a socket")
+	}
+	want := []string{"nvim", "--server", socket, "--remote-send",
+		`<C-\><C-N>:edit /j/my\ notes/2024-03-01.md<CR>:norm Go## 09:00:00<CR>:norm Gi<lt>br><CR>:startinsert<CR>`}
+	if strings.Join(cmd.Args, "\n") != strings.Join(want, "\n") {
+		t.Errorf("args = %q, want %q", cmd.Args, want)
+	}
+}
+
+func TestRemoteCommandSocket(t *testing.T) {
+	t.Setenv("NVIM", "")
+	if cmd := (&Journal{}).remoteCommand("a.md", nil); cmd != nil {
+		t.Errorf("remote command without a socket: %q", cmd.Args)
+	}
+	if cmd := (&Journal{NvimSocket: filepath.Join(t.TempDir(), "gone.sock")}).remoteCommand("a.md", nil); cmd != nil {
+		t.Errorf("remote command to a missing socket: %q", cmd.Args)
+	}
+	if cmd := (&Journal{NvimSocket: "localhost:6666"}).remoteCommand("a.md", nil); cmd == nil || cmd.Args[2] != "localhost:6666" {
+		t.Errorf("remote command to a tcp socket: %v", cmd)
+	}
+	t.Setenv("NVIM", "127.0.0.1:7777")
+	if cmd := (&Journal{}).remoteCommand("a.md", nil); cmd == nil || cmd.Args[2] != "127.0.0.1:7777" {
+		t.Errorf("remote command from $NVIM: %v", cmd)
+	}
+}
+
+func TestMissingEditor(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{".journal.json": `{"Editor": "no-such-editor-xyz --wait"}`})
+	want := "editor 'no-such-editor-xyz' not found in PATH; set Editor in .journal.json or $EDITOR"
+	if _, err := j.editorCommand("a.md", 1); err == nil || err.Error() != want {
+		t.Errorf("editorCommand: %v", err)
+	}
+	if err := j.OpenIndex(); err == nil || err.Error() != want {
+		t.Errorf("OpenIndex: %v", err)
+	}
+}
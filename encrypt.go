@@ -0,0 +1,325 @@
+package diary
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ageExt marks a note encrypted with age, e.g. "2024/01/2024-01-02.md.age".
+// Encrypted notes are decrypted in memory with the AgeIdentity file and are
+// never written back in plain text. EncryptDiary creates new diary notes
+// encrypted for AgeRecipients and EncryptIndex does the same for the
+// generated index files.
+const ageExt = ".age"
+
+func (n *Note) Encrypted() bool {
+	return strings.HasSuffix(n.Path, ageExt)
+}
+
+// isNoteFile reports whether fn is a note: a ".md" file, or a ".md.age"
+// file when encrypted notes can be read.
+func (j *Journal) isNoteFile(fn string) bool {
+	if strings.HasSuffix(fn, ".md") {
+		return true
+	}
+	return strings.HasSuffix(fn, ".md"+ageExt) && j.canDecrypt()
+}
+
+// canDecrypt reports whether encrypted notes can be read. Without an age
+// binary or AgeIdentity they are skipped, which is warned about once.
+func (j *Journal) canDecrypt() bool {
+	if j.decrypt == nil {
+		ok, reason := false, ""
+		if j.AgeIdentity == "" {
+			reason = "no AgeIdentity in " + j.config
+		} else if fn, err := ExpandPath(j.AgeIdentity); err != nil {
+			reason = err.Error()
+		} else if _, err := os.Stat(fn); err != nil {
+			reason = fmt.Sprintf("identity '%s' not found", j.AgeIdentity)
+		} else if _, err := exec.LookPath("age"); err != nil {
+			reason = "age not found in PATH"
+		} else {
+			ok = true
+		}
+		j.decrypt = &ok
+		if !ok {
+			j.warnf("skip encrypted notes, %s", reason)
+		}
+	}
+	return *j.decrypt
+}
+
+// read returns the content of the note, decrypted for encrypted notes.
+func (n *Note) read() ([]byte, error) {
+	ff := filepath.Join(n.journal.path, n.Path)
+	if n.Encrypted() {
+		return n.journal.decryptFile(ff)
+	}
+	data, err := ioutil.ReadFile(ff)
+	if err != nil {
+		return nil, fmt.Errorf("error read file '%s': %w", n.Path, err)
+	}
+	return data, nil
+}
+
+// plainConfig is the journal as written to the config, which is committed
+// by default: the tags, front matter and links of encrypted notes are left
+// out and read back from the notes by loadEncrypted.
+func (j *Journal) plainConfig() *Journal {
+	c := *j
+	c.Doings = plainTags(j.Doings)
+	c.Todos = plainTags(j.Todos)
+	c.Laters = plainTags(j.Laters)
+	c.Waitings = plainTags(j.Waitings)
+	c.Done = plainTags(j.Done)
+	c.Custom = make(map[string]map[string][]Tag, len(j.Custom))
+	for kind, tags := range j.Custom {
+		c.Custom[kind] = plainTags(tags)
+	}
+	c.Meta = make(map[string]map[string]string, len(j.Meta))
+	for fn, meta := range j.Meta {
+		if !strings.HasSuffix(fn, ageExt) {
+			c.Meta[fn] = meta
+		}
+	}
+	c.Links = make(map[string][]string, len(j.Links))
+	for fn, links := range j.Links {
+		if !strings.HasSuffix(fn, ageExt) {
+			c.Links[fn] = links
+		}
+	}
+	return &c
+}
+
+func plainTags(tagMap map[string][]Tag) map[string][]Tag {
+	plain := make(map[string][]Tag, len(tagMap))
+	for fn, tags := range tagMap {
+		if !strings.HasSuffix(fn, ageExt) {
+			plain[fn] = tags
+		}
+	}
+	return plain
+}
+
+// loadEncrypted parses the encrypted notes left out of the config. Their
+// History was recorded when they were processed, and as their old tags are
+// not known, tags added to them are not counted in the commit message.
+func (j *Journal) loadEncrypted() error {
+	return j.walkFiles(false, false, func(n *Note) error {
+		if !n.Encrypted() {
+			return nil
+		}
+		tags, err := n.parse()
+		n.fresh = false
+		return n.apply(tags, err)
+	})
+}
+
+// decryptFile returns the plain content of the age file ff.
+func (j *Journal) decryptFile(ff string) ([]byte, error) {
+	if j.AgeIdentity == "" {
+		return nil, fmt.Errorf("error decrypt '%s': no AgeIdentity in %s", filepath.Base(ff), j.config)
+	}
+	identity, err := ExpandPath(j.AgeIdentity)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("age", "--decrypt", "-i", identity, ff)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error decrypt '%s': %w\n%s", filepath.Base(ff), err, strings.TrimSpace(stderr.String()))
+	}
+	return out.Bytes(), nil
+}
+
+// encrypt returns data encrypted for AgeRecipients, fn naming it in errors.
+func (j *Journal) encrypt(fn string, data []byte) ([]byte, error) {
+	if len(j.AgeRecipients) == 0 {
+		return nil, fmt.Errorf("no AgeRecipients in %s", j.config)
+	}
+	args := []string{"--encrypt"}
+	for _, r := range j.AgeRecipients {
+		args = append(args, "-r", r)
+	}
+	cmd := exec.Command("age", args...)
+	var out, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error encrypt '%s': %w\n%s", fn, err, strings.TrimSpace(stderr.String()))
+	}
+	return out.Bytes(), nil
+}
+
+// encryptedDiary reports whether the diary note ff is edited encrypted,
+// because ff with ageExt exists or EncryptDiary is set and ff does not.
+func (j *Journal) encryptedDiary(ff string) (bool, error) {
+	if _, err := os.Stat(ff + ageExt); err == nil {
+		return true, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("error stat '%s': %w", ff+ageExt, err)
+	}
+	if !j.EncryptDiary {
+		return false, nil
+	}
+	if _, err := os.Stat(ff); err == nil {
+		return false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("error stat '%s': %w", ff, err)
+	}
+	return true, nil
+}
+
+// editDir holds the plain copies of encrypted files being edited. It is in
+// the journal rather than the shared temporary directory, and listed in
+// .gitignore so a copy left by a crash is not committed.
+const editDir = ".journal.edit"
+
+// editPlain calls edit on a plain copy of the age file ef, in a directory of
+// editDir only the user can read, and encrypts the copy back to ef when
+// edit changed it. The copy is removed whatever the outcome. A missing ef
+// starts out as a missing copy.
+func (j *Journal) editPlain(ef string, edit func(ff string) error) error {
+	root := filepath.Join(j.path, editDir)
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return fmt.Errorf("error create path '%s': %w", root, err)
+	}
+	dir, err := os.MkdirTemp(root, "edit-")
+	if err != nil {
+		return fmt.Errorf("error create temporary path: %w", err)
+	}
+	defer os.Remove(root)
+	defer os.RemoveAll(dir)
+	ff := filepath.Join(dir, strings.TrimSuffix(filepath.Base(ef), ageExt))
+	var old []byte
+	if _, err := os.Stat(ef); err == nil {
+		if old, err = j.decryptFile(ef); err != nil {
+			return err
+		}
+		if err := os.WriteFile(ff, old, 0600); err != nil {
+			return fmt.Errorf("error write file '%s': %w", ff, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error stat '%s': %w", ef, err)
+	}
+	if err := edit(ff); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(ff)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error read file '%s': %w", ff, err)
+	}
+	if old != nil && bytes.Equal(old, data) {
+		return nil
+	}
+	enc, err := j.encrypt(filepath.Base(ef), data)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(ef, enc); err != nil {
+		return fmt.Errorf("error write file '%s': %w", ef, err)
+	}
+	return nil
+}
+
+// writeIndexFile writes the generated file fn, or fn with ageExt encrypted
+// when EncryptIndex is set, removing the plain one. As age output differs
+// on every run, an unchanged file is told by its decrypted content.
+func (j *Journal) writeIndexFile(fn string, data []byte) error {
+	if !j.EncryptIndex {
+		return writeFileChanged(fn, data)
+	}
+	ef := fn + ageExt
+	if _, err := os.Stat(ef); err == nil && j.canDecrypt() {
+		if old, err := j.decryptFile(ef); err == nil && bytes.Equal(old, data) {
+			return removeFile(fn)
+		}
+	}
+	enc, err := j.encrypt(filepath.Base(fn), data)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(ef, enc); err != nil {
+		return err
+	}
+	return removeFile(fn)
+}
+
+// removeIndexFile removes the generated file fn, plain or encrypted.
+func removeIndexFile(fn string) error {
+	if err := removeFile(fn); err != nil {
+		return err
+	}
+	return removeFile(fn + ageExt)
+}
+
+func removeFile(fn string) error {
+	if err := os.Remove(fn); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// indexFile is the name the generated file fn is written under.
+func (j *Journal) indexFile(fn string) string {
+	if j.EncryptIndex {
+		return fn + ageExt
+	}
+	return fn
+}
+
+// Encrypt replaces a plain note with a copy encrypted for AgeRecipients.
+func (j *Journal) Encrypt(fn string) error {
+	fn = filepath.ToSlash(filepath.Clean(fn))
+	if len(j.AgeRecipients) == 0 {
+		return fmt.Errorf("no AgeRecipients in %s", j.config)
+	}
+	n, err := j.NewNote(fn)
+	if err != nil {
+		return err
+	}
+	if n.Encrypted() {
+		return fmt.Errorf("'%s' is already encrypted", fn)
+	}
+	dst := fn + ageExt
+	if _, err := os.Stat(filepath.Join(j.path, dst)); err == nil {
+		return fmt.Errorf("'%s' already exists", dst)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error stat '%s': %w", dst, err)
+	}
+	fmt.Printf("%s -> %s\n", fn, dst)
+	if j.dryRun {
+		return nil
+	}
+	data, err := n.read()
+	if err != nil {
+		return err
+	}
+	enc, err := j.encrypt(fn, data)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(j.path, dst), enc); err != nil {
+		return fmt.Errorf("error write file '%s': %w", dst, err)
+	}
+	if err := os.Remove(filepath.Join(j.path, fn)); err != nil {
+		return fmt.Errorf("error remove '%s': %w", fn, err)
+	}
+	j.moveHistory(fn, dst)
+	j.purge(fn)
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	return j.Write()
+}
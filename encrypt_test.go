@@ -0,0 +1,421 @@
+package diary
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeAgeScript stands in for age: it encrypts stdin to base64 under a
+// header with a nonce and the recipients, so no two outputs are equal, and
+// decrypts only with an identity for one of the recipients.
+const fakeAgeScript = `#!/bin/sh
+if [ "$1" = --encrypt ]; then
+	shift
+	header="fake-age $$"
+	while [ "$1" = -r ]; do header="$header $2"; shift 2; done
+	echo "$header"
+	exec base64
+fi
+[ "$1" = --decrypt ] && [ "$2" = -i ] || exit 2
+key=$(sed -n 's/^# public key: //p' "$3")
+if [ -z "$key" ] || ! head -n 1 "$4" | grep -q " $key\( \|$\)"; then
+	echo "age: error: no identity matched any of the recipients" >&2
+	exit 1
+fi
+tail -n +2 "$4" | base64 -d
+`
+
+// fakeAge puts the fake age on PATH and returns the test key: an identity
+// file and its recipient.
+func fakeAge(t *testing.T) (identity, recipient string) {
+	t.Helper()
+	for _, name := range []string{"sh", "sed", "grep", "head", "tail", "base64"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s not installed", name)
+		}
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "age"), []byte(fakeAgeScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	recipient = "age1testkey"
+	identity = filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(identity, []byte("# public key: "+recipient+"\nAGE-SECRET-KEY-1TEST\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return identity, recipient
+}
+
+func ageConfig(identity, recipient, extra string) string {
+	return fmt.Sprintf(`{"Timezone": "UTC", "AgeIdentity": %q, "AgeRecipients": [%q]%s}`, identity, recipient, extra)
+}
+
+// writeEncrypted writes text encrypted to fn in the journal.
+func writeEncrypted(t *testing.T, j *Journal, fn, text string) {
+	t.Helper()
+	data, err := j.encrypt(fn, []byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(text)) {
+		t.Fatalf("encrypted '%s' holds the plain text", fn)
+	}
+	writeFiles(t, j.path, map[string]string{fn: string(data)})
+}
+
+// decrypted returns the plain content of the encrypted file fn.
+func decrypted(t *testing.T, j *Journal, fn string) string {
+	t.Helper()
+	data, err := j.decryptFile(j.resolve(fn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestEncryptedDiaryNotes(t *testing.T) {
+	identity, recipient := fakeAge(t)
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         ageConfig(identity, recipient, ""),
+		"2024/03/2024-03-02.md": "# Note 2024-03-02\n",
+	})
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-05T12:00:00Z")
+	writeEncrypted(t, j, "2024/03/2024-03-01.md.age", "# Note 2024-03-04\n\n## 09:00:00\n- *TODO* secret plan\n")
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if days := fmt.Sprint(j.Diary["2024-03"]); !strings.Contains(days, "[01 2024/03/2024-03-01.md.age]") {
+		t.Errorf("diary = %s", days)
+	}
+	if todos := j.Todos["2024/03/2024-03-01.md.age"]; len(todos) != 1 || !strings.Contains(todos[0].Text, "secret plan") {
+		t.Errorf("todos = %v", todos)
+	}
+
+	var out bytes.Buffer
+	if err := j.WriteFeed(&out, 0); err != nil {
+		t.Fatal(err)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(out.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Entries) != 2 || feed.Entries[1].Title != "Note 2024-03-04" || !strings.Contains(feed.Entries[1].Content.Body, "secret plan") {
+		t.Errorf("feed entries = %+v", feed.Entries)
+	}
+
+	outdir := t.TempDir()
+	if err := j.ExportHTML(outdir); err != nil {
+		t.Fatal(err)
+	}
+	if page := readFile(t, filepath.Join(outdir, "2024/03/2024-03-01.html")); !strings.Contains(page, "secret plan") {
+		t.Errorf("exported page:\n%s", page)
+	}
+	if index := readFile(t, filepath.Join(outdir, "index.html")); !strings.Contains(index, `href="2024/03/2024-03-01.html`) {
+		t.Errorf("exported index:\n%s", index)
+	}
+
+	var checkErr error
+	report := captureStdout(t, func() error {
+		checkErr = j.Check(true, false)
+		return nil
+	})
+	if checkErr == nil || !strings.Contains(report, "2024/03/2024-03-01.md.age: header date 2024-03-04 does not match the file, expected 2024-03-01") {
+		t.Errorf("check:\n%s", report)
+	}
+}
+
+func TestEncryptedNotesWithoutKey(t *testing.T) {
+	_, recipient := fakeAge(t)
+	j, _, log := newTestJournal(t, map[string]string{
+		".journal.json": fmt.Sprintf(`{"Timezone": "UTC", "AgeRecipients": [%q]}`, recipient),
+	})
+	writeEncrypted(t, j, "2024/03/2024-03-01.md.age", "- *TODO* secret plan\n")
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Todos) != 0 || len(j.Diary) != 0 {
+		t.Errorf("todos %v, diary %v", j.Todos, j.Diary)
+	}
+	if got := log.String(); strings.Count(got, "warning: skip encrypted notes, no AgeIdentity") != 1 {
+		t.Errorf("log = %q", got)
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	identity, recipient := fakeAge(t)
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": ageConfig(identity, recipient, ""),
+	})
+	j.AgeRecipients = []string{"age1otherkey"}
+	writeEncrypted(t, j, "2024/03/2024-03-01.md.age", "- *TODO* secret plan\n")
+	if err := j.ProcessAll(); err == nil || !strings.Contains(err.Error(), "no identity matched") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCreateEncryptedDiary(t *testing.T) {
+	identity, recipient := fakeAge(t)
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": ageConfig(identity, recipient, `, "EncryptDiary": true`),
+	})
+	j.SetNoEdit(true)
+	setNow(t, j, "2024-03-01T09:00:00Z")
+	if err := j.CreateDiary(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(j.path, "2024/03/2024-03-01.md")); !os.IsNotExist(err) {
+		t.Errorf("plain diary note written: %v", err)
+	}
+	fn := "2024/03/2024-03-01.md.age"
+	if got := decrypted(t, j, fn); got != "# Note 2024-03-01\n\n## 09:00:00\n\n\n" {
+		t.Errorf("diary note = %q", got)
+	}
+	if days := fmt.Sprint(j.Diary["2024-03"]); days != "[[01 "+fn+"]]" {
+		t.Errorf("diary = %s", days)
+	}
+
+	// an unchanged note is not encrypted again
+	before := readFile(t, filepath.Join(j.path, fn))
+	setNow(t, j, "2024-03-01T10:00:00Z")
+	if err := j.CreateDiary(); err != nil {
+		t.Fatal(err)
+	}
+	if after := readFile(t, filepath.Join(j.path, fn)); after != before {
+		t.Errorf("unchanged note rewritten:\n%s\n%s", before, after)
+	}
+}
+
+func TestCreateDiaryKeepsPlainNote(t *testing.T) {
+	identity, recipient := fakeAge(t)
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         ageConfig(identity, recipient, `, "EncryptDiary": true`),
+		"2024/03/2024-03-01.md": "# plain\n",
+	})
+	j.SetNoEdit(true)
+	setNow(t, j, "2024-03-01T09:00:00Z")
+	if err := j.CreateDiary(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(j.path, "2024/03/2024-03-01.md.age")); !os.IsNotExist(err) {
+		t.Errorf("encrypted copy of a plain note: %v", err)
+	}
+}
+
+func TestEncryptedIndex(t *testing.T) {
+	identity, recipient := fakeAge(t)
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": ageConfig(identity, recipient, `, "EncryptIndex": true, "MaxPerSection": 1`),
+		"index.md":      "stale plain index\n",
+		"a.md":          "- *TODO* first\n- *TODO* second\n",
+	})
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range []string{"index.md", "index.json", "index-todo.md"} {
+		if _, err := os.Stat(filepath.Join(j.path, fn)); !os.IsNotExist(err) {
+			t.Errorf("plain %s left: %v", fn, err)
+		}
+	}
+	index := decrypted(t, j, "index.md.age")
+	if !strings.Contains(index, "first") || strings.Contains(index, "second") || !strings.Contains(index, "see [TODO](index-todo.md.age)") {
+		t.Errorf("index:\n%s", index)
+	}
+	if listing := decrypted(t, j, "index-todo.md.age"); !strings.Contains(listing, "second") {
+		t.Errorf("listing:\n%s", listing)
+	}
+	if !strings.Contains(decrypted(t, j, "index.json.age"), "second") {
+		t.Error("index.json.age without the tags")
+	}
+
+	// the encrypted index is no note, and an unchanged one is kept
+	before := readFile(t, filepath.Join(j.path, "index.md.age"))
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Todos) != 1 {
+		t.Errorf("todos = %v", j.Todos)
+	}
+	if after := readFile(t, filepath.Join(j.path, "index.md.age")); after != before {
+		t.Errorf("unchanged index rewritten:\n%s\n%s", before, after)
+	}
+}
+
+func TestEncryptConfig(t *testing.T) {
+	for _, config := range []string{
+		`{"EncryptDiary": true}`,
+		`{"EncryptIndex": true, "AgeRecipients": ["age1testkey"]}`,
+	} {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{".journal.json": config})
+		if _, err := OpenJournal(dir, ""); err == nil {
+			t.Errorf("%s: no error", config)
+		}
+	}
+}
+
+// TestRealAge runs the round trip with age itself, when installed.
+func TestRealAge(t *testing.T) {
+	for _, name := range []string{"age", "age-keygen"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s not installed", name)
+		}
+	}
+	identity := filepath.Join(t.TempDir(), "key.txt")
+	out, err := exec.Command("age-keygen", "-o", identity).CombinedOutput()
+	if err != nil {
+		t.Fatalf("age-keygen: %v\n%s", err, out)
+	}
+	key := readFile(t, identity)
+	i := strings.Index(key, "# public key: ")
+	if i < 0 {
+		t.Fatalf("no public key in:\n%s", key)
+	}
+	recipient := strings.Fields(key[i+len("# public key: "):])[0]
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": ageConfig(identity, recipient, ""),
+		"a.md":          "- *TODO* secret plan\n",
+	})
+	j.SetNoCommit(true)
+	captureStdout(t, func() error { return j.Encrypt("a.md") })
+	if got := decrypted(t, j, "a.md.age"); got != "- *TODO* secret plan\n" {
+		t.Errorf("decrypted = %q", got)
+	}
+	if todos := j.Todos["a.md.age"]; len(todos) != 1 {
+		t.Errorf("todos = %v", j.Todos)
+	}
+}
+
+func TestEncryptedSplitIndex(t *testing.T) {
+	identity, recipient := fakeAge(t)
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": ageConfig(identity, recipient, `, "EncryptIndex": true, "SplitIndex": true`),
+		"a.md":          "- *TODO* secret\n",
+	})
+	j.SetNoCommit(true)
+	// the second write reads the marker back from the encrypted files
+	for i := 0; i < 2; i++ {
+		if err := j.ProcessAll(); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := decrypted(t, j, "todo.md"+ageExt); !strings.HasPrefix(got, splitMarker) || !strings.Contains(got, "secret") {
+		t.Errorf("todo.md.age:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(j.path, "todo.md")); !os.IsNotExist(err) {
+		t.Errorf("plain split file written: %v", err)
+	}
+}
+
+func TestEncryptedNotesStayOutOfConfig(t *testing.T) {
+	identity, recipient := fakeAge(t)
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": ageConfig(identity, recipient, ""),
+		"a.md":          "- *TODO* plain plan\n",
+	})
+	j.SetNoCommit(true)
+	j.Hash = "1111111"
+	for _, text := range []string{"- *TODO* secret plan\n", "---\ntopic: secret topic\n---\n- *DONE* secret plan\n"} {
+		writeEncrypted(t, j, "b.md.age", text)
+		if err := j.ProcessAll(); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if days, _ := j.LeadTimes(); len(days) != 1 {
+		t.Errorf("history = %v", j.History)
+	}
+	leaks := func(j *Journal, secret string) {
+		t.Helper()
+		for _, fn := range []string{defaultConfig, cacheFile} {
+			if data := readFile(t, filepath.Join(j.path, fn)); strings.Contains(data, secret) {
+				t.Errorf("%s holds '%s':\n%s", fn, secret, data)
+			}
+		}
+	}
+	leaks(j, "secret")
+	if !strings.Contains(readFile(t, filepath.Join(j.path, defaultConfig)), "plain plan") {
+		t.Error("config without the plain note")
+	}
+
+	// the encrypted note is read again on open
+	next, _ := reopen(t, j)
+	if done := next.Done["b.md.age"]; len(done) != 1 || !strings.Contains(done[0].Text, "secret plan") {
+		t.Errorf("done = %v", next.Done)
+	}
+	if topic := next.Meta["b.md.age"]["topic"]; topic != "secret topic" {
+		t.Errorf("meta = %v", next.Meta)
+	}
+	if days, _ := next.LeadTimes(); len(days) != 1 {
+		t.Errorf("history = %v", next.History)
+	}
+
+	// an encrypted note keeps its history, hashed
+	captureStdout(t, func() error { return next.Encrypt("a.md") })
+	if _, open := next.LeadTimes(); open != 1 {
+		t.Errorf("history = %v", next.History)
+	}
+	leaks(next, "plain plan")
+}
+
+func TestEditPlainCopy(t *testing.T) {
+	identity, recipient := fakeAge(t)
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": ageConfig(identity, recipient, `, "EncryptIndex": true`),
+		"a.md":          "- *TODO* secret plan\n",
+	})
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	j.Editor = filepath.Join(dir, "editor")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$1\" > %s/path\ncp -p \"$1\" %s/copy\nexit $EDITOR_EXIT\n", dir, dir)
+	if err := os.WriteFile(j.Editor, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the copy is removed whether the editor succeeds or fails
+	for _, exit := range []string{"0", "1"} {
+		t.Setenv("EDITOR_EXIT", exit)
+		if err := j.OpenIndex(); (err != nil) != (exit != "0") {
+			t.Errorf("exit %s: err = %v", exit, err)
+		}
+		copied := strings.TrimSpace(readFile(t, filepath.Join(dir, "path")))
+		if !strings.HasPrefix(copied, filepath.Join(j.path, editDir)+string(filepath.Separator)) {
+			t.Errorf("exit %s: copy at %s", exit, copied)
+		}
+		if st, err := os.Stat(filepath.Join(dir, "copy")); err != nil || st.Mode().Perm() != 0600 {
+			t.Errorf("exit %s: copy mode %v, %v", exit, st.Mode(), err)
+		} else if !strings.Contains(readFile(t, filepath.Join(dir, "copy")), "secret plan") {
+			t.Errorf("exit %s: copy without the index", exit)
+		}
+		if _, err := os.Stat(filepath.Join(j.path, editDir)); !os.IsNotExist(err) {
+			t.Errorf("exit %s: edit copy left: %v", exit, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package diary_test
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/senomas/diary"
+)
+
+func Example() {
+	dir, err := os.MkdirTemp("", "journal-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	note := "# Release\n\n- *DOING* write the changelog\n- *TODO:A* tag the release\n" +
+		"- *LATER* write the blog post\n- *WAITING* review from ops\n"
+	if err := os.WriteFile(filepath.Join(dir, "release.md"), []byte(note), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	j, err := diary.OpenJournal(dir, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer j.Close()
+	// the journal is not a git repository: leave the index uncommitted and
+	// the warning about it unlogged
+	j.SetNoCommit(true)
+	j.SetLog(io.Discard, diary.LogQuiet)
+	if err := j.ProcessAll(); err != nil {
+		log.Fatal(err)
+	}
+	for _, t := range j.Todos["release.md"] {
+		fmt.Printf("%s:%d %s %s\n", t.Path(), t.LineNo, t.Tag, t.Priority)
+	}
+	if err := j.Write(); err != nil {
+		log.Fatal(err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "index.md"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(string(index))
+	// Output:
+	// release.md:4 TODO A
+	// # DOING
+	//
+	// - *[DOING](release.md)* write the changelog _(Release)_
+	//
+	// # TODO
+	//
+	// - *[TODO:A](release.md)* tag the release _(Release)_
+	//
+	// # LATER
+	//
+	// - *[LATER](release.md)* write the blog post _(Release)_
+	//
+	// # WAITING
+	//
+	// - *[WAITING](release.md)* review from ops _(Release)_
+}
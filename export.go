@@ -0,0 +1,240 @@
+package diary
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// timeHeadingPattern matches the text of "## HH:MM:SS", "## HH:MM" and
+// "## now" headings. They get the time in TimeFormat as id, the anchor used
+// by the tag links in the index.
+var timeHeadingPattern = regexp.MustCompile(`^(?:(?i:now)|\d\d:\d\d(?::\d\d)?)$`)
+
+// noteTimeKey holds the modification time of the note being converted, the
+// time of its "## now" headings.
+var noteTimeKey = parser.NewContextKey()
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+{{- if .Nav}}
+<nav>
+{{- range .Nav}}
+<h2>{{.Year}}</h2>
+{{- range .Months}}
+<h3>{{.Month}}</h3>
+<ul>
+{{- range .Days}}
+<li><a href="{{.Href}}">{{.Day}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- end}}
+</nav>
+{{- end}}
+<main>
+{{.Body}}
+</main>
+</body>
+</html>
+`))
+
+type navDay struct {
+	Day  string
+	Href string
+}
+
+type navMonth struct {
+	Month string
+	Days  []navDay
+}
+
+type navYear struct {
+	Year   string
+	Months []navMonth
+}
+
+type page struct {
+	Title string
+	Nav   []navYear
+	Body  template.HTML
+}
+
+type exportTransformer struct {
+	journal *Journal
+}
+
+func (t exportTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	now, _ := pc.Get(noteTimeKey).(time.Time)
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch v := n.(type) {
+		case *ast.Link:
+			v.Destination = htmlLink(v.Destination)
+		case *ast.Heading:
+			if v.Lines().Len() > 0 {
+				line := v.Lines().At(0)
+				if h := strings.TrimSpace(string(line.Value(source))); timeHeadingPattern.MatchString(h) {
+					v.SetAttributeString("id", []byte(t.journal.headerTime(h, now)))
+				}
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func htmlLink(dest []byte) []byte {
+	d := string(dest)
+	if strings.Contains(d, "://") {
+		return dest
+	}
+	anchor := ""
+	if i := strings.Index(d, "#"); i >= 0 {
+		d, anchor = d[:i], d[i:]
+	}
+	if strings.HasSuffix(d, ".md") || strings.HasSuffix(d, ".md"+ageExt) {
+		return []byte(strings.TrimSuffix(strings.TrimSuffix(d, ageExt), ".md") + ".html" + anchor)
+	}
+	return dest
+}
+
+// convert renders the markdown of n.
+func convert(md goldmark.Markdown, n *Note, data []byte, w io.Writer) error {
+	pc := parser.NewContext()
+	pc.Set(noteTimeKey, n.modTime)
+	return md.Convert(data, w, parser.WithContext(pc))
+}
+
+func (j *Journal) newMarkdown() goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.Prioritized(exportTransformer{journal: j}, 100)),
+		),
+	)
+}
+
+func (j *Journal) diaryNav() []navYear {
+	var months []string
+	for m := range j.Diary {
+		months = append(months, m)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(months)))
+	var nav []navYear
+	for _, m := range months {
+		days := append([][]string(nil), j.Diary[m]...)
+		sort.Slice(days, func(a, b int) bool {
+			return days[a][0] > days[b][0]
+		})
+		nm := navMonth{Month: m}
+		for _, d := range days {
+			nm.Days = append(nm.Days, navDay{Day: d[0], Href: string(htmlLink([]byte(d[1])))})
+		}
+		year := m[:4]
+		if len(nav) == 0 || nav[len(nav)-1].Year != year {
+			nav = append(nav, navYear{Year: year})
+		}
+		nav[len(nav)-1].Months = append(nav[len(nav)-1].Months, nm)
+	}
+	return nav
+}
+
+func (j *Journal) exportPage(md goldmark.Markdown, outdir string, fn string, nav []navYear) error {
+	n, err := j.NewNote(fn)
+	if err != nil {
+		return err
+	}
+	data, err := n.read()
+	if err != nil {
+		return err
+	}
+	if j.isSplitIndex(fn) {
+		data = bytes.TrimPrefix(data, []byte(splitMarker))
+	}
+	var body bytes.Buffer
+	if err := convert(md, n, data, &body); err != nil {
+		return fmt.Errorf("error render '%s': %w", fn, err)
+	}
+	var out bytes.Buffer
+	err = pageTemplate.Execute(&out, page{Title: strings.TrimSuffix(strings.TrimSuffix(fn, ageExt), ".md"), Nav: nav, Body: template.HTML(body.String())})
+	if err != nil {
+		return fmt.Errorf("error render '%s': %w", fn, err)
+	}
+	ofn := filepath.Join(outdir, string(htmlLink([]byte(fn))))
+	if err := os.MkdirAll(filepath.Dir(ofn), os.ModePerm); err != nil {
+		return fmt.Errorf("error create path '%s': %w", filepath.Dir(ofn), err)
+	}
+	if err := ioutil.WriteFile(ofn, out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error write file '%s': %w", ofn, err)
+	}
+	return nil
+}
+
+func (j *Journal) ExportHTML(outdir string) error {
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	md := j.newMarkdown()
+	// with SplitIndex the combined index is gone, each section file gets
+	// the navigation instead
+	indexes := []string{j.indexPath()}
+	if j.SplitIndex {
+		indexes = nil
+		for _, s := range j.Sections {
+			indexes = append(indexes, j.resolve(j.splitIndex(s.Tag)))
+		}
+	}
+	nav := j.diaryNav()
+	for _, ff := range indexes {
+		index, err := filepath.Rel(j.path, ff)
+		if err != nil {
+			return fmt.Errorf("error resolve index file: %w", err)
+		}
+		if err := j.exportPage(md, outdir, j.indexFile(index), nav); err != nil {
+			return err
+		}
+	}
+	notes := make(map[string]bool)
+	for _, days := range j.Diary {
+		for _, d := range days {
+			notes[d[1]] = true
+		}
+	}
+	for _, kind := range j.reportKinds() {
+		for fn := range j.tagMap(kind) {
+			notes[fn] = true
+		}
+	}
+	var fns []string
+	for fn := range notes {
+		fns = append(fns, fn)
+	}
+	sort.Strings(fns)
+	for _, fn := range fns {
+		if err := j.exportPage(md, outdir, fn, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
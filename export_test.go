@@ -0,0 +1,167 @@
+package diary
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// readTree returns the content of every file below dir by relative path.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[rel] = readFile(t, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestExportHTMLIsDeterministic(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/01/2024-01-05.md": "# Note\n\n## 09:00:00\n- *TODO* write [plan](notes/plan.md)\n",
+		"2024/01/2024-01-06.md": "# Note\n\n## 08:30:00\n- *DOING* review\n",
+		"notes/plan.md":         "# Plan\n- *LATER* someday\n",
+	})
+	setNow(t, j, "2024-01-20T12:00:00Z")
+	j.NoCommit = true
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	first, second := t.TempDir(), t.TempDir()
+	if err := j.ExportHTML(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.ExportHTML(second); err != nil {
+		t.Fatal(err)
+	}
+	a, b := readTree(t, first), readTree(t, second)
+	if len(a) != 4 || len(a) != len(b) {
+		t.Fatalf("exported %d and %d files: %v", len(a), len(b), a)
+	}
+	for fn, html := range a {
+		if b[fn] != html {
+			t.Errorf("%s differs between exports", fn)
+		}
+	}
+	index := a["index.html"]
+	if !strings.Contains(index, `href="2024/01/2024-01-05.html#09:00:00"`) {
+		t.Errorf("index.html does not link the note anchor:\n%s", index)
+	}
+	if strings.Index(index, ">06<") > strings.Index(index, ">05<") {
+		t.Errorf("navigation not newest first:\n%s", index)
+	}
+	if note := a[filepath.Join("2024", "01", "2024-01-05.html")]; !strings.Contains(note, `id="09:00:00"`) || !strings.Contains(note, `href="notes/plan.html"`) {
+		t.Errorf("note page:\n%s", note)
+	}
+}
+
+var anchorLinkPattern = regexp.MustCompile(`href="([^"#]+)#([^"]+)"`)
+
+func TestExportLinksHaveAnchors(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/01/2024-01-05.md": "# Note\n\n## 09:00:00\n- *TODO* first\n\n## 10:15\n- *DOING* short header\n\n## 11:30:00\n- *WAITING* reply *TODO* and todo\n",
+		"2024/01/2024-01-06.md": "# Note\n\n## 08:30:00\n- *LATER* next day\n",
+	})
+	j.NoCommit = true
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	if err := j.ExportHTML(out); err != nil {
+		t.Fatal(err)
+	}
+	files := readTree(t, out)
+	links := anchorLinkPattern.FindAllStringSubmatch(files["index.html"], -1)
+	if len(links) != 7 {
+		t.Errorf("%d anchored links in the index:\n%s", len(links), files["index.html"])
+	}
+	for _, l := range links {
+		page, ok := files[filepath.FromSlash(l[1])]
+		if !ok {
+			t.Errorf("link to missing page %s", l[1])
+			continue
+		}
+		if !strings.Contains(page, `id="`+l[2]+`"`) {
+			t.Errorf("no anchor %s in %s:\n%s", l[2], l[1], page)
+		}
+	}
+}
+
+func TestExportCustomIndex(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/01/2024-01-05.md": "# Note\n\n## 09:00:00\n- *TODO* first\n",
+	})
+	setNow(t, j, "2024-01-20T12:00:00Z")
+	j.NoCommit = true
+	j.SetIndex("home.md")
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	if err := j.ExportHTML(out); err != nil {
+		t.Fatal(err)
+	}
+	files := readTree(t, out)
+	if home := files["home.html"]; !strings.Contains(home, `href="2024/01/2024-01-05.html#09:00:00"`) || !strings.Contains(home, "<nav>") {
+		t.Errorf("home.html:\n%s", home)
+	}
+	if _, ok := files["index.html"]; ok {
+		t.Errorf("exported the default index: %v", files)
+	}
+}
+
+func TestExportSplitIndex(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"SplitIndex": true}`,
+		"2024/01/2024-01-05.md": "## 09:00:00\n- *TODO* first\n- *DOING* now\n",
+	})
+	setNow(t, j, "2024-01-20T12:00:00Z")
+	j.NoCommit = true
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	if err := j.ExportHTML(out); err != nil {
+		t.Fatal(err)
+	}
+	files := readTree(t, out)
+	for fn, want := range map[string]string{
+		"doing.html":   `<a href="2024/01/2024-01-05.html#09:00:00">DOING</a>`,
+		"todo.html":    `<a href="2024/01/2024-01-05.html#09:00:00">TODO</a>`,
+		"later.html":   "<h1>LATER</h1>",
+		"waiting.html": "<h1>WAITING</h1>",
+	} {
+		page := files[fn]
+		if !strings.Contains(page, want) || !strings.Contains(page, `<nav>`) || !strings.Contains(page, `href="2024/01/2024-01-05.html">05</a>`) {
+			t.Errorf("%s without %q or the navigation:\n%s", fn, want, page)
+		}
+		if strings.Contains(page, "generated by diary") || strings.Contains(page, "raw HTML omitted") {
+			t.Errorf("%s shows the split marker:\n%s", fn, page)
+		}
+	}
+	if _, ok := files["index.html"]; ok {
+		t.Errorf("exported a combined index: %v", files)
+	}
+}
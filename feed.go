@@ -0,0 +1,99 @@
+package diary
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func noteTitle(data []byte, fallback string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if ms := headerPattern.FindStringSubmatch(scanner.Text()); ms != nil {
+			return strings.TrimSpace(ms[1])
+		}
+	}
+	return fallback
+}
+
+func (j *Journal) WriteFeed(w io.Writer, limit int) error {
+	var fns []string
+	for _, days := range j.Diary {
+		for _, d := range days {
+			fns = append(fns, d[1])
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(fns)))
+	if limit > 0 && len(fns) > limit {
+		fns = fns[:limit]
+	}
+	md := j.newMarkdown()
+	feed := atomFeed{Xmlns: "http://www.w3.org/2005/Atom", ID: "urn:diary:feed", Title: "Diary"}
+	for _, fn := range fns {
+		n, err := j.NewNote(fn)
+		if err != nil {
+			return err
+		}
+		data, err := n.read()
+		if err != nil {
+			return err
+		}
+		var body bytes.Buffer
+		if err := convert(md, n, data, &body); err != nil {
+			return fmt.Errorf("error render '%s': %w", fn, err)
+		}
+		published := n.Time.Format(time.RFC3339)
+		if feed.Updated == "" {
+			feed.Updated = published
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        "urn:diary:" + fn,
+			Title:     noteTitle(data, strings.TrimSuffix(strings.TrimSuffix(filepath.Base(fn), ageExt), ".md")),
+			Published: published,
+			Updated:   published,
+			Content:   atomContent{Type: "html", Body: body.String()},
+		})
+	}
+	if feed.Updated == "" {
+		feed.Updated = time.Unix(0, 0).UTC().Format(time.RFC3339)
+	}
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshal feed: %w", err)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("error write feed: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error write feed: %w", err)
+	}
+	return nil
+}
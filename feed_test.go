@@ -0,0 +1,57 @@
+package diary
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+func TestWriteFeed(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/03/2024-03-01.md": "# Friday\n\n## 09:00:00\nshipped *it*\n",
+		"2024/03/2024-03-02.md": "# Saturday\n",
+		"2024/02/2024-02-28.md": "no title here\n",
+	})
+	setNow(t, j, "2024-03-05T12:00:00Z")
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	var first, second bytes.Buffer
+	if err := j.WriteFeed(&first, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.WriteFeed(&second, 2); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Errorf("feed not deterministic:\n%s\n%s", first.String(), second.String())
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(first.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("entries = %v, want the 2 newest", feed.Entries)
+	}
+	if e := feed.Entries[0]; e.ID != "urn:diary:2024/03/2024-03-02.md" || e.Title != "Saturday" {
+		t.Errorf("first entry = %+v", e)
+	}
+	if e := feed.Entries[1]; e.Title != "Friday" || e.Content.Type != "html" || e.Content.Body != "<h1>Friday</h1>\n<h2 id=\"09:00:00\">09:00:00</h2>\n<p>shipped <em>it</em></p>\n" {
+		t.Errorf("second entry = %+v", e)
+	}
+	if feed.Updated != feed.Entries[0].Published {
+		t.Errorf("updated %s, newest entry %s", feed.Updated, feed.Entries[0].Published)
+	}
+
+	var all bytes.Buffer
+	if err := j.WriteFeed(&all, 0); err != nil {
+		t.Fatal(err)
+	}
+	feed = atomFeed{}
+	if err := xml.Unmarshal(all.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Entries) != 3 || feed.Entries[2].Title != "2024-02-28" {
+		t.Errorf("entries without limit = %+v", feed.Entries)
+	}
+}
@@ -0,0 +1,38 @@
+package diary
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+func (n *Note) frontMatter(data []byte) (map[string]string, int) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return nil, 0
+	}
+	meta := make(map[string]string)
+	var invalid []int
+	lineNo := 1
+	for scanner.Scan() {
+		lineNo++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "---" || text == "..." {
+			for _, l := range invalid {
+				n.journal.warnf("invalid front matter in '%s' line %d", n.Path, l)
+			}
+			return meta, lineNo
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		kv := strings.SplitN(text, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			invalid = append(invalid, lineNo)
+			continue
+		}
+		meta[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), `"'`)
+	}
+	n.journal.warnf("unterminated front matter in '%s'", n.Path)
+	return nil, 0
+}
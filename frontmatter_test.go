@@ -0,0 +1,36 @@
+package diary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFrontMatter(t *testing.T) {
+	j, _, log := newTestJournal(t, map[string]string{
+		"with.md":      "---\ntitle: \"Plan\"\n# comment\ntags: work, q1\n---\n- *TODO* after front matter\n",
+		"without.md":   "title: not meta\n- *TODO* plain\n",
+		"malformed.md": "---\ntitle: ok\nno colon here\n---\n- *TODO* kept\n",
+		"open.md":      "---\ntitle: never closed\n- *TODO* still parsed\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if m := j.Meta["with.md"]; len(m) != 2 || m["title"] != "Plan" || m["tags"] != "work, q1" {
+		t.Errorf("meta = %v", m)
+	}
+	if got := lineNos(j.Todos, "with.md"); len(got) != 1 || got[0] != 6 {
+		t.Errorf("todo lines = %v, want [6]", got)
+	}
+	if _, ok := j.Meta["without.md"]; ok || len(j.Todos["without.md"]) != 1 {
+		t.Errorf("note without front matter: meta %v, todos %v", j.Meta["without.md"], j.Todos["without.md"])
+	}
+	if m := j.Meta["malformed.md"]; len(m) != 1 || m["title"] != "ok" || len(j.Todos["malformed.md"]) != 1 {
+		t.Errorf("malformed: meta %v, todos %v", m, j.Todos["malformed.md"])
+	}
+	if !strings.Contains(log.String(), "invalid front matter in 'malformed.md' line 3") {
+		t.Errorf("log = %q", log)
+	}
+	if _, ok := j.Meta["open.md"]; ok || len(j.Todos["open.md"]) != 1 || !strings.Contains(log.String(), "unterminated front matter in 'open.md'") {
+		t.Errorf("unterminated: meta %v, todos %v, log %q", j.Meta["open.md"], j.Todos["open.md"], log)
+	}
+}
@@ -0,0 +1,32 @@
+package diary
+
+import "fmt"
+
+// GC compacts the journal repository with git gc --aggressive, followed by
+// a full repack when repack is set. It refuses to run while a rebase or
+// merge is in progress.
+func (j *Journal) GC(repack bool) error {
+	if !j.hasRepo() {
+		return fmt.Errorf("'%s' is not a git repository", j.path)
+	}
+	if rebase, err := j.git.RebaseInProgress(); err != nil {
+		return err
+	} else if rebase {
+		return fmt.Errorf("rebase in progress, finish or abort it before gc")
+	}
+	if merge, err := j.git.MergeInProgress(); err != nil {
+		return err
+	} else if merge {
+		return fmt.Errorf("merge in progress, finish or abort it before gc")
+	}
+	if j.dryRun {
+		return nil
+	}
+	if err := j.git.GC(true); err != nil {
+		return err
+	}
+	if repack {
+		return j.git.Repack()
+	}
+	return nil
+}
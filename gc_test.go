@@ -0,0 +1,58 @@
+package diary
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGC(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	if err := j.GC(false); err != nil {
+		t.Fatal(err)
+	}
+	if got := git.called("gc"); len(got) != 1 || got[0] != "gc --aggressive" || len(git.called("repack")) != 0 {
+		t.Errorf("calls = %q", git.calls)
+	}
+	if err := j.GC(true); err != nil {
+		t.Fatal(err)
+	}
+	if got := git.called("repack"); len(got) != 1 || got[0] != "repack -a -d" {
+		t.Errorf("calls = %q", git.calls)
+	}
+}
+
+func TestGCRefusesDuringRebaseOrMerge(t *testing.T) {
+	for _, tc := range []struct {
+		rebasing, merging bool
+		want              string
+	}{
+		{true, false, "rebase in progress"},
+		{false, true, "merge in progress"},
+	} {
+		j, git, _ := newTestJournal(t, nil)
+		git.rebasing, git.merging = tc.rebasing, tc.merging
+		if err := j.GC(true); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("err = %v, want %s", err, tc.want)
+		}
+		if len(git.called("gc")) != 0 || len(git.called("repack")) != 0 {
+			t.Errorf("calls = %q", git.calls)
+		}
+	}
+}
+
+func TestGCErrors(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	git.errs = map[string]error{"gc": errors.New("error run git gc: exit status 128")}
+	if err := j.GC(true); err == nil || err.Error() != "error run git gc: exit status 128" {
+		t.Errorf("err = %v", err)
+	}
+	if len(git.called("repack")) != 0 {
+		t.Errorf("repack after a failed gc: %q", git.calls)
+	}
+	j, git, _ = newTestJournal(t, nil)
+	git.noRepo = true
+	if err := j.GC(false); err == nil || !strings.Contains(err.Error(), "is not a git repository") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package diary
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type GitRunner interface {
+	Init() error
+	IsRepo() bool
+	Add(paths ...string) error
+	Status(paths ...string) (string, error)
+	RevParse(rev string) (string, error)
+	Commit(message, name, email string) error
+	Pull(rebase bool) error
+	Push() error
+	LsFiles(args ...string) (string, error)
+	Diff(args ...string) (string, error)
+	Move(src, dst string) error
+	RebaseInProgress() (bool, error)
+	RebaseAbort() error
+	MergeInProgress() (bool, error)
+	GC(aggressive bool) error
+	Repack() error
+	LastMessage() (string, error)
+	Remotes() ([]string, error)
+	Unpushed() (int, bool, error)
+	RemoveCached(path string) error
+	ResetSoft(rev string) error
+}
+
+type execGit struct {
+	dir string
+	log *logger
+}
+
+func (g *execGit) command(args ...string) *exec.Cmd {
+	g.log.logf(LogVerbose, "git %s", strings.Join(args, " "))
+	return exec.Command("git", append([]string{"-C", g.dir}, args...)...)
+}
+
+func gitError(name string, err error, stderr bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("error run git %s: %w\n%s", name, err, msg)
+	}
+	return fmt.Errorf("error run git %s: %w", name, err)
+}
+
+// interactive shows the output of git unless quiet. Its stderr ends up in
+// the returned error on failure and is logged otherwise, never both.
+func (g *execGit) interactive(name string, args ...string) error {
+	cmd := g.command(args...)
+	var stderr bytes.Buffer
+	cmd.Stdin = os.Stdin
+	if g.log.enabled(LogNormal) {
+		cmd.Stdout = os.Stdout
+	}
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return gitError(name, err, stderr)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		g.log.logf(LogNormal, "%s", msg)
+	}
+	return nil
+}
+
+// progress streams the progress git writes to stderr, so the error does not
+// repeat it.
+func (g *execGit) progress(name string, args ...string) error {
+	cmd := g.command(args...)
+	cmd.Stdin = os.Stdin
+	if g.log.enabled(LogNormal) {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error run git %s: %w", name, err)
+	}
+	return nil
+}
+
+func (g *execGit) quiet(name string, args ...string) error {
+	cmd := g.command(args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return gitError(name, err, stderr)
+	}
+	return nil
+}
+
+func (g *execGit) output(name string, args ...string) (string, error) {
+	cmd := g.command(args...)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", gitError(name, err, stderr)
+	}
+	return out.String(), nil
+}
+
+func (g *execGit) Init() error {
+	return g.quiet("init", "init")
+}
+
+func (g *execGit) IsRepo() bool {
+	out, err := g.output("rev-parse", "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+func (g *execGit) Add(paths ...string) error {
+	return g.interactive("add", append([]string{"add"}, paths...)...)
+}
+
+func (g *execGit) Status(paths ...string) (string, error) {
+	return g.output("status", append([]string{"status", "--porcelain", "--"}, paths...)...)
+}
+
+func (g *execGit) RevParse(rev string) (string, error) {
+	return g.output("rev-parse", "rev-parse", rev)
+}
+
+// Commit commits as name and email when given, the git config otherwise.
+func (g *execGit) Commit(message, name, email string) error {
+	var args []string
+	if name != "" {
+		args = append(args, "-c", "user.name="+name)
+	}
+	if email != "" {
+		args = append(args, "-c", "user.email="+email)
+	}
+	return g.interactive("commit", append(args, "commit", "-m", message)...)
+}
+
+func (g *execGit) Pull(rebase bool) error {
+	args := []string{"pull"}
+	if rebase {
+		args = append(args, "--rebase")
+	} else {
+		args = append(args, "--no-rebase")
+	}
+	return g.quiet("pull", args...)
+}
+
+func (g *execGit) Push() error {
+	return g.quiet("push", "push")
+}
+
+func (g *execGit) LsFiles(args ...string) (string, error) {
+	return g.output("ls-files", append([]string{"ls-files"}, args...)...)
+}
+
+func (g *execGit) Diff(args ...string) (string, error) {
+	return g.output("diff", append([]string{"diff"}, args...)...)
+}
+
+func (g *execGit) Move(src, dst string) error {
+	return g.interactive("mv", "mv", src, dst)
+}
+
+func (g *execGit) RebaseInProgress() (bool, error) {
+	return g.gitPathExists("rebase state", "rebase-merge", "rebase-apply")
+}
+
+func (g *execGit) MergeInProgress() (bool, error) {
+	return g.gitPathExists("merge state", "MERGE_HEAD")
+}
+
+// gitPathExists reports whether any of the given paths inside the git
+// directory exist.
+func (g *execGit) gitPathExists(what string, paths ...string) (bool, error) {
+	for _, p := range paths {
+		out, err := g.output("rev-parse", "rev-parse", "--git-path", p)
+		if err != nil {
+			return false, err
+		}
+		path := strings.TrimSpace(out)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(g.dir, path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return true, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return false, fmt.Errorf("error check %s: %w", what, err)
+		}
+	}
+	return false, nil
+}
+
+func (g *execGit) RebaseAbort() error {
+	return g.quiet("rebase --abort", "rebase", "--abort")
+}
+
+// GC and Repack forward the git progress output to stderr.
+func (g *execGit) GC(aggressive bool) error {
+	args := []string{"gc"}
+	if aggressive {
+		args = append(args, "--aggressive")
+	}
+	return g.progress("gc", args...)
+}
+
+func (g *execGit) Repack() error {
+	return g.progress("repack", "repack", "-a", "-d")
+}
+
+func (g *execGit) LastMessage() (string, error) {
+	return g.output("log", "log", "-1", "--format=%B")
+}
+
+func (g *execGit) Remotes() ([]string, error) {
+	out, err := g.output("remote", "remote")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// Unpushed counts the commits on HEAD missing from its upstream branch, and
+// reports false when there is no upstream.
+func (g *execGit) Unpushed() (int, bool, error) {
+	if _, err := g.output("rev-parse", "rev-parse", "--abbrev-ref", "@{u}"); err != nil {
+		return 0, false, nil
+	}
+	out, err := g.output("rev-list", "rev-list", "--count", "@{u}..HEAD")
+	if err != nil {
+		return 0, true, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return 0, true, fmt.Errorf("error parse git rev-list count '%s': %w", strings.TrimSpace(out), err)
+	}
+	return n, true, nil
+}
+
+// RemoveCached stops tracking path, leaving the file in the work tree.
+func (g *execGit) RemoveCached(path string) error {
+	return g.quiet("rm", "rm", "--cached", "-q", "--", path)
+}
+
+func (g *execGit) ResetSoft(rev string) error {
+	return g.quiet("reset", "reset", "--soft", rev)
+}
@@ -0,0 +1,454 @@
+package diary
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGit is a GitRunner returning canned output. Every call is recorded as
+// its git subcommand and arguments, e.g. "commit -m 2024-01-02 10:00:00".
+type fakeGit struct {
+	mu       sync.Mutex
+	calls    []string
+	noRepo   bool
+	head     string
+	status   string
+	diff     string
+	others   string
+	staged   string
+	modified string
+	// tracked are the files ls-files lists when asked for them by path
+	tracked  []string
+	message  string
+	remotes  []string
+	ahead    int
+	upstream bool
+	rebasing bool
+	merging  bool
+	// errs fails the named subcommand, e.g. "pull"
+	errs map[string]error
+	// onPull runs on Pull, to simulate a pull that stops on conflicts
+	onPull func(g *fakeGit)
+	// onPush runs on Push, to simulate a slow push
+	onPush func()
+}
+
+func (g *fakeGit) record(name string, args ...string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls = append(g.calls, strings.TrimSpace(name+" "+strings.Join(args, " ")))
+	return g.errs[name]
+}
+
+// called returns the recorded calls of the named subcommand.
+func (g *fakeGit) called(name string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var calls []string
+	for _, c := range g.calls {
+		if c == name || strings.HasPrefix(c, name+" ") {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (g *fakeGit) Init() error {
+	return g.record("init")
+}
+
+func (g *fakeGit) IsRepo() bool {
+	return !g.noRepo
+}
+
+func (g *fakeGit) Add(paths ...string) error {
+	return g.record("add", paths...)
+}
+
+func (g *fakeGit) Status(paths ...string) (string, error) {
+	return g.status, g.record("status", paths...)
+}
+
+func (g *fakeGit) RevParse(rev string) (string, error) {
+	head := g.head
+	if head == "" {
+		head = "0123456789abcdef0123456789abcdef01234567"
+	}
+	return head + "\n", g.record("rev-parse", rev)
+}
+
+func (g *fakeGit) Commit(message, name, email string) error {
+	args := []string{"-m", message}
+	if name != "" {
+		args = append([]string{"-c", "user.name=" + name}, args...)
+	}
+	if email != "" {
+		args = append([]string{"-c", "user.email=" + email}, args...)
+	}
+	if err := g.record("commit", args...); err != nil {
+		return err
+	}
+	g.message = message
+	return nil
+}
+
+func (g *fakeGit) Pull(rebase bool) error {
+	err := g.record("pull", fmt.Sprintf("rebase=%t", rebase))
+	if g.onPull != nil {
+		g.onPull(g)
+	}
+	return err
+}
+
+func (g *fakeGit) Push() error {
+	if g.onPush != nil {
+		g.onPush()
+	}
+	return g.record("push")
+}
+
+func (g *fakeGit) LsFiles(args ...string) (string, error) {
+	err := g.record("ls-files", args...)
+	switch {
+	case hasToken(args, "--others"):
+		return g.others, err
+	case hasToken(args, "-s"):
+		return g.staged, err
+	case hasToken(args, "-m"):
+		return g.modified, err
+	case hasToken(args, "--"):
+		var out strings.Builder
+		for _, fn := range args {
+			if hasToken(g.tracked, fn) {
+				out.WriteString(fn + "\n")
+			}
+		}
+		return out.String(), err
+	}
+	return "", err
+}
+
+func (g *fakeGit) Diff(args ...string) (string, error) {
+	return g.diff, g.record("diff", args...)
+}
+
+func (g *fakeGit) Move(src, dst string) error {
+	return g.record("mv", src, dst)
+}
+
+func (g *fakeGit) RebaseInProgress() (bool, error) {
+	return g.rebasing, nil
+}
+
+func (g *fakeGit) RebaseAbort() error {
+	if err := g.record("rebase", "--abort"); err != nil {
+		return err
+	}
+	g.rebasing = false
+	return nil
+}
+
+func (g *fakeGit) MergeInProgress() (bool, error) {
+	return g.merging, nil
+}
+
+func (g *fakeGit) GC(aggressive bool) error {
+	if aggressive {
+		return g.record("gc", "--aggressive")
+	}
+	return g.record("gc")
+}
+
+func (g *fakeGit) Repack() error {
+	return g.record("repack", "-a", "-d")
+}
+
+func (g *fakeGit) LastMessage() (string, error) {
+	return g.message + "\n", g.record("log")
+}
+
+func (g *fakeGit) Remotes() ([]string, error) {
+	return g.remotes, g.record("remote")
+}
+
+func (g *fakeGit) Unpushed() (int, bool, error) {
+	return g.ahead, g.upstream, g.record("rev-list")
+}
+
+func (g *fakeGit) RemoveCached(path string) error {
+	return g.record("rm", "--cached", path)
+}
+
+func (g *fakeGit) ResetSoft(rev string) error {
+	return g.record("reset", "--soft", rev)
+}
+
+func TestPushAbortsConflictingRebase(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	git.errs = map[string]error{"pull": errors.New("error run git pull: exit status 1\nCONFLICT (content): Merge conflict in a.md")}
+	git.onPull = func(g *fakeGit) { g.rebasing = true }
+	err := j.Push()
+	if err == nil || !strings.Contains(err.Error(), "stopped on conflicts and was aborted") || !strings.Contains(err.Error(), "CONFLICT (content)") {
+		t.Errorf("err = %v", err)
+	}
+	if calls := git.called("rebase"); len(calls) != 1 || calls[0] != "rebase --abort" {
+		t.Errorf("rebase calls = %q", calls)
+	}
+	if git.rebasing || len(git.called("push")) != 0 {
+		t.Errorf("rebasing %t, calls %q", git.rebasing, git.calls)
+	}
+}
+
+func TestPushReportsFailedAbort(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	git.errs = map[string]error{"pull": errors.New("conflict"), "rebase": errors.New("abort failed")}
+	git.rebasing = true
+	if err := j.Push(); err == nil || !strings.Contains(err.Error(), "could not be aborted") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestPushPullFailureWithoutRebase(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	git.errs = map[string]error{"pull": errors.New("network down")}
+	if err := j.Push(); err == nil || err.Error() != "network down" {
+		t.Errorf("err = %v", err)
+	}
+	if len(git.called("rebase")) != 0 || len(git.called("push")) != 0 {
+		t.Errorf("calls = %q", git.calls)
+	}
+}
+
+func TestPushNoRebase(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	j.SetNoRebase(true)
+	if err := j.Push(); err != nil {
+		t.Fatal(err)
+	}
+	if calls := git.called("pull"); len(calls) != 1 || calls[0] != "pull rebase=false" || len(git.called("push")) != 1 {
+		t.Errorf("calls = %q", git.calls)
+	}
+}
+
+// realGit returns a git runner on a new repository in a temporary
+// directory, with the identity of the user config hidden.
+func realGit(t *testing.T) (*execGit, *bytes.Buffer) {
+	t.Helper()
+	hideGitIdentity(t)
+	var log bytes.Buffer
+	g := &execGit{dir: t.TempDir(), log: &logger{out: &log, level: LogNormal}}
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return g, &log
+}
+
+// hideGitIdentity skips the test without git and points git at an empty
+// user config that refuses to guess an identity.
+func hideGitIdentity(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	home := t.TempDir()
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tuseConfigOnly = true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	for _, v := range []string{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL", "EMAIL"} {
+		t.Setenv(v, "")
+		os.Unsetenv(v)
+	}
+}
+
+// captureStderr returns what fn writes to stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	done := make(chan string)
+	go func() {
+		var out bytes.Buffer
+		out.ReadFrom(r)
+		done <- out.String()
+	}()
+	fn()
+	os.Stderr = stderr
+	w.Close()
+	return <-done
+}
+
+func TestGitErrorShownOnce(t *testing.T) {
+	g, log := realGit(t)
+	if err := os.WriteFile(filepath.Join(g.dir, "a.md"), []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Add("."); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	stderr := captureStderr(t, func() {
+		err = g.Commit("test", "", "")
+	})
+	if err == nil {
+		t.Fatal("commit without an identity succeeded")
+	}
+	if !strings.Contains(err.Error(), "error run git commit") || !strings.Contains(err.Error(), "user.email") {
+		t.Errorf("err = %v", err)
+	}
+	if strings.Contains(stderr, "user.email") || strings.Contains(log.String(), "user.email") {
+		t.Errorf("git message shown besides the error:\n%s%s", stderr, log)
+	}
+}
+
+func TestCommitWithoutPush(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{".journal.json": `{"Timezone": "UTC", "AutoPush": false}`})
+	setNow(t, j, "2024-03-01T09:00:00Z")
+	git.head = "2222222"
+	git.status = " M a.md\n"
+	if err := j.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := git.called("commit"); len(got) != 1 || got[0] != "commit -m 2024-03-01 09:00:00" {
+		t.Errorf("commit calls = %q", got)
+	}
+	if len(git.called("add")) == 0 || len(git.called("push")) != 0 || len(git.called("pull")) != 0 {
+		t.Errorf("calls = %q", git.calls)
+	}
+	if j.Hash != "2222222" || !strings.Contains(readFile(t, j.configPath()), `"Hash": "2222222"`) {
+		t.Errorf("hash %s, config:\n%s", j.Hash, readFile(t, j.configPath()))
+	}
+}
+
+func TestCommitCleanTree(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	if err := j.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(git.called("commit")) != 0 || j.Hash != "" {
+		t.Errorf("calls %q, hash %s", git.calls, j.Hash)
+	}
+}
+
+func TestCommitIdentity(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{".journal.json": `{"Timezone": "UTC", "AuthorName": "Diary Bot", "AuthorEmail": "bot@example.com"}`})
+	setNow(t, j, "2024-03-01T09:00:00Z")
+	git.status = " M a.md\n"
+	if err := j.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := git.called("commit"); len(got) != 1 || got[0] != "commit -c user.email=bot@example.com -c user.name=Diary Bot -m 2024-03-01 09:00:00" {
+		t.Errorf("commit calls = %q", got)
+	}
+}
+
+func TestGitCommitIdentity(t *testing.T) {
+	g, _ := realGit(t)
+	if err := os.WriteFile(filepath.Join(g.dir, "a.md"), []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g.log.level = LogQuiet
+	if err := g.Add("."); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Commit("test", "Diary Bot", "bot@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := g.output("log", "log", "-1", "--format=%an <%ae>|%cn <%ce>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out); got != "Diary Bot <bot@example.com>|Diary Bot <bot@example.com>" {
+		t.Errorf("identity = %q", got)
+	}
+}
+
+func TestWarnUnpushed(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		config   string
+		status   string
+		ahead    int
+		upstream bool
+		want     string
+	}{
+		{"ahead", `{}`, " M a.md\n", 3, true, "3 unpushed commits; run `diary push`"},
+		{"pushed", `{}`, " M a.md\n", 0, true, ""},
+		{"no upstream", `{}`, " M a.md\n", 3, false, ""},
+		{"auto push", `{"AutoPush": true}`, " M a.md\n", 3, true, ""},
+		{"nothing committed", `{}`, "", 3, true, ""},
+	} {
+		j, git, log := newTestJournal(t, map[string]string{".journal.json": c.config})
+		git.status = c.status
+		git.ahead = c.ahead
+		git.upstream = c.upstream
+		git.remotes = []string{"origin"}
+		if err := j.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(log.String()); !strings.Contains(got, c.want) || (c.want == "" && strings.Contains(got, "unpushed")) {
+			t.Errorf("%s: log %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestGitUnpushed(t *testing.T) {
+	g, _ := realGit(t)
+	g.log.level = LogQuiet
+	commit := func(fn string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(g.dir, fn), []byte(fn+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Add("."); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Commit(fn, "Diary Bot", "bot@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit("a.md")
+	if ahead, upstream, err := g.Unpushed(); err != nil || upstream || ahead != 0 {
+		t.Errorf("without upstream: %d, %t, %v", ahead, upstream, err)
+	}
+
+	remote := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare: %v\n%s", err, out)
+	}
+	for _, args := range [][]string{{"remote", "add", "origin", remote}, {"push", "-q", "-u", "origin", "HEAD"}} {
+		if err := g.quiet(args[0], args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit("b.md")
+	commit("c.md")
+	if ahead, upstream, err := g.Unpushed(); err != nil || !upstream || ahead != 2 {
+		t.Errorf("with upstream: %d, %t, %v", ahead, upstream, err)
+	}
+
+	var err error
+	out := captureStdout(t, func() error {
+		err = Doctor(g.dir, "", true)
+		return nil
+	})
+	if !strings.Contains(out, "ok   git upstream\n") || !strings.Contains(out, "FAIL everything pushed: 2 commits not pushed\n     run 'diary push'\n") || err == nil {
+		t.Errorf("doctor: %v\n%s", err, out)
+	}
+}
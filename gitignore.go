@@ -0,0 +1,92 @@
+package diary
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoredFiles are the journal files .gitignore must list: the lock, the
+// cache, the plain copies of encrypted files being edited and, when
+// CommitConfig is off, the config.
+func (j *Journal) ignoredFiles() []string {
+	files := []string{lockFile, cacheFile, editDir + "/"}
+	if !j.CommitConfig && !filepath.IsAbs(j.config) {
+		files = append(files, filepath.ToSlash(j.config))
+	}
+	return files
+}
+
+// gitignore returns the content of .gitignore and the paths it lists.
+func (j *Journal) gitignore() ([]byte, map[string]bool, error) {
+	data, err := ioutil.ReadFile(filepath.Join(j.path, ".gitignore"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("error read .gitignore: %w", err)
+	}
+	present := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		present[strings.TrimPrefix(strings.TrimSpace(line), "/")] = true
+	}
+	return data, present, nil
+}
+
+// ensureGitignore adds the missing ignoredFiles to .gitignore, keeping what
+// is already there, and returns the lines it added.
+func (j *Journal) ensureGitignore() ([]string, error) {
+	data, present, err := j.gitignore()
+	if err != nil {
+		return nil, err
+	}
+	var added []string
+	for _, f := range j.ignoredFiles() {
+		if !present[f] {
+			added = append(added, f)
+		}
+	}
+	if len(added) == 0 || j.dryRun {
+		return added, nil
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	data = append(data, strings.Join(added, "\n")+"\n"...)
+	if err := ioutil.WriteFile(filepath.Join(j.path, ".gitignore"), data, 0644); err != nil {
+		return nil, fmt.Errorf("error write .gitignore: %w", err)
+	}
+	return added, nil
+}
+
+// Fix brings .gitignore up to date with ignoredFiles and, when CommitConfig
+// is off, stops tracking a config committed before, then commits. The
+// config stays on disk either way.
+func (j *Journal) Fix() error {
+	added, err := j.ensureGitignore()
+	if err != nil {
+		return err
+	}
+	for _, f := range added {
+		fmt.Printf("add %s to .gitignore\n", f)
+	}
+	if !j.hasRepo() {
+		return nil
+	}
+	if !j.CommitConfig && !filepath.IsAbs(j.config) {
+		out, err := j.git.LsFiles("--", j.config)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(out) != "" {
+			fmt.Printf("untrack %s\n", j.config)
+			if j.dryRun {
+				return nil
+			}
+			if err := j.git.RemoveCached(j.config); err != nil {
+				return err
+			}
+		}
+	}
+	return j.Commit()
+}
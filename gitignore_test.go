@@ -0,0 +1,105 @@
+package diary
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitJournal opens a journal over a real git repository holding files,
+// none of them committed yet.
+func gitJournal(t *testing.T, files map[string]string) (*Journal, *execGit) {
+	t.Helper()
+	g, _ := realGit(t)
+	g.log.level = LogQuiet
+	if err := g.quiet("commit", "-c", "user.name=Diary Bot", "-c", "user.email=bot@example.com", "commit", "-q", "--allow-empty", "-m", "init"); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, g.dir, files)
+	j, err := OpenJournal(g.dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		j.Close()
+	})
+	j.SetGit(g)
+	j.SetLog(&bytes.Buffer{}, LogNormal)
+	return j, g
+}
+
+// tracked returns the files of the last commit.
+func tracked(t *testing.T, g *execGit) string {
+	t.Helper()
+	out, err := g.output("ls-tree", "ls-tree", "-r", "--name-only", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(strings.Fields(out), " ")
+}
+
+func TestCommitConfig(t *testing.T) {
+	for _, c := range []struct {
+		config    string
+		gitignore string
+		want      string
+	}{
+		{`{"CommitConfig": false}`, "", "a.md index.json index.md"},
+		{`{"CommitConfig": false}`, lockFile + "\n" + cacheFile + "\n" + editDir + "/\n.journal.json\n", ".gitignore a.md index.json index.md"},
+		{`{}`, "", ".journal.json a.md index.json index.md"},
+	} {
+		files := map[string]string{".journal.json": c.config, "a.md": "- *TODO* a\n"}
+		if c.gitignore != "" {
+			files[".gitignore"] = c.gitignore
+		}
+		j, g := gitJournal(t, files)
+		j.AuthorName, j.AuthorEmail = "Diary Bot", "bot@example.com"
+		if err := j.ProcessAll(); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Write(); err != nil {
+			t.Fatal(err)
+		}
+		if got := tracked(t, g); got != c.want {
+			t.Errorf("%s with .gitignore %q: committed %s, want %s", c.config, c.gitignore, got, c.want)
+		}
+		// the lock, the cache and the config are not left staged either
+		if staged, err := g.output("diff", "diff", "--cached", "--name-only"); err != nil || staged != "" {
+			t.Errorf("%s: staged %q, %v", c.config, staged, err)
+		}
+	}
+}
+
+func TestFix(t *testing.T) {
+	j, g := gitJournal(t, map[string]string{
+		".journal.json": `{"AuthorName": "Diary Bot", "AuthorEmail": "bot@example.com"}`,
+		".gitignore":    "drafts/\n/" + lockFile,
+		"a.md":          "- *TODO* a\n",
+	})
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if got := tracked(t, g); !strings.Contains(got, ".journal.json") {
+		t.Fatalf("config not committed: %s", got)
+	}
+
+	// keeping the config local ignores and untracks it, without removing it
+	j.CommitConfig = false
+	out := captureStdout(t, j.Fix)
+	if want := "add " + cacheFile + " to .gitignore\nadd " + editDir + "/ to .gitignore\nadd .journal.json to .gitignore\nuntrack .journal.json\n"; out != want {
+		t.Errorf("fix:\n%s\nwant:\n%s", out, want)
+	}
+	if got, want := readFile(t, filepath.Join(j.path, ".gitignore")), "drafts/\n/"+lockFile+"\n"+cacheFile+"\n"+editDir+"/\n.journal.json\n"; got != want {
+		t.Errorf(".gitignore:\n%q\nwant:\n%q", got, want)
+	}
+	if got := tracked(t, g); strings.Contains(got, ".journal.json") {
+		t.Errorf("config still tracked: %s", got)
+	}
+	readFile(t, filepath.Join(j.path, ".journal.json"))
+
+	// a second run has nothing to do
+	if out := captureStdout(t, j.Fix); out != "" {
+		t.Errorf("second fix:\n%s", out)
+	}
+}
@@ -1,3 +1,10 @@
 module github.com/senomas/diary
 
-go 1.18
+go 1.23
+
+require (
+	github.com/fsnotify/fsnotify v1.10.1
+	github.com/yuin/goldmark v1.8.6
+)
+
+require golang.org/x/sys v0.13.0
@@ -0,0 +1,132 @@
+package diary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TagHistory is when a task was first seen with an open tag, TODO or any
+// other kind but DONE, and when its line was first seen DONE.
+type TagHistory struct {
+	Created   time.Time
+	Completed *time.Time `json:",omitempty"`
+}
+
+// historyKey names a task by its note, without the archive prefix so
+// archiving keeps the history, and the words of its line other than the
+// tags and the list or checkbox marker. For an encrypted note the words
+// are hashed, History being kept in the plain config.
+func historyKey(path, body string) string {
+	if strings.HasSuffix(path, ageExt) && body != "" {
+		sum := sha256.Sum256([]byte(body))
+		body = hex.EncodeToString(sum[:])
+	}
+	return strings.TrimPrefix(filepath.ToSlash(path), archiveDir+"/") + "|" + body
+}
+
+// recordHistory updates History from the freshly parsed tags of n. A new
+// open task is created at its tag time, the time header above it, and
+// completed when its DONE is first seen. Open tasks gone from the note are
+// dropped, completed ones are kept for LeadTimes.
+func (n *Note) recordHistory(tags []Tag) {
+	j := n.journal
+	if j.History == nil {
+		j.History = make(map[string]TagHistory)
+	}
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		key := historyKey(n.Path, t.body)
+		seen[key] = true
+		h, ok := j.History[key]
+		if t.Tag != "DONE" {
+			if !ok || h.Completed != nil {
+				j.History[key] = TagHistory{Created: t.Time}
+			}
+		} else if ok && h.Completed == nil {
+			now := j.now()
+			h.Completed = &now
+			j.History[key] = h
+		}
+	}
+	prefix := historyKey(n.Path, "")
+	for key, h := range j.History {
+		if h.Completed == nil && !seen[key] && strings.HasPrefix(key, prefix) {
+			delete(j.History, key)
+		}
+	}
+}
+
+// moveHistory re-keys the History of the note src to dst, so a moved note
+// keeps the creation times of its tasks. The words of a plain note are
+// hashed when it moves to an encrypted one.
+func (j *Journal) moveHistory(src, dst string) {
+	from, to := historyKey(src, ""), historyKey(dst, "")
+	if from == to {
+		return
+	}
+	encrypted := strings.HasSuffix(src, ageExt)
+	for key, h := range j.History {
+		if body, ok := strings.CutPrefix(key, from); ok {
+			delete(j.History, key)
+			if encrypted {
+				j.History[to+body] = h
+			} else {
+				j.History[historyKey(dst, body)] = h
+			}
+		}
+	}
+}
+
+// forgetHistory drops the open tasks of the note fn from History, as a
+// note gone does not hold them open. Completed tasks are kept for LeadTimes.
+func (j *Journal) forgetHistory(fn string) {
+	prefix := historyKey(fn, "")
+	for key, h := range j.History {
+		if h.Completed == nil && strings.HasPrefix(key, prefix) {
+			delete(j.History, key)
+		}
+	}
+}
+
+// LeadTimes returns the days from creation to completion of every completed
+// task in History, shortest first, and the number of tasks still open.
+func (j *Journal) LeadTimes() ([]float64, int) {
+	var days []float64
+	open := 0
+	for _, h := range j.History {
+		if h.Completed == nil {
+			open++
+			continue
+		}
+		days = append(days, h.Completed.Sub(h.Created).Hours()/24)
+	}
+	sort.Float64s(days)
+	return days, open
+}
+
+func (j *Journal) PrintLeadTime() error {
+	days, open := j.LeadTimes()
+	var average, median float64
+	if len(days) > 0 {
+		for _, d := range days {
+			average += d
+		}
+		average /= float64(len(days))
+		median = days[len(days)/2]
+		if len(days)%2 == 0 {
+			median = (days[len(days)/2-1] + median) / 2
+		}
+	}
+	if j.json {
+		return writeResult(LeadTimeResult{Done: len(days), Open: open, AverageDays: average, MedianDays: median})
+	}
+	fmt.Printf("done: %d tasks, %d open\n", len(days), open)
+	fmt.Printf("average lead time: %.1f days\n", average)
+	fmt.Printf("median lead time: %.1f days\n", median)
+	return nil
+}
@@ -0,0 +1,142 @@
+package diary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeadTimes(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"Timezone": "UTC"}`,
+		"2024/03/2024-03-01.md": "# Note\n\n## 09:00:00\n- *TODO* write report\n- *TODO* call bob\n- *TODO* still open\n",
+	})
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-01T09:00:00Z")
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the history lives in the config, so the creation times survive a
+	// rebuild from a fresh journal
+	j, _ = reopen(t, j)
+	j.SetNoCommit(true)
+	writeFiles(t, j.path, map[string]string{"2024/03/2024-03-01.md": "# Note\n\n## 09:00:00\n- *DONE* write report\n- *TODO* call bob\n- *TODO* still open\n"})
+	setNow(t, j, "2024-03-04T09:00:00Z")
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, j.path, map[string]string{"2024/03/2024-03-01.md": "# Note\n\n## 09:00:00\n- *DONE* write report\n- [x] *TODO* call bob\n- *TODO* still open\n"})
+	setNow(t, j, "2024-03-02T21:00:00Z")
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	days, open := j.LeadTimes()
+	if fmt.Sprint(days) != "[1.5 3]" || open != 1 {
+		t.Fatalf("lead times %v, open %d", days, open)
+	}
+
+	j.SetJSON(true)
+	out := captureStdout(t, j.PrintLeadTime)
+	for _, want := range []string{`"Done": 2`, `"Open": 1`, `"AverageDays": 2.25`, `"MedianDays": 2.25`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("json without %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestHistoryDropsRemovedTasks(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{"a.md": "- *TODO* gone soon\n- *TODO* stays\n"})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, j.path, map[string]string{"a.md": "- *TODO* stays\n"})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, open := j.LeadTimes(); open != 1 || len(j.History) != 1 {
+		t.Errorf("open %d, history %v", open, j.History)
+	}
+}
+
+func TestHistoryDropsDeletedNotes(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		"a.md": "- *TODO* open in a gone note\n- *DONE* done long ago\n",
+		"b.md": "- *TODO* still open\n",
+	})
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, j.path, map[string]string{"a.md": "- *DONE* open in a gone note\n- *DONE* done long ago\n- *TODO* dropped with the note\n"})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if days, open := j.LeadTimes(); len(days) != 1 || open != 2 {
+		t.Fatalf("before delete: %v done, %d open", days, open)
+	}
+	if err := os.Remove(filepath.Join(j.path, "a.md")); err != nil {
+		t.Fatal(err)
+	}
+	j.Hash = "1111111"
+	git.diff = "D\ta.md\n"
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	days, open := j.LeadTimes()
+	if len(days) != 1 || open != 1 {
+		t.Errorf("after delete: %v done, %d open, history %v", days, open, j.History)
+	}
+}
+
+func TestHistoryFollowsMove(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"Timezone": "UTC"}`,
+		"2024/03/2024-03-01.md": "## 09:00:00\n- *TODO* moved along\n",
+	})
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-01T09:00:00Z")
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	setNow(t, j, "2024-03-05T09:00:00Z")
+	captureStdout(t, func() error { return j.Move("2024/03/2024-03-01.md", "2024/03/2024-03-02.md") })
+	h, ok := j.History[historyKey("2024/03/2024-03-02.md", "moved along")]
+	if len(j.History) != 1 || !ok || h.Created.Format(time.RFC3339) != "2024-03-01T09:00:00Z" {
+		t.Errorf("history = %v", j.History)
+	}
+	if _, open := j.LeadTimes(); open != 1 {
+		t.Errorf("open = %d", open)
+	}
+}
+
+func TestHistoryFollowsGitRename(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{"a.md": "- *TODO* renamed outside diary\n"})
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	created := j.History[historyKey("a.md", "renamed outside diary")].Created
+	if err := os.Rename(filepath.Join(j.path, "a.md"), filepath.Join(j.path, "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	// the renamed note is touched, a new task would be created at this time
+	later := created.Add(48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(j.path, "b.md"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	j.Hash = "1111111"
+	git.diff = "R100\ta.md\tb.md\n"
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if h, ok := j.History[historyKey("b.md", "renamed outside diary")]; len(j.History) != 1 || !ok || !h.Created.Equal(created) {
+		t.Errorf("history = %v", j.History)
+	}
+}
@@ -0,0 +1,68 @@
+package diary
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// HourHistogram counts the time headers of every diary note, archived ones
+// included, by hour of day. Headers are written in the configured Timezone,
+// so the hour is taken as is.
+func (j *Journal) HourHistogram() ([24]int, error) {
+	var hours [24]int
+	err := j.walkTree(true, func(n *Note) error {
+		if n.Type != Diary {
+			return nil
+		}
+		data, err := n.read()
+		if err != nil {
+			return err
+		}
+		fenced := false
+		for _, line := range strings.Split(string(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "```") {
+				fenced = !fenced
+			}
+			if ms := mdTimePattern.FindStringSubmatch(line); ms != nil && !fenced {
+				if h, err := strconv.Atoi(n.headerTime(ms[1])[:2]); err == nil && h < 24 {
+					hours[h]++
+				}
+			}
+		}
+		return nil
+	})
+	return hours, err
+}
+
+func (j *Journal) PrintHours() error {
+	hours, err := j.HourHistogram()
+	if err != nil {
+		return err
+	}
+	if j.json {
+		result := []HourResult{}
+		for h, c := range hours {
+			result = append(result, HourResult{Hour: h, Count: c})
+		}
+		return writeResult(result)
+	}
+	largest := 0
+	for _, c := range hours {
+		if c > largest {
+			largest = c
+		}
+	}
+	t := j.newTable("HOUR", "ENTRIES", "")
+	t.alignRight(1)
+	for h, c := range hours {
+		bar := 0
+		if largest > 0 {
+			bar = c * 40 / largest
+		}
+		t.add(fmt.Sprintf("%02d", h), strconv.Itoa(c), strings.Repeat("#", bar))
+	}
+	return t.write(os.Stdout)
+}
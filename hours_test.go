@@ -0,0 +1,46 @@
+package diary
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHourHistogram(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":                 `{"Timezone": "Asia/Jakarta"}`,
+		"2024/03/2024-03-01.md":         "# Note\n## 08:15:00\n## 08:45\n## 23:59:59\n```\n## 08:00:00\n```\n",
+		"2024/03/2024-03-02.md":         "## 00:00:00\r\n## now\r\n",
+		"archive/2023/01/2023-01-01.md": "## 08:00:00\n",
+		"notes/plan.md":                 "## 08:00:00\n",
+	})
+	written := time.Date(2024, 3, 2, 7, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(j.path, "2024/03/2024-03-02.md"), written, written); err != nil {
+		t.Fatal(err)
+	}
+	hours, err := j.HourHistogram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// now is the modification time, 14:30 in Jakarta
+	want := [24]int{0: 1, 8: 3, 14: 1, 23: 1}
+	if hours != want {
+		t.Errorf("hours = %v, want %v", hours, want)
+	}
+}
+
+func TestPrintHours(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/03/2024-03-01.md": "## 08:00:00\n## 08:30:00\n## 21:00:00\n",
+	})
+	out := captureStdout(t, j.PrintHours)
+	lines := strings.Split(out, "\n")
+	if len(lines) != 26 || lines[0] != "HOUR  ENTRIES" {
+		t.Fatalf("output:\n%s", out)
+	}
+	if lines[9] != "08          2  "+strings.Repeat("#", 40) || lines[22] != "21          1  "+strings.Repeat("#", 20) || lines[1] != "00          0" {
+		t.Errorf("output:\n%s", out)
+	}
+}
@@ -0,0 +1,59 @@
+package diary
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matchGlob matches a slash separated path against pattern, where "**"
+// matches any number of path elements and the other elements follow
+// path.Match.
+func matchGlob(pattern, name string) bool {
+	return matchParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func (j *Journal) validateIgnore() error {
+	for _, p := range j.Ignore {
+		for _, part := range strings.Split(p, "/") {
+			if _, err := path.Match(part, ""); err != nil {
+				return fmt.Errorf("invalid pattern '%s' in Ignore: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ignored reports whether the journal relative path fn matches one of the
+// Ignore patterns.
+func (j *Journal) ignored(fn string) bool {
+	fn = filepath.ToSlash(fn)
+	for _, p := range j.Ignore {
+		if matchGlob(p, fn) {
+			return true
+		}
+	}
+	return false
+}
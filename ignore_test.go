@@ -0,0 +1,66 @@
+package diary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"drafts/**", "drafts/a.md", true},
+		{"drafts/**", "drafts/x/y/a.md", true},
+		{"drafts/**", "notes/drafts/a.md", false},
+		{"**/drafts/**", "notes/drafts/a.md", true},
+		{"**/*.tmp.md", "a.tmp.md", true},
+		{"**/*.tmp.md", "x/y/a.tmp.md", true},
+		{"templates/*.md", "templates/a.md", true},
+		{"templates/*.md", "templates/x/a.md", false},
+		{"a.md", "b/a.md", false},
+	} {
+		if got := matchGlob(tc.pattern, tc.name); got != tc.want {
+			t.Errorf("matchGlob(%q, %q) = %t, want %t", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIgnoreExcludesDrafts(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		".journal.json":     `{"Ignore": ["drafts/**"]}`,
+		"a.md":              "- *TODO* kept\n",
+		"drafts/b.md":       "- *TODO* draft\n",
+		"drafts/deep/c.md":  "- *TODO* deep draft\n",
+		"notes/drafts/d.md": "- *TODO* not a top level draft\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Todos) != 2 || j.Todos["a.md"] == nil || j.Todos["notes/drafts/d.md"] == nil {
+		t.Errorf("todos after ProcessAll = %v", j.Todos)
+	}
+	if index := renderIndex(t, j); strings.Contains(index, "* draft") || strings.Contains(index, "deep draft") {
+		t.Errorf("index:\n%s", index)
+	}
+
+	// changes under drafts are skipped as well
+	j.Hash = "1111111"
+	writeFiles(t, j.path, map[string]string{"drafts/e.md": "- *TODO* new draft\n", "e.md": "- *TODO* new\n"})
+	git.others = "drafts/e.md\ne.md\n"
+	git.diff = "M\tdrafts/b.md\n"
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Todos) != 3 || j.Todos["e.md"] == nil {
+		t.Errorf("todos after ProcessChanges = %v", j.Todos)
+	}
+}
+
+func TestInvalidIgnorePattern(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".journal.json": `{"Ignore": ["drafts/[a"]}`})
+	if _, err := OpenJournal(dir, ""); err == nil || !strings.Contains(err.Error(), "invalid pattern 'drafts/[a' in Ignore") {
+		t.Errorf("err = %v", err)
+	}
+}
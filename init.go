@@ -0,0 +1,49 @@
+package diary
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Init creates the journal directory as a new git repository with a default
+// config and commits it. An existing config is never overwritten.
+func (j *Journal) Init() error {
+	if _, err := os.Stat(j.configPath()); err == nil {
+		return fmt.Errorf("journal already initialized, '%s' exists", j.configPath())
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error open config file: %w", err)
+	}
+	j.AutoPush = false
+	if j.dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(j.path, os.ModePerm); err != nil {
+		return fmt.Errorf("error create path '%s': %w", j.path, err)
+	}
+	if err := j.git.Init(); err != nil {
+		return err
+	}
+	if err := j.writeConfig(); err != nil {
+		return err
+	}
+	// the config marks the journal initialized, so it goes again when the
+	// commit fails, to let init run once more
+	if err := j.commitInit(); err != nil {
+		if rerr := os.Remove(j.configPath()); rerr != nil {
+			return fmt.Errorf("%w, and error remove config: %v", err, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (j *Journal) commitInit() error {
+	if _, err := j.ensureGitignore(); err != nil {
+		return err
+	}
+	if err := j.git.Add(j.commitPaths()...); err != nil {
+		return err
+	}
+	return j.git.Commit("init journal", j.AuthorName, j.AuthorEmail)
+}
@@ -0,0 +1,68 @@
+package diary
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initJournal opens a journal on a directory that does not exist yet, with
+// real git and its log in a buffer.
+func initJournal(t *testing.T) *Journal {
+	t.Helper()
+	hideGitIdentity(t)
+	t.Setenv("EDITOR", "nano")
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := &logger{out: &bytes.Buffer{}, level: LogNormal}
+	j.log = log
+	j.SetGit(&execGit{dir: j.path, log: log})
+	return j
+}
+
+func TestInit(t *testing.T) {
+	j := initJournal(t)
+	j.AuthorName, j.AuthorEmail = "Diary Bot", "bot@example.com"
+	if err := j.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(j.path, ".git")); err != nil {
+		t.Errorf("no repository: %v", err)
+	}
+	config := readFile(t, filepath.Join(j.path, ".journal.json"))
+	if !strings.Contains(config, `"Editor": "nano"`) || !strings.Contains(config, `"AutoPush": false`) {
+		t.Errorf("config:\n%s", config)
+	}
+	if ignore := readFile(t, filepath.Join(j.path, ".gitignore")); !strings.Contains(ignore, lockFile+"\n") {
+		t.Errorf(".gitignore:\n%s", ignore)
+	}
+	out, err := exec.Command("git", "-C", j.path, "log", "--format=%s", "--name-only").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out); !strings.HasPrefix(got, "init journal\n") || !strings.Contains(got, ".journal.json") || !strings.Contains(got, ".gitignore") {
+		t.Errorf("initial commit:\n%s", got)
+	}
+	if err := j.Init(); err == nil || !strings.Contains(err.Error(), "journal already initialized") {
+		t.Errorf("second init: %v", err)
+	}
+}
+
+func TestInitRemovesConfigWhenCommitFails(t *testing.T) {
+	j := initJournal(t)
+	if err := j.Init(); err == nil || !strings.Contains(err.Error(), "error run git commit") {
+		t.Fatalf("init without an identity: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(j.path, ".journal.json")); !os.IsNotExist(err) {
+		t.Errorf("config left behind: %v", err)
+	}
+	j.AuthorName, j.AuthorEmail = "Diary Bot", "bot@example.com"
+	if err := j.Init(); err != nil {
+		t.Errorf("init again: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package diary
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type indexEntry struct {
+	Path     string `json:"path"`
+	LineNo   int    `json:"line"`
+	Tag      string `json:"tag"`
+	Priority string `json:"priority,omitempty"`
+	Section  string `json:"section,omitempty"`
+	Time     string `json:"time"`
+	Text     string `json:"text"`
+}
+
+// indexJSON holds the entries of every section under its tag kind in plural,
+// e.g. "todos", in section order, followed by the front matter of the notes.
+type indexJSON struct {
+	sections []indexSection
+	notes    map[string]map[string]string
+}
+
+type indexSection struct {
+	key     string
+	entries []indexEntry
+}
+
+func (ix indexJSON) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	field := func(key string, v interface{}) error {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(data)
+		return nil
+	}
+	for _, s := range ix.sections {
+		if err := field(s.key, s.entries); err != nil {
+			return nil, err
+		}
+	}
+	if len(ix.notes) > 0 {
+		if err := field("notes", ix.notes); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func indexEntries(tagMap map[string][]Tag) []indexEntry {
+	entries := []indexEntry{}
+	for path, tags := range tagMap {
+		for _, t := range tags {
+			entries = append(entries, indexEntry{
+				Path:     path,
+				LineNo:   t.LineNo,
+				Tag:      t.Tag,
+				Priority: t.Priority,
+				Section:  t.Section,
+				Time:     t.Time.Format(time.RFC3339),
+				Text:     t.Text,
+			})
+		}
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].Path != entries[b].Path {
+			return entries[a].Path < entries[b].Path
+		}
+		return entries[a].LineNo < entries[b].LineNo
+	})
+	return entries
+}
+
+func (j *Journal) WriteJSON(w io.Writer) error {
+	ix := indexJSON{notes: j.Meta}
+	seen := make(map[string]bool)
+	for _, s := range j.Sections {
+		if !seen[s.Tag] {
+			seen[s.Tag] = true
+			ix.sections = append(ix.sections, indexSection{key: strings.ToLower(s.Tag) + "s", entries: indexEntries(j.tagMap(s.Tag))})
+		}
+	}
+	data, err := json.MarshalIndent(ix, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshal json: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error write json: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package diary
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const defaultDiaryLayout = "{yyyy}/{mm}/{yyyy}-{mm}-{dd}.md"
+
+var layoutFormats = map[string]string{"yyyy": "2006", "mm": "01", "dd": "02"}
+var layoutDigits = map[string]string{"yyyy": `(\d{4})`, "mm": `(\d{2})`, "dd": `(\d{2})`}
+
+func (j *Journal) diaryLayout() string {
+	if j.DiaryLayout == "" {
+		return defaultDiaryLayout
+	}
+	return j.DiaryLayout
+}
+
+// compileDiaryLayout checks DiaryLayout and derives the pattern matching
+// diary paths. A placeholder may repeat, every occurrence is captured and
+// diaryDate requires them to agree.
+func (j *Journal) compileDiaryLayout() error {
+	layout := j.diaryLayout()
+	if !strings.HasSuffix(layout, ".md") {
+		return fmt.Errorf("invalid DiaryLayout '%s' in config, expected a path ending in .md", layout)
+	}
+	if filepath.IsAbs(layout) || strings.HasPrefix(layout, "/") || strings.HasPrefix(layout, archiveDir+"/") {
+		return fmt.Errorf("invalid DiaryLayout '%s' in config, expected a path relative to the journal outside %s", layout, archiveDir)
+	}
+	for _, part := range strings.Split(layout, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid DiaryLayout '%s' in config, expected a path relative to the journal outside %s", layout, archiveDir)
+		}
+	}
+	var re strings.Builder
+	var groups []string
+	re.WriteString("^")
+	last := 0
+	for _, loc := range linkPlaceholderPattern.FindAllStringSubmatchIndex(layout, -1) {
+		name := layout[loc[2]:loc[3]]
+		digits, ok := layoutDigits[name]
+		if !ok {
+			return fmt.Errorf("unknown placeholder '%s' in DiaryLayout, expected {yyyy}, {mm} or {dd}", layout[loc[0]:loc[1]])
+		}
+		re.WriteString(regexp.QuoteMeta(layout[last:loc[0]]))
+		re.WriteString(digits)
+		groups = append(groups, name)
+		last = loc[1]
+	}
+	re.WriteString(regexp.QuoteMeta(layout[last:]))
+	re.WriteString("$")
+	for name := range layoutDigits {
+		if !hasToken(groups, name) {
+			return fmt.Errorf("invalid DiaryLayout '%s' in config, missing {%s}", layout, name)
+		}
+	}
+	j.layoutRe = regexp.MustCompile(re.String())
+	j.layoutGroups = groups
+	return nil
+}
+
+// diaryPath is the path of the diary note of day t.
+func (j *Journal) diaryPath(t time.Time) string {
+	return linkPlaceholderPattern.ReplaceAllStringFunc(j.diaryLayout(), func(p string) string {
+		return t.Format(layoutFormats[p[1:len(p)-1]])
+	})
+}
+
+// diaryDate returns the day of the diary note at fn, a slash separated
+// path relative to the journal, and false when fn does not follow the
+// layout.
+func (j *Journal) diaryDate(fn string) (time.Time, bool) {
+	ms := j.layoutRe.FindStringSubmatch(filepath.ToSlash(fn))
+	if ms == nil {
+		return time.Time{}, false
+	}
+	parts := make(map[string]string)
+	for i, name := range j.layoutGroups {
+		if v, ok := parts[name]; ok && v != ms[i+1] {
+			return time.Time{}, false
+		}
+		parts[name] = ms[i+1]
+	}
+	d, err := time.ParseInLocation("2006-01-02", fmt.Sprintf("%s-%s-%s", parts["yyyy"], parts["mm"], parts["dd"]), j.location())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return d, true
+}
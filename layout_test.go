@@ -0,0 +1,75 @@
+package diary
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiaryLayouts(t *testing.T) {
+	day := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		layout, path string
+		others       []string
+	}{
+		{"{yyyy}-{mm}-{dd}.md", "2024-03-04.md", []string{"2024/03/2024-03-04.md", "2024-03-4.md"}},
+		{"{yyyy}/{yyyy}-{mm}-{dd}.md", "2024/2024-03-04.md", []string{"2023/2024-03-04.md", "2024/03/2024-03-04.md"}},
+		{"journal/{yyyy}/{mm}/{dd}.md", "journal/2024/03/04.md", []string{"2024/03/04.md"}},
+	} {
+		j, _, _ := newTestJournal(t, map[string]string{
+			".journal.json": fmt.Sprintf(`{"Timezone": "UTC", "DiaryLayout": %q}`, tc.layout),
+		})
+		if got := j.diaryPath(day); got != tc.path {
+			t.Errorf("%s: diaryPath = %s, want %s", tc.layout, got, tc.path)
+		}
+		if d, ok := j.diaryDate(tc.path); !ok || !d.Equal(startOfDay(day)) {
+			t.Errorf("%s: diaryDate(%s) = %v, %t", tc.layout, tc.path, d, ok)
+		}
+		for _, fn := range tc.others {
+			if _, ok := j.diaryDate(fn); ok {
+				t.Errorf("%s: diaryDate(%s) matched", tc.layout, fn)
+			}
+		}
+	}
+}
+
+func TestFlatLayoutDiary(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"Timezone": "UTC", "DiaryLayout": "{yyyy}-{mm}-{dd}.md"}`,
+		"2024-03-01.md": "# Note 2024-03-01\n\n## 09:00:00\n- *TODO* flat\n",
+	})
+	j.SetNoEdit(true)
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-04T09:30:00Z")
+	if err := j.CreateDiary(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(j.path, "2024-03-04.md")); !strings.HasPrefix(got, "# Note 2024-03-04\n") {
+		t.Errorf("new day = %q", got)
+	}
+	if days := fmt.Sprint(j.Diary["2024-03"]); days != "[[01 2024-03-01.md] [04 2024-03-04.md]]" {
+		t.Errorf("diary = %s", days)
+	}
+	if todos := j.Todos["2024-03-01.md"]; len(todos) != 1 || todos[0].Time.Format(time.RFC3339) != "2024-03-01T09:00:00Z" {
+		t.Errorf("todos = %v", todos)
+	}
+}
+
+func TestInvalidDiaryLayout(t *testing.T) {
+	for _, layout := range []string{
+		"{yyyy}/{mm}/{dd}.txt",
+		"/abs/{yyyy}-{mm}-{dd}.md",
+		"archive/{yyyy}-{mm}-{dd}.md",
+		"../{yyyy}-{mm}-{dd}.md",
+		"{yyyy}-{mm}.md",
+		"{yyyy}-{mm}-{day}.md",
+	} {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{".journal.json": fmt.Sprintf(`{"DiaryLayout": %q}`, layout)})
+		if _, err := OpenJournal(dir, ""); err == nil {
+			t.Errorf("%s: no error", layout)
+		}
+	}
+}
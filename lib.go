@@ -1,4 +1,4 @@
-package main
+package diary
 
 import (
 	"bufio"
@@ -6,29 +6,109 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
-var dpattern = regexp.MustCompile(`^(\d\d\d\d)/(\d\d)/(\d\d\d\d)-(\d\d)-(\d\d)\.md$`)
-var mdTimePattern = regexp.MustCompile(`^##\s+(\d\d:\d\d:\d\d)\s*$`)
+var mdTimePattern = regexp.MustCompile(`^##\s+((?i:now)|\d\d:\d\d(?::\d\d)?)\s*$`)
 var headerPattern = regexp.MustCompile(`^#+\s+(.*)`)
+var duePattern = regexp.MustCompile(`^@due:(\S+)$`)
+var wakePattern = regexp.MustCompile(`^@wake:(\S+)$`)
+var projectPattern = regexp.MustCompile(`^\+([A-Za-z0-9_][\w-]*)[:,.;!?]*$`)
+var contextPattern = regexp.MustCompile(`^@([A-Za-z][\w-]*)[,.;!?]*$`)
+var checkboxPattern = regexp.MustCompile(`^\s*[-*+] \[([ xX])\]\s`)
+var listItemPattern = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s`)
 
 type Journal struct {
-	path   string
-	Hash   string
-	Editor string
-	Doings map[string][]Tag
-	Todos  map[string][]Tag
-	Laters map[string][]Tag
-	Diary  map[string][][]string
+	path          string
+	months        int
+	git           GitRunner
+	pushDone      chan error
+	dryRun        bool
+	strict        bool
+	noRebase      bool
+	lock          *os.File
+	cache         *tagCache
+	tagRe         *regexp.Regexp
+	hashtagRe     *regexp.Regexp
+	config        string
+	index         string
+	loc           *time.Location
+	repo          *bool
+	plain         bool
+	added         map[string]int
+	since         time.Time
+	until         time.Time
+	rangeNotes    bool
+	diaryOnly     bool
+	depth         int
+	committed     bool
+	log           *logger
+	noCommit      bool
+	decrypt       *bool
+	json          bool
+	noEdit        bool
+	layoutRe      *regexp.Regexp
+	layoutGroups  []string
+	notifier      Notifier
+	clock         func() time.Time
+	Hash          string
+	Editor        string
+	EditorArgs    []string
+	NvimSocket    string
+	Priorities    []string
+	TagStyle      string
+	AutoPush      bool
+	NoCommit      bool
+	CommitMessage string
+	TimeFormat    string
+	TitleFormat   string
+	Inbox         string
+	AuthorName    string
+	AuthorEmail   string
+	WatchDebounce int
+	MultilineTags bool
+	Workers       int
+	MaxPerSection int
+	GroupByDay    bool
+	IndexOrder    string
+	DiaryLayout   string
+	Notifier      string
+	StaleDays     int
+	MaxDepth      int
+	SplitIndex    bool
+	CommitConfig  bool
+	DiaryMonths   int
+	Timezone      string
+	Sections      []Section
+	CustomTags    []string
+	LinkFormats   map[string]string
+	AgeIdentity   string
+	AgeRecipients []string
+	EncryptDiary  bool
+	EncryptIndex  bool
+	Ignore        []string
+	IndexRaw      bool
+	Doings        map[string][]Tag
+	Todos         map[string][]Tag
+	Laters        map[string][]Tag
+	Waitings      map[string][]Tag
+	Done          map[string][]Tag
+	Custom        map[string]map[string][]Tag
+	Diary         map[string][][]string
+	Meta          map[string]map[string]string
+	Links         map[string][]string
+	History       map[string]TagHistory
 }
 
 type NoteType int8
@@ -43,14 +123,42 @@ type Note struct {
 	Path    string
 	Type    NoteType
 	Time    time.Time
+	Meta    map[string]string
+	Links   []string
+	// fresh is set when the tags were parsed rather than read from the cache
+	fresh bool
+	// unordered are the out-of-order time headers found by scan
+	unordered []string
+	// modTime is when the file was last written, the time of its "## now"
+	// headers
+	modTime time.Time
 }
 
 type Tag struct {
-	note   *Note
-	Time   time.Time
-	LineNo int
-	Tag    string
-	Text   string
+	note     *Note
+	Time     time.Time
+	LineNo   int
+	Tag      string
+	Priority string
+	Section  string
+	Due      *time.Time `json:",omitempty"`
+	Wake     *time.Time `json:",omitempty"`
+	Task     bool       `json:",omitempty"`
+	Checked  bool       `json:",omitempty"`
+	Projects []string   `json:",omitempty"`
+	Contexts []string   `json:",omitempty"`
+	Text     string
+	// Raw is Text with the indentation and spacing of the source line, set
+	// only when they differ
+	Raw string `json:",omitempty"`
+	// Depth is the list nesting level of the tag line, 0 at the top
+	Depth int `json:",omitempty"`
+	// Parent is the line of the list item enclosing the tag line, 0 at the
+	// top
+	Parent int `json:",omitempty"`
+	// body is the line without its tags and list marker, the task of
+	// History; it is not cached
+	body string
 }
 
 type TagCount struct {
@@ -72,378 +180,1692 @@ func (tc TagCounts) Swap(i, j int) {
 	tc[i], tc[j] = tc[j], tc[i]
 }
 
-func OpenJournal(path string) *Journal {
-	journal := Journal{path: path, Editor: "lvim", Doings: make(map[string][]Tag), Todos: make(map[string][]Tag), Laters: make(map[string][]Tag)}
-	file, err := os.Open(filepath.Join(path, ".journal.json"))
+func ExpandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error resolve home dir: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("error resolve path '%s': %w", path, err)
+	}
+	return abs, nil
+}
+
+const (
+	defaultConfig = ".journal.json"
+	defaultIndex  = "index.md"
+)
+
+// OpenJournal loads the journal at path. An empty config uses the default
+// ".journal.json"; a relative config is resolved against the journal path.
+func OpenJournal(path string, config string) (*Journal, error) {
+	path, err := ExpandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return loadJournal(path, config, newLogger())
+}
+
+// OpenLockedJournal is OpenJournal holding the lock, taken before the config
+// is read so a process that waited for it sees what the previous one wrote.
+// The journal directory is created when missing.
+func OpenLockedJournal(path string, config string) (*Journal, error) {
+	path, err := ExpandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("error create path '%s': %w", path, err)
+	}
+	f, err := lockDir(path)
+	if err != nil {
+		return nil, err
+	}
+	journal, err := loadJournal(path, config, newLogger())
+	if err != nil {
+		unlockFile(f)
+		f.Close()
+		return nil, err
+	}
+	journal.lock = f
+	return journal, nil
+}
+
+func loadJournal(path string, config string, log *logger) (*Journal, error) {
+	if config == "" {
+		config = defaultConfig
+	}
+	defaults := func() Journal {
+		return Journal{path: path, config: config, index: defaultIndex, log: log, git: &execGit{dir: path, log: log}, Editor: defaultEditor(), Priorities: []string{"A", "B", "C"}, DiaryMonths: 3, TagStyle: "asterisk", CommitConfig: true, Sections: defaultSections(), Doings: make(map[string][]Tag), Todos: make(map[string][]Tag), Laters: make(map[string][]Tag), Waitings: make(map[string][]Tag), Done: make(map[string][]Tag), Custom: make(map[string]map[string][]Tag), Meta: make(map[string]map[string]string), Links: make(map[string][]string), History: make(map[string]TagHistory)}
+	}
+	journal := defaults()
+	file, err := os.Open(journal.configPath())
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
-			panic(fmt.Sprintf("error open config file %+v", err))
+			return nil, fmt.Errorf("error open config file: %w", err)
 		}
 	} else {
 		defer file.Close()
 		data, err := ioutil.ReadAll(file)
 		if err != nil {
-			panic(fmt.Sprintf("error open config file %+v", err))
+			return nil, fmt.Errorf("error read config file: %w", err)
+		}
+		if err := json.Unmarshal(data, &journal); err != nil {
+			// a corrupt config is kept aside and the index rebuilt from
+			// the notes, as the defaults have no Hash
+			bad := journal.configPath() + ".bad"
+			if werr := writeFileAtomic(bad, data); werr != nil {
+				return nil, fmt.Errorf("error parse config file: %w", err)
+			}
+			journal = defaults()
+			journal.warnf("invalid config file '%s', saved as '%s' and using defaults: %v", config, filepath.Base(bad), err)
 		}
-		json.Unmarshal(data, &journal)
 	}
-	cmd := exec.Command("git", "-C", path, "push")
-	err = cmd.Start()
-	if err != nil {
-		panic(fmt.Sprintf("error run git push %#v\n", err))
+	switch journal.TagStyle {
+	case "asterisk", "hashtag", "both":
+	default:
+		return nil, fmt.Errorf("unknown TagStyle '%s' in config, expected asterisk, hashtag or both", journal.TagStyle)
+	}
+	switch journal.TimeFormat {
+	case "", "HH:MM:SS", "HH:MM":
+	default:
+		return nil, fmt.Errorf("unknown TimeFormat '%s' in config, expected HH:MM:SS or HH:MM", journal.TimeFormat)
+	}
+	if err := journal.compileDiaryLayout(); err != nil {
+		return nil, err
 	}
-	return &journal
+	if err := validateNotifier(journal.Notifier); err != nil {
+		return nil, err
+	}
+	if journal.MaxDepth < 0 {
+		return nil, fmt.Errorf("invalid MaxDepth %d in config, expected 0 for unlimited or more", journal.MaxDepth)
+	}
+	if err := journal.validateSections(); err != nil {
+		return nil, err
+	}
+	if err := journal.validateIgnore(); err != nil {
+		return nil, err
+	}
+	if (journal.EncryptDiary || journal.EncryptIndex) && len(journal.AgeRecipients) == 0 {
+		return nil, fmt.Errorf("EncryptDiary and EncryptIndex need AgeRecipients in config")
+	}
+	if journal.EncryptIndex && journal.AgeIdentity == "" {
+		return nil, fmt.Errorf("EncryptIndex needs AgeIdentity in config, to tell an unchanged index")
+	}
+	if journal.Timezone != "" {
+		loc, err := time.LoadLocation(journal.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown Timezone '%s' in config: %w", journal.Timezone, err)
+		}
+		journal.loc = loc
+	}
+	journal.compileTagPatterns()
+	if journal.Hash != "" {
+		if err := journal.loadEncrypted(); err != nil {
+			return nil, err
+		}
+	}
+	return &journal, nil
 }
 
-func (j *Journal) Commit() {
-	cmd := exec.Command("git", "-C", j.path, "add", ".")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	if err != nil {
-		panic(fmt.Sprintf("error run git add %#v\n", err))
+// hasRepo reports whether the journal is a git repository. Without one the
+// journal runs local only: the index is still written but nothing is
+// committed or pushed, which is warned about once.
+func (j *Journal) hasRepo() bool {
+	if j.repo == nil {
+		repo := j.git.IsRepo()
+		j.repo = &repo
+		if !repo {
+			j.warnf("'%s' is not a git repository, changes are not committed", j.path)
+		}
 	}
-	cmd = exec.Command("git", "-C", j.path, "status", "--porcelain")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err = cmd.Run()
+	return *j.repo
+}
+
+func (j *Journal) StartAutoPush() error {
+	if !j.AutoPush || j.dryRun || !j.hasRepo() {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- j.git.Push()
+	}()
+	j.pushDone = done
+	return nil
+}
+
+func (j *Journal) waitPush() error {
+	if j.pushDone == nil {
+		return nil
+	}
+	done := j.pushDone
+	j.pushDone = nil
+	return <-done
+}
+
+// Close waits for the background push, saves pending cache entries and
+// releases the lock. The config itself is written by Write, so there is
+// nothing else to flush. The journal must not be used afterwards. A run that
+// committed warns about commits left unpushed, unless AutoPush pushes them
+// next time.
+func (j *Journal) Close() error {
+	err := j.waitPush()
+	if err == nil && j.committed && !j.AutoPush {
+		j.warnUnpushed()
+	}
+	if cerr := j.saveCache(); err == nil {
+		err = cerr
+	}
+	if uerr := j.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+func (j *Journal) Commit() error {
+	if j.dryRun || !j.hasRepo() {
+		return nil
+	}
+	if err := j.waitPush(); err != nil {
+		return err
+	}
+	if err := j.git.Add(j.commitPaths()...); err != nil {
+		return err
+	}
+	out, err := j.git.Status(j.commitPaths()...)
 	if err != nil {
-		panic(fmt.Sprintf("error run git status %#v\n", err))
+		return err
 	}
-	if strings.TrimSpace(out.String()) != "" {
-		cmd = exec.Command("git", "-C", j.path, "rev-parse", "HEAD")
-		var out bytes.Buffer
-		cmd.Stdout = &out
-		err := cmd.Run()
+	if strings.TrimSpace(out) != "" {
+		hash, err := j.git.RevParse("HEAD")
 		if err != nil {
-			panic(fmt.Sprintf("error run git status %+v\n", err))
+			return err
 		}
-		j.Hash = strings.TrimSpace(out.String())
-		j.writeConfig()
-
-		cmd = exec.Command("git", "-C", j.path, "add", ".")
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err = cmd.Run()
-		if err != nil {
-			panic(fmt.Sprintf("error run git add %#v\n", err))
+		j.Hash = strings.TrimSpace(hash)
+		if err := j.writeConfig(); err != nil {
+			return err
 		}
-		cmd = exec.Command("git", "-C", j.path, "commit", "-m", time.Now().Format("2006-01-02 15:04:05"))
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err = cmd.Run()
-		if err != nil {
-			panic(fmt.Sprintf("error run git add %#v\n", err))
+		if err := j.git.Add(j.commitPaths()...); err != nil {
+			return err
 		}
+		if err := j.git.Commit(j.commitMessage(len(strings.Split(strings.TrimSpace(out), "\n"))), j.AuthorName, j.AuthorEmail); err != nil {
+			return err
+		}
+		j.committed = true
 	}
+	return nil
 }
 
-func (j *Journal) Push() {
-	j.Commit()
-	cmd := exec.Command("git", "-C", j.path, "pull", "--rebase")
-	err := cmd.Run()
+func (j *Journal) warnUnpushed() {
+	ahead, upstream, err := j.git.Unpushed()
 	if err != nil {
-		panic(fmt.Sprintf("error run git push %#v\n", err))
+		j.verbosef("skip unpushed check: %v", err)
+	} else if upstream && ahead > 0 {
+		j.warnf("%d unpushed commits; run `diary push`", ahead)
 	}
-	cmd = exec.Command("git", "-C", j.path, "push")
-	err = cmd.Run()
-	if err != nil {
-		panic(fmt.Sprintf("error run git push %#v\n", err))
+}
+
+// commitPaths are the pathspecs Commit adds: everything but the lock, the
+// cache, the edit copies and, when CommitConfig is off, the config. Files
+// .gitignore already lists are left to it, as git add fails on an exclude
+// naming an ignored file.
+func (j *Journal) commitPaths() []string {
+	_, present, err := j.gitignore()
+	paths := []string{"."}
+	for _, f := range j.ignoredFiles() {
+		if err != nil || !present[f] {
+			paths = append(paths, ":(exclude)"+f)
+		}
 	}
+	return paths
 }
 
-func (j *Journal) writeConfig() {
-	data, err := json.MarshalIndent(j, "", "  ")
+func (j *Journal) Push() error {
+	if err := j.Commit(); err != nil {
+		return err
+	}
+	if !j.hasRepo() {
+		return nil
+	}
+	if err := j.git.Pull(!j.noRebase); err != nil {
+		inProgress, rerr := j.git.RebaseInProgress()
+		if rerr != nil || !inProgress {
+			return err
+		}
+		if aerr := j.git.RebaseAbort(); aerr != nil {
+			return fmt.Errorf("pull --rebase stopped on conflicts and could not be aborted, resolve them manually in '%s': %w", j.path, aerr)
+		}
+		return fmt.Errorf("pull --rebase stopped on conflicts and was aborted, resolve them manually in '%s' and run push again: %w", j.path, err)
+	}
+	return j.git.Push()
+}
+
+func (j *Journal) writeConfig() error {
+	data, err := json.MarshalIndent(j.plainConfig(), "", "  ")
 	if err != nil {
-		panic(fmt.Sprintf("error marshal json %+v", err))
+		return fmt.Errorf("error marshal json: %w", err)
 	}
-	err = ioutil.WriteFile(filepath.Join(j.path, ".journal.json"), data, 0644)
+	err = writeFileChanged(j.configPath(), data)
 	if err != nil {
-		panic(fmt.Sprintf("error write file %+v", err))
+		return fmt.Errorf("error write config file: %w", err)
 	}
+	return nil
 }
 
-func (j *Journal) Write() {
-	j.writeConfig()
-	fout, err := os.Create(filepath.Join(j.path, "index.md"))
-	if err != nil {
-		panic(fmt.Sprintf("error write index file %+v", err))
+func (j *Journal) Write() error {
+	if j.dryRun {
+		return j.RenderIndex(os.Stdout)
+	}
+	if err := j.writeConfig(); err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := j.writeIndexFiles(); err != nil {
+		return err
+	}
+	if err := j.WriteJSON(&out); err != nil {
+		return err
+	}
+	if err := j.writeIndexFile(strings.TrimSuffix(j.indexPath(), filepath.Ext(j.index))+".json", out.Bytes()); err != nil {
+		return fmt.Errorf("error write index json file: %w", err)
+	}
+	if err := j.writeSectionListings(); err != nil {
+		return err
+	}
+	for _, s := range j.Sections {
+		j.verbosef("write %s: %d %s", j.index, len(j.visibleTags(j.tagMap(s.Tag))), s.Tag)
+	}
+	if j.NoCommit || j.noCommit {
+		j.verbosef("skip commit")
+	} else if err := j.Commit(); err != nil {
+		return err
+	}
+	j.infof("indexed %d tags in %d notes", j.tagCount(), len(j.knownPaths()))
+	return nil
+}
+
+func (j *Journal) tagCount() int {
+	count := 0
+	for _, k := range builtinKinds {
+		for _, tags := range j.tagMap(k) {
+			count += len(tags)
+		}
+	}
+	for _, m := range j.Custom {
+		for _, tags := range m {
+			count += len(tags)
+		}
 	}
-	defer fout.Close()
-	fout.WriteString("# DOING\n\n")
-	j.writeTags(fout, j.Doings)
+	return count
+}
 
-	fout.WriteString("\n# TODO\n\n")
-	j.writeTags(fout, j.Todos)
+// sectionListing is the file holding the untruncated tags of a section when
+// MaxPerSection cuts it short in the index, e.g. "index-todo.md".
+func (j *Journal) sectionListing(kind string) string {
+	ext := filepath.Ext(j.index)
+	return strings.TrimSuffix(j.index, ext) + "-" + strings.ToLower(kind) + ext
+}
+
+func (j *Journal) writeSectionListings() error {
+	for _, s := range j.Sections {
+		fn := j.resolve(j.sectionListing(s.Tag))
+		if j.MaxPerSection <= 0 || len(j.visibleTags(j.tagMap(s.Tag))) <= j.MaxPerSection {
+			if err := removeIndexFile(fn); err != nil {
+				return fmt.Errorf("error remove section listing: %w", err)
+			}
+			continue
+		}
+		var out bytes.Buffer
+		fmt.Fprintf(&out, "# %s\n\n", s.Title)
+		j.writeTags(&out, j.tagMap(s.Tag), 0, j.ascending(s))
+		if err := j.writeIndexFile(fn, out.Bytes()); err != nil {
+			return fmt.Errorf("error write section listing: %w", err)
+		}
+	}
+	return nil
+}
 
-	fout.WriteString("\n# LATER\n\n")
-	j.writeTags(fout, j.Laters)
+func (j *Journal) RenderIndex(w io.Writer) error {
+	var out bytes.Buffer
+	for i, s := range j.Sections {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		j.renderSection(&out, s)
+	}
 
-	fout.Close()
-	j.Commit()
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return fmt.Errorf("error write index: %w", err)
+	}
+	return nil
 }
 
-func (j *Journal) writeTags(out *os.File, tagMap map[string][]Tag) {
+func (j *Journal) renderSection(out io.Writer, s Section) {
+	fmt.Fprintf(out, "# %s\n\n", s.Title)
+	if more := j.writeTags(out, j.tagMap(s.Tag), j.MaxPerSection, j.ascending(s)); more > 0 {
+		fmt.Fprintf(out, "\n... and %d more, see [%s](%s)\n", more, s.Title, filepath.ToSlash(j.indexFile(j.sectionListing(s.Tag))))
+	}
+}
+
+// splitIndex is the file of section kind with SplitIndex set, next to the
+// index and named after the kind, e.g. "todo.md".
+func (j *Journal) splitIndex(kind string) string {
+	return filepath.Join(filepath.Dir(j.index), strings.ToLower(kind)+filepath.Ext(j.index))
+}
+
+// splitMarker opens every split index file, telling it from a note of the
+// same name, which Write refuses to overwrite.
+const splitMarker = "<!-- generated by diary, edits are overwritten -->\n"
+
+// isSplitIndex reports whether fn is the split index file of a section.
+func (j *Journal) isSplitIndex(fn string) bool {
+	ff := j.resolve(strings.TrimSuffix(fn, ageExt))
+	for _, s := range j.Sections {
+		if ff == j.resolve(j.splitIndex(s.Tag)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSplitIndex returns an error when a split index file, plain or
+// encrypted, exists without splitMarker, as it then is a note of the user.
+func (j *Journal) checkSplitIndex() error {
+	for _, s := range j.Sections {
+		fn := j.splitIndex(s.Tag)
+		for _, ff := range []string{j.resolve(fn), j.resolve(fn) + ageExt} {
+			var data []byte
+			var err error
+			if strings.HasSuffix(ff, ageExt) {
+				if _, err = os.Stat(ff); err == nil {
+					data, err = j.decryptFile(ff)
+				}
+			} else {
+				data, err = os.ReadFile(ff)
+			}
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("error read index file: %w", err)
+			}
+			if !bytes.HasPrefix(data, []byte(splitMarker)) {
+				return fmt.Errorf("'%s' is a note, not a split index file: rename it, or turn SplitIndex off", filepath.ToSlash(fn))
+			}
+		}
+	}
+	return nil
+}
+
+// writeIndexFiles writes the combined index or, with SplitIndex set, one
+// file per section and removes the combined one. Turning SplitIndex off
+// leaves the section files, which are then scanned as notes.
+func (j *Journal) writeIndexFiles() error {
+	var out bytes.Buffer
+	if !j.SplitIndex {
+		if err := j.RenderIndex(&out); err != nil {
+			return err
+		}
+		if err := j.writeIndexFile(j.indexPath(), out.Bytes()); err != nil {
+			return fmt.Errorf("error write index file: %w", err)
+		}
+		return nil
+	}
+	if err := j.checkSplitIndex(); err != nil {
+		return err
+	}
+	for _, s := range j.Sections {
+		out.Reset()
+		out.WriteString(splitMarker + "\n")
+		j.renderSection(&out, s)
+		if err := j.writeIndexFile(j.resolve(j.splitIndex(s.Tag)), out.Bytes()); err != nil {
+			return fmt.Errorf("error write index file: %w", err)
+		}
+	}
+	if err := removeIndexFile(j.indexPath()); err != nil {
+		return fmt.Errorf("error remove index file: %w", err)
+	}
+	return nil
+}
+
+// visibleTags returns the tags of tagMap that are not snoozed past today.
+func (j *Journal) visibleTags(tagMap map[string][]Tag) []Tag {
+	today := startOfDay(j.now())
 	var tags []Tag
 	for _, n := range tagMap {
 		for _, t := range n {
-			tags = append(tags, t)
+			if t.Wake == nil || !t.Wake.After(today) {
+				tags = append(tags, t)
+			}
 		}
 	}
-	sort.Slice(tags, func(i, j int) bool {
-		return tags[i].Time.After(tags[j].Time)
+	return tags
+}
+
+// writeTags writes at most limit tags, all of them when limit is zero, and
+// returns how many were left out. Tags are newest first, or oldest first
+// when asc is set, with higher priorities ahead; the limit keeps the newest
+// tags by time whatever that order.
+func (j *Journal) writeTags(out io.Writer, tagMap map[string][]Tag, limit int, asc bool) int {
+	items := j.indexItems(tagMap)
+	more := 0
+	if limit > 0 && len(items) > limit {
+		sort.Slice(items, func(a, b int) bool {
+			ta, tb := items[a].tag, items[b].tag
+			if !ta.Time.Equal(tb.Time) {
+				return ta.Time.After(tb.Time)
+			}
+			if items[a].path != items[b].path {
+				return items[a].path < items[b].path
+			}
+			return ta.LineNo < tb.LineNo
+		})
+		for _, it := range items[limit:] {
+			more += it.size()
+		}
+		items = items[:limit]
+	}
+	sort.Slice(items, func(a, b int) bool {
+		ta, tb := items[a].tag, items[b].tag
+		if j.GroupByDay {
+			if da, db := j.tagDay(ta), j.tagDay(tb); da != db {
+				return (da > db) != asc
+			}
+		}
+		pa, pb := j.priorityRank(ta.Priority), j.priorityRank(tb.Priority)
+		if pa != pb {
+			return pa < pb
+		}
+		if !ta.Time.Equal(tb.Time) {
+			return ta.Time.After(tb.Time) != asc
+		}
+		if items[a].path != items[b].path {
+			return items[a].path < items[b].path
+		}
+		return ta.LineNo < tb.LineNo
 	})
-	for _, t := range tags {
-		out.WriteString(fmt.Sprintf("%s\n", t.Text))
+	day := ""
+	for _, it := range items {
+		if j.GroupByDay {
+			if d := j.tagDay(it.tag); d != day {
+				fmt.Fprintf(out, "### %s\n", d)
+				day = d
+			}
+		}
+		j.writeItem(out, it, 0)
 	}
+	return more
 }
 
-func (j *Journal) OpenIndex() {
-	j.processChanges()
-	cmd := exec.Command(j.Editor, filepath.Join(j.path, "index.md"))
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	if err != nil {
-		panic(fmt.Sprintf("error run %#v\n", err))
+// indexItem is a tag in the index with the tags nested below it in the
+// same note.
+type indexItem struct {
+	tag      Tag
+	path     string
+	children []*indexItem
+}
+
+func (it *indexItem) size() int {
+	n := 1
+	for _, c := range it.children {
+		n += c.size()
 	}
-	j.processChanges()
-	j.Write()
+	return n
 }
 
-func (j *Journal) CreateDiary() {
-	now := time.Now()
-	fp := now.Format("2006/01")
-	err := os.MkdirAll(filepath.Join(j.path, fp), os.ModePerm)
-	if err != nil {
-		panic(fmt.Sprintf("error create path '%s' %+v\n", fp, err))
-	}
-	fn := now.Format("2006-01-02.md")
-	ff := filepath.Join(j.path, fp, fn)
-	if _, err := os.Stat(ff); err == nil {
-		cmd := exec.Command(j.Editor,
-			"-c", "norm Go",
-			"-c", fmt.Sprintf("norm Go## %s", now.Format("15:04:05")),
-			"-c", "norm G2o",
-			"-c", "norm zz",
-			"-c", "startinsert", ff,
-		)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err = cmd.Run()
+// indexItems returns the visible tags of tagMap as top level items. A tag
+// whose Parent line holds a tag of tagMap becomes its child, any other tag
+// is top level, so a TODO below a DOING is not put under an earlier TODO.
+func (j *Journal) indexItems(tagMap map[string][]Tag) []*indexItem {
+	var paths []string
+	for fn := range tagMap {
+		paths = append(paths, fn)
+	}
+	sort.Strings(paths)
+	var items []*indexItem
+	for _, fn := range paths {
+		tags := j.visibleTags(map[string][]Tag{fn: tagMap[fn]})
+		sort.SliceStable(tags, func(a, b int) bool {
+			return tags[a].LineNo < tags[b].LineNo
+		})
+		lines := make(map[int]*indexItem)
+		for _, t := range tags {
+			it := &indexItem{tag: t, path: fn}
+			if parent, ok := lines[t.Parent]; ok && t.Parent > 0 {
+				parent.children = append(parent.children, it)
+			} else {
+				items = append(items, it)
+			}
+			lines[t.LineNo] = it
+		}
+	}
+	return items
+}
+
+func (j *Journal) writeItem(out io.Writer, it *indexItem, level int) {
+	text := it.tag.Text
+	if j.IndexRaw && it.tag.Raw != "" {
+		text = it.tag.Raw
+	}
+	if level > 0 {
+		text = strings.Repeat("  ", level) + strings.TrimLeft(text, " \t")
+	}
+	if it.tag.Section != "" {
+		text += fmt.Sprintf(" _(%s)_", it.tag.Section)
+	}
+	// the open date rather than the age, so the index only changes once
+	// when a tag goes stale
+	if j.StaleDays > 0 && j.isStale(it.tag, j.now(), j.StaleDays) {
+		text += fmt.Sprintf(" _(open since %s)_", j.tagDay(it.tag))
+	}
+	fmt.Fprintf(out, "%s\n", text)
+	for _, c := range it.children {
+		j.writeItem(out, c, level+1)
+	}
+}
+
+// indentWidth is the indentation of line in columns, a tab counting as four.
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// tagDay is the date a tag is grouped under when GroupByDay is set.
+func (j *Journal) tagDay(t Tag) string {
+	return t.Time.In(j.location()).Format("2006-01-02")
+}
+
+func (j *Journal) SetDiaryMonths(months int) {
+	j.months = months
+}
+
+func (j *Journal) warnf(format string, args ...interface{}) {
+	j.log.logf(LogNormal, "warning: "+format, args...)
+}
+
+func (j *Journal) inDiaryWindow(t time.Time) bool {
+	now := j.now()
+	lastYearMonth := now.Year()*12 + int(now.Month()) - j.diaryMonths()
+	return t.Year()*12+int(t.Month()) > lastYearMonth
+}
+
+// SetNoCommit makes Write leave the index uncommitted, as NoCommit in the
+// config does, for a later commit or push.
+func (j *Journal) SetNoCommit(noCommit bool) {
+	j.noCommit = noCommit
+}
+
+// SetNoEdit makes new and today only seed the missing day note from the
+// template, without ever starting the editor, as when stdin is not a
+// terminal.
+func (j *Journal) SetNoEdit(noEdit bool) {
+	j.noEdit = noEdit
+}
+
+// SetGit replaces the git runner, which shells out to git in the journal
+// directory by default.
+func (j *Journal) SetGit(git GitRunner) {
+	j.git = git
+	j.repo = nil
+}
+
+func (j *Journal) SetNoRebase(noRebase bool) {
+	j.noRebase = noRebase
+}
+
+// SetIndex changes the name of the generated index. The JSON index is
+// written next to it with a ".json" extension.
+func (j *Journal) SetIndex(index string) {
+	if index != "" {
+		j.index = index
+	}
+}
+
+func (j *Journal) resolve(fn string) string {
+	if filepath.IsAbs(fn) {
+		return fn
+	}
+	return filepath.Join(j.path, fn)
+}
+
+func (j *Journal) configPath() string {
+	return j.resolve(j.config)
+}
+
+func (j *Journal) indexPath() string {
+	return j.resolve(j.index)
+}
+
+// isIndex reports whether fn is a generated index, plain or encrypted,
+// which is never scanned for tags.
+func (j *Journal) isIndex(fn string) bool {
+	base := strings.TrimSuffix(filepath.Base(fn), ageExt)
+	if base == filepath.Base(j.index) {
+		return true
+	}
+	for _, s := range j.Sections {
+		if base == filepath.Base(j.sectionListing(s.Tag)) {
+			return true
+		}
+	}
+	return j.SplitIndex && j.isSplitIndex(fn)
+}
+
+func (j *Journal) SetPlain(plain bool) {
+	j.plain = plain
+}
+
+// SetDiaryOnly limits the walk over notes to diary notes, the Diary type,
+// leaving out every other note as SetRange does.
+func (j *Journal) SetDiaryOnly(diaryOnly bool) {
+	j.diaryOnly = diaryOnly
+}
+
+// SetRange limits the walk over notes to diary notes dated from since to
+// until, both inclusive and in YYYY-MM-DD form; an empty bound is open.
+// Other notes are walked only without a range, or when notes is set.
+func (j *Journal) SetRange(since, until string, notes bool) error {
+	for _, b := range []struct {
+		value string
+		t     *time.Time
+	}{{since, &j.since}, {until, &j.until}} {
+		if b.value == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", b.value, j.location())
+		if err != nil {
+			return fmt.Errorf("invalid date '%s', expected YYYY-MM-DD: %w", b.value, err)
+		}
+		*b.t = t
+	}
+	j.rangeNotes = notes
+	return nil
+}
+
+// inRange reports whether the note at fn passes the SetRange filter, judging
+// by its path alone so skipped files are never opened.
+func (j *Journal) inRange(fn string) bool {
+	if j.since.IsZero() && j.until.IsZero() {
+		return true
+	}
+	d, ok := j.diaryDate(strings.TrimPrefix(filepath.ToSlash(fn), archiveDir+"/"))
+	if !ok {
+		return j.rangeNotes
+	}
+	return (j.since.IsZero() || !d.Before(j.since)) && (j.until.IsZero() || !d.After(j.until))
+}
+
+func (j *Journal) SetStrict(strict bool) {
+	j.strict = strict
+}
+
+func (j *Journal) SetDryRun(dryRun bool) {
+	j.dryRun = dryRun
+}
+
+// SetMaxDepth overrides MaxDepth from the config for this run when depth is
+// above zero.
+func (j *Journal) SetMaxDepth(depth int) {
+	j.depth = depth
+}
+
+// maxDepth is how many directories deep the walk over notes goes, from
+// SetMaxDepth or MaxDepth; 0 is unlimited. Diary notes are two deep, three
+// in the archive.
+func (j *Journal) maxDepth() int {
+	if j.depth > 0 {
+		return j.depth
+	}
+	return j.MaxDepth
+}
+
+// tooDeep reports whether the directory at fn, relative to the journal, is
+// below maxDepth.
+func (j *Journal) tooDeep(fn string) bool {
+	return j.maxDepth() > 0 && fn != "." && len(strings.Split(filepath.ToSlash(fn), "/")) > j.maxDepth()
+}
+
+func (j *Journal) diaryMonths() int {
+	if j.months > 0 {
+		return j.months
+	}
+	if j.DiaryMonths > 0 {
+		return j.DiaryMonths
+	}
+	return 3
+}
+
+// timeLayout is the time.Format layout of new time headers, from
+// TimeFormat.
+func (j *Journal) timeLayout() string {
+	if j.TimeFormat == "HH:MM" {
+		return "15:04"
+	}
+	return "15:04:05"
+}
+
+// headerTime returns the time of a time header in TimeFormat: "now" is the
+// time given and HH:MM gets ":00" under HH:MM:SS. An HH:MM:SS header is kept
+// as written under HH:MM. fmt -w persists the result.
+func (j *Journal) headerTime(s string, now time.Time) string {
+	if strings.EqualFold(s, "now") {
+		return now.In(j.location()).Format(j.timeLayout())
+	}
+	if len(s) == 5 && j.TimeFormat != "HH:MM" {
+		return s + ":00"
+	}
+	return s
+}
+
+// headerTime is the time of a time header of the note, "now" being its
+// modification time so every parse gives the same time until it is edited.
+func (n *Note) headerTime(s string) string {
+	return n.journal.headerTime(s, n.modTime)
+}
+
+// location is the configured Timezone, or the machine's local zone when
+// none is set.
+func (j *Journal) location() *time.Location {
+	if j.loc != nil {
+		return j.loc
+	}
+	return time.Local
+}
+
+// now is the current time in the journal zone, from clock when set.
+func (j *Journal) now() time.Time {
+	if j.clock != nil {
+		return j.clock().In(j.location())
+	}
+	return time.Now().In(j.location())
+}
+
+func (j *Journal) priorityRank(priority string) int {
+	if priority == "" {
+		return len(j.Priorities)
+	}
+	for i, p := range j.Priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return -1
+}
+
+func (j *Journal) matchTag(w string) (kind, priority, suffix string, ok bool) {
+	var ms []string
+	if j.TagStyle != "hashtag" {
+		ms = j.tagRe.FindStringSubmatch(w)
+	}
+	if ms == nil && j.TagStyle != "asterisk" {
+		ms = j.hashtagRe.FindStringSubmatch(w)
+	}
+	if ms == nil || (ms[2] != "" && j.priorityRank(ms[2]) < 0) {
+		return "", "", "", false
+	}
+	return strings.ToUpper(ms[1]), ms[2], ms[3], true
+}
+
+func (j *Journal) OpenIndex() error {
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	index := j.indexPath()
+	if j.SplitIndex && len(j.Sections) > 0 {
+		index = j.resolve(j.splitIndex(j.Sections[0].Tag))
+	}
+	edit := func(ff string) error {
+		cmd, err := j.editorCommand(ff, 1)
 		if err != nil {
-			panic(fmt.Sprintf("error run %#v\n", err))
-		}
-	} else if errors.Is(err, os.ErrNotExist) {
-		cmd := exec.Command(j.Editor,
-			"-c", fmt.Sprintf("norm Gi# Note %s", now.Format("2006-01-02")),
-			"-c", "norm Go",
-			"-c", fmt.Sprintf("norm Go## %s", now.Format("15:04:05")),
-			"-c", "norm G2o",
-			"-c", "norm zz",
-			"-c", "startinsert", ff,
-		)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err = cmd.Run()
+			return err
+		}
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("error run %s: %w", j.Editor, err)
+		}
+		return nil
+	}
+	var err error
+	if j.EncryptIndex {
+		err = j.editPlain(index+ageExt, edit)
+	} else {
+		err = edit(index)
+	}
+	if err != nil {
+		return err
+	}
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	return j.Write()
+}
+
+// diaryCommand returns the editor command opening today's diary at ff. When
+// a running Neovim is reachable, remote sends the same cursor setup to it
+// and cmd is the fallback should that fail.
+func (j *Journal) diaryCommand(ff string, now time.Time) (cmd *exec.Cmd, remote *exec.Cmd, err error) {
+	exists := true
+	if _, err := os.Stat(ff); errors.Is(err, os.ErrNotExist) {
+		exists = false
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("error create file '%s': %w", ff, err)
+	}
+	if j.noEdit || !interactive(os.Stdin) {
+		return nil, nil, j.seedDiary(ff, now, exists)
+	}
+	if tmpl, ok, err := j.diaryTemplate(exists); err != nil {
+		return nil, nil, err
+	} else if ok {
+		line, err := j.appendTemplate(ff, now, exists, tmpl)
 		if err != nil {
-			panic(fmt.Sprintf("error run %#v\n", err))
+			return nil, nil, err
 		}
+		cmd, err = j.editorCommand(ff, line, fmt.Sprintf("+%d", line), "-c", "startinsert")
+		return cmd, nil, err
+	}
+	var cmds []string
+	if !exists {
+		cmds = append(cmds, fmt.Sprintf("norm Gi# %s", j.title(now)))
+	}
+	cmds = append(cmds,
+		"norm Go",
+		fmt.Sprintf("norm Go## %s", now.Format(j.timeLayout())),
+		"norm G2o",
+		"norm zz",
+		"startinsert",
+	)
+	if j.useVimArgs() {
+		var args []string
+		for _, c := range cmds {
+			args = append(args, "-c", c)
+		}
+		cmd, err = j.editorCommand(ff, 0, args...)
+		return cmd, j.remoteCommand(ff, cmds), err
+	}
+	line, err := j.appendScaffold(ff, now, exists)
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd, err = j.editorCommand(ff, line)
+	return cmd, nil, err
+}
+
+// title is the first header of a new diary note, from TitleFormat.
+func (j *Journal) title(now time.Time) string {
+	if j.TitleFormat == "" {
+		return j.renderTemplate(defaultTitleFormat, now)
+	}
+	return j.renderTemplate(j.TitleFormat, now)
+}
+
+// seedDiary writes the day note ff from the template when it does not
+// exist yet, and leaves an existing one alone.
+func (j *Journal) seedDiary(ff string, now time.Time, exists bool) error {
+	if exists {
+		return nil
+	}
+	tmpl, ok, err := j.diaryTemplate(false)
+	if err != nil {
+		return err
+	}
+	if ok {
+		_, err = j.appendTemplate(ff, now, false, tmpl)
 	} else {
-		panic(fmt.Sprintf("error create file '%s' %#v\n", ff, err))
+		_, err = j.appendScaffold(ff, now, false)
+	}
+	if err != nil {
+		return err
+	}
+	j.infof("created %s", j.diaryPath(now))
+	return nil
+}
+
+func (j *Journal) appendScaffold(ff string, now time.Time, exists bool) (int, error) {
+	var data []byte
+	if exists {
+		var err error
+		data, err = ioutil.ReadFile(ff)
+		if err != nil {
+			return 0, fmt.Errorf("error read file '%s': %w", ff, err)
+		}
+	}
+	var sb strings.Builder
+	if !exists {
+		sb.WriteString(fmt.Sprintf("# %s\n", j.title(now)))
+	} else if len(data) > 0 && data[len(data)-1] != '\n' {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("\n## %s\n\n\n", now.Format(j.timeLayout())))
+	data = append(data, sb.String()...)
+	if err := ioutil.WriteFile(ff, data, 0644); err != nil {
+		return 0, fmt.Errorf("error write file '%s': %w", ff, err)
+	}
+	return bytes.Count(data, []byte("\n")), nil
+}
+
+func (j *Journal) OpenToday() error {
+	now := j.now()
+	fn := j.diaryPath(now)
+	ff := filepath.Join(j.path, fn)
+	if err := os.MkdirAll(filepath.Dir(ff), os.ModePerm); err != nil {
+		return fmt.Errorf("error create path '%s': %w", filepath.Dir(fn), err)
+	}
+	if encrypted, err := j.encryptedDiary(ff); err != nil {
+		return err
+	} else if encrypted {
+		// the plain copy is encrypted back once the editor exits, which a
+		// running Neovim does not wait for
+		return j.editPlain(ff+ageExt, func(ff string) error {
+			cmd, _, err := j.diaryCommand(ff, now)
+			if err != nil || cmd == nil {
+				return err
+			}
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("error run %s: %w", j.Editor, err)
+			}
+			return nil
+		})
+	}
+	cmd, remote, err := j.diaryCommand(ff, now)
+	if err != nil {
+		return err
+	}
+	if cmd == nil {
+		return nil
+	}
+	if remote != nil {
+		if err := remote.Run(); err == nil {
+			return nil
+		}
+		j.warnf("no Neovim server at '%s', starting %s", j.nvimSocket(), j.Editor)
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error run %s: %w", j.Editor, err)
+	}
+	return nil
+}
+
+func (j *Journal) CreateDiary() error {
+	if err := j.OpenToday(); err != nil {
+		return err
+	}
+	if err := j.ProcessChanges(); err != nil {
+		return err
 	}
-	j.processChanges()
-	j.Write()
+	return j.Write()
 }
 
-func (j *Journal) NewNote(fn string) *Note {
+func (j *Journal) NewNote(fn string) (*Note, error) {
 	pfn := filepath.Join(j.path, fn)
 	if st, err := os.Stat(pfn); err != nil {
-		panic(fmt.Sprintf("Error read file '%s' %+v\n", fn, err))
+		return nil, fmt.Errorf("error read file '%s': %w", fn, err)
 	} else {
-		if ms := dpattern.FindAllStringSubmatch(fn, -1); ms != nil && len(ms) == 1 && len(ms[0]) == 6 && ms[0][1] == ms[0][3] && ms[0][2] == ms[0][4] {
-			dt := fmt.Sprintf("%s-%s-%sT00:00:00", ms[0][3], ms[0][4], ms[0][5])
-			time, err := time.ParseInLocation("2006-01-02T15:04:05", dt, time.Local)
-			if err != nil {
-				panic(fmt.Sprintf("error date format '%s' %+v", dt, err))
-			}
+		if day, ok := j.diaryDate(strings.TrimSuffix(strings.TrimPrefix(fn, archiveDir+"/"), ageExt)); ok {
 			return &Note{
 				journal: j,
 				Path:    fn,
 				Type:    Diary,
-				Time:    time,
-			}
+				Time:    day,
+				modTime: st.ModTime(),
+			}, nil
 		} else {
 			return &Note{
 				journal: j,
 				Path:    fn,
 				Type:    NoteText,
-				Time:    st.ModTime(),
+				Time:    st.ModTime().In(j.location()),
+				modTime: st.ModTime(),
+			}, nil
+		}
+	}
+}
+
+type change struct {
+	Path   string
+	Status string
+	note   *Note
+	// from is the old path of a renamed note
+	from string
+}
+
+// changedNotes lists the notes added, modified or deleted since Hash.
+// Untracked notes have status "?", deleted notes have no note.
+func (j *Journal) changedNotes() ([]change, error) {
+	out, err := j.git.LsFiles(".", "--exclude-standard", "--others")
+	if err != nil {
+		return nil, err
+	}
+	var changes []change
+	seen := make(map[string]bool)
+	for _, fn := range strings.Split(out, "\n") {
+		if j.isNoteFile(fn) && !isArchived(fn) && !j.ignored(fn) && !j.tooDeep(filepath.Dir(fn)) && !seen[fn] {
+			n, err := j.NewNote(fn)
+			if err != nil {
+				return nil, err
 			}
+			seen[fn] = true
+			changes = append(changes, change{Path: fn, Status: "?", note: n})
 		}
 	}
+	out, err = j.git.Diff(j.Hash, "--name-status", "-M")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		// a rename lists the old path first, which is gone like a delete
+		from := ""
+		if old := fields[1]; strings.HasPrefix(fields[0], "R") && len(fields) == 3 && j.isNoteFile(old) && !seen[old] {
+			seen[old] = true
+			changes = append(changes, change{Path: old, Status: "D"})
+			from = old
+		}
+		fn := fields[len(fields)-1]
+		if !j.isNoteFile(fn) || isArchived(fn) || j.ignored(fn) || j.tooDeep(filepath.Dir(fn)) || seen[fn] {
+			continue
+		}
+		ff := filepath.Join(j.path, fn)
+		if _, err := os.Stat(ff); strings.HasPrefix(fields[0], "D") || errors.Is(err, os.ErrNotExist) {
+			seen[fn] = true
+			changes = append(changes, change{Path: fn, Status: "D"})
+		} else if err == nil {
+			n, err := j.NewNote(fn)
+			if err != nil {
+				return nil, err
+			}
+			seen[fn] = true
+			changes = append(changes, change{Path: fn, Status: fields[0][:1], note: n, from: from})
+		}
+	}
+	sort.Slice(changes, func(a, b int) bool {
+		return changes[a].Path < changes[b].Path
+	})
+	return changes, nil
 }
 
-func (j *Journal) processChanges() {
+// ProcessChanges reparses the notes changed since the commit of the index,
+// or every note when there is no such commit.
+func (j *Journal) ProcessChanges() error {
 	if j.Hash == "" {
-		j.processAll()
-		return
+		j.verbosef("no index hash, process all notes")
+		return j.ProcessAll()
 	}
-	cmd := exec.Command("git", "-C", j.path, "ls-files", ".", "--exclude-standard", "--others")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
+	if !j.hasRepo() {
+		j.verbosef("no git repository, process all notes")
+		return j.ProcessAll()
+	}
+	changes, err := j.changedNotes()
 	if err != nil {
-		panic(fmt.Sprintf("error run git ls-files %+v\n", err))
+		return err
 	}
-	changes := make(map[string]*Note)
-	for _, fn := range strings.Split(out.String(), "\n") {
-		if strings.HasSuffix(fn, ".md") {
-			if _, ok := changes[fn]; !ok {
-				changes[fn] = j.NewNote(fn)
-			}
+	j.verbosef("%d notes changed since %s", len(changes), j.Hash)
+	for _, c := range changes {
+		if c.from != "" {
+			j.moveHistory(c.from, c.Path)
+		}
+	}
+	for _, c := range changes {
+		if c.note == nil {
+			j.purge(c.Path)
+		}
+	}
+	for _, fn := range j.knownPaths() {
+		if _, err := os.Stat(filepath.Join(j.path, fn)); errors.Is(err, os.ErrNotExist) || j.ignored(fn) || j.tooDeep(filepath.Dir(fn)) {
+			j.purge(fn)
+		}
+	}
+	for _, c := range changes {
+		if c.note == nil {
+			continue
+		}
+		stored := j.storedTags(c.Path)
+		if err := c.note.process(); err != nil {
+			return err
 		}
+		j.countAdded(tagsMissing(j.storedTags(c.Path), stored))
 	}
-	cmd = exec.Command("git", "-C", j.path, "diff", j.Hash, "--name-only")
-	out = bytes.Buffer{}
-	cmd.Stdout = &out
-	err = cmd.Run()
+	return j.saveCache()
+}
+
+func (j *Journal) collectTags() ([]Tag, error) {
+	var tags []Tag
+	err := j.walkNotes(func(n *Note) error {
+		ts, err := n.parse()
+		if err != nil {
+			return err
+		}
+		tags = append(tags, ts...)
+		return nil
+	})
 	if err != nil {
-		panic(fmt.Sprintf("error run git status %+v\n", err))
-	}
-	for _, fn := range strings.Split(out.String(), "\n") {
-		if strings.HasSuffix(fn, ".md") {
-			ff := filepath.Join(j.path, fn)
-			if _, err := os.Stat(ff); err == nil {
-				if _, ok := changes[fn]; !ok {
-					changes[fn] = j.NewNote(fn)
-				}
-			}
+		return nil, err
+	}
+	return tags, j.saveCache()
+}
+
+func (j *Journal) storedTags(fn string) []Tag {
+	var tags []Tag
+	for _, kind := range j.kinds() {
+		tags = append(tags, j.tagMap(kind)[fn]...)
+	}
+	return tags
+}
+
+func (j *Journal) knownPaths() []string {
+	paths := make(map[string]bool)
+	for _, kind := range j.kinds() {
+		for fn := range j.tagMap(kind) {
+			paths[fn] = true
 		}
 	}
-	for _, v := range changes {
-		v.process()
+	for _, days := range j.Diary {
+		for _, d := range days {
+			paths[d[1]] = true
+		}
+	}
+	for fn := range j.Meta {
+		paths[fn] = true
+	}
+	for fn := range j.Links {
+		paths[fn] = true
+	}
+	var result []string
+	for fn := range paths {
+		result = append(result, fn)
 	}
+	sort.Strings(result)
+	return result
 }
 
-func (j *Journal) processAll() {
-	pl := len(j.path)
+func (j *Journal) purge(fn string) {
+	j.forgetTags(fn)
+	j.forgetHistory(fn)
+	for _, kind := range j.kinds() {
+		delete(j.tagMap(kind), fn)
+	}
+	delete(j.Meta, fn)
+	delete(j.Links, fn)
+	for k, days := range j.Diary {
+		var kept [][]string
+		for _, d := range days {
+			if d[1] != fn {
+				kept = append(kept, d)
+			}
+		}
+		if len(kept) > 0 {
+			j.Diary[k] = kept
+		} else {
+			delete(j.Diary, k)
+		}
+	}
+}
+
+// ProcessAll rebuilds the tag maps from every note.
+func (j *Journal) ProcessAll() error {
 	j.Doings = make(map[string][]Tag)
 	j.Todos = make(map[string][]Tag)
 	j.Laters = make(map[string][]Tag)
+	j.Waitings = make(map[string][]Tag)
+	j.Done = make(map[string][]Tag)
+	j.Custom = make(map[string]map[string][]Tag)
+	j.Meta = make(map[string]map[string]string)
+	j.Links = make(map[string][]string)
 	j.Diary = make(map[string][][]string)
-	filepath.WalkDir(j.path, func(path string, d fs.DirEntry, err error) error {
-		if d.Name() == ".git" {
+	var notes []*Note
+	err := j.walkNotes(func(n *Note) error {
+		if !j.isIndex(n.Path) {
+			notes = append(notes, n)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	type result struct {
+		tags []Tag
+		err  error
+	}
+	results := make([]result, len(notes))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	j.tagCache()
+	for w := 0; w < j.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				tags, err := notes[i].parse()
+				results[i] = result{tags, err}
+			}
+		}()
+	}
+	for i := range notes {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	// applied in walk order so the maps do not depend on the worker count
+	for i, n := range notes {
+		if err := n.apply(results[i].tags, results[i].err); err != nil {
+			return err
+		}
+	}
+	return j.saveCache()
+}
+
+// workers is the number of notes ProcessAll parses in parallel, Workers
+// from the config or GOMAXPROCS.
+func (j *Journal) workers() int {
+	if j.Workers > 0 {
+		return j.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+func (j *Journal) walkNotes(visit func(n *Note) error) error {
+	return j.walkTree(false, visit)
+}
+
+func (j *Journal) walkTree(archive bool, visit func(n *Note) error) error {
+	return j.walkFiles(archive, true, visit)
+}
+
+// walkFiles visits every note, including archive/ when archive is set. With
+// ranged set, notes outside the SetRange and SetDiaryOnly filters are
+// skipped.
+func (j *Journal) walkFiles(archive bool, ranged bool, visit func(n *Note) error) error {
+	return filepath.WalkDir(j.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() == ".git" || (d.IsDir() && (path == filepath.Join(j.path, templateDir) || path == filepath.Join(j.path, editDir))) {
+			return filepath.SkipDir
+		}
+		if !archive && d.IsDir() && path == filepath.Join(j.path, archiveDir) {
+			return filepath.SkipDir
+		}
+		fn, err := filepath.Rel(j.path, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && j.tooDeep(fn) {
 			return filepath.SkipDir
 		}
-		if d.Name() == "index.md" {
+		if fn != "." && j.ignored(fn) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		} else if j.isIndex(fn) {
 			// ignore
-		} else if strings.HasSuffix(path, ".md") {
-			fn := path[pl:]
-			n := j.NewNote(fn)
-			n.process()
+		} else if j.isNoteFile(path) {
+			if ranged && !j.inRange(fn) {
+				return nil
+			}
+			n, err := j.NewNote(fn)
+			if err != nil {
+				return err
+			}
+			if ranged && j.diaryOnly && n.Type != Diary {
+				return nil
+			}
+			return visit(n)
 		}
 		return nil
 	})
 }
 
-func (n *Note) process() {
-	if strings.HasSuffix(n.Path, "/index.md") || n.Path == "index.md" {
-		return
-	}
-	fin, err := os.Open(filepath.Join(n.journal.path, n.Path))
-	if err != nil {
-		if !errors.Is(err, os.ErrNotExist) {
-			delete(n.journal.Doings, n.Path)
-			delete(n.journal.Todos, n.Path)
-			delete(n.journal.Laters, n.Path)
-			return
-		}
-		panic(fmt.Sprintf("error processing '%s' %+v\n", n.Path, err))
-	}
-	defer fin.Close()
-	scanner := bufio.NewScanner(fin)
+// scan calls fn for every line of the note with the time of the nearest
+// preceding "## HH:MM:SS" header. Lines before the first header get the
+// note time, which is 00:00:00 of the file date for diary notes and the
+// modification time otherwise. A header earlier than the previous one is
+// reported as a warning, or as an error in strict mode. A trailing "\r" is
+// dropped so CRLF notes read like LF ones.
+func (n *Note) scan(r io.Reader, fn func(lineNo int, text string, nt string, ctime time.Time) error) error {
+	scanner := bufio.NewScanner(r)
 	var nd = n.Time.Format("2006-01-02")
 	var nt = n.Time.Format("15:04:05")
 	var ctime = n.Time
+	var last time.Time
 	lineNo := 1
-	var doings []Tag
-	var todos []Tag
-	var laters []Tag
 	for scanner.Scan() {
-		text := scanner.Text()
+		text := strings.TrimSuffix(scanner.Text(), "\r")
 		if ms := mdTimePattern.FindAllStringSubmatch(text, -1); ms != nil {
-			nt = ms[0][1]
-			ctime, err = time.ParseInLocation("2006-01-02T15:04:05", fmt.Sprintf("%sT%s", nd, nt), time.Local)
+			var err error
+			nt = n.headerTime(ms[0][1])
+			clock := nt
+			if len(clock) == 5 {
+				clock += ":00"
+			}
+			ctime, err = time.ParseInLocation("2006-01-02T15:04:05", fmt.Sprintf("%sT%s", nd, clock), n.journal.location())
 			if err != nil {
-				panic(fmt.Sprintf("error parse date '%sT%s' %+v\n", nd, nt, err))
+				return fmt.Errorf("error parse date '%sT%s' in '%s': %w", nd, nt, n.Path, err)
+			}
+			if !last.IsZero() && ctime.Before(last) {
+				msg := fmt.Sprintf("time header %s in '%s' line %d is earlier than previous %s", nt, n.Path, lineNo, last.Format("15:04:05"))
+				n.unordered = append(n.unordered, msg)
+				if err := n.journal.headerOrder(msg); err != nil {
+					return err
+				}
 			}
+			last = ctime
+		}
+		if err := fn(lineNo, text, nt, ctime); err != nil {
+			return err
 		}
-		var doing = false
-		var todo = false
-		var later = false
+		lineNo++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error read file '%s': %w", n.Path, err)
+	}
+	return nil
+}
+
+// headerOrder reports a time header earlier than the previous one, as an
+// error in strict mode and as a warning otherwise.
+func (j *Journal) headerOrder(msg string) error {
+	if j.strict {
+		return errors.New(msg)
+	}
+	j.warnf("%s", msg)
+	return nil
+}
+
+func (n *Note) parse() ([]Tag, error) {
+	tags, key, ok := n.journal.cachedTags(n)
+	if ok {
+		n.journal.verbosef("cached %s", n.Path)
+		// the warnings of scan, which a cache hit skips
+		for _, msg := range n.unordered {
+			if err := n.journal.headerOrder(msg); err != nil {
+				return nil, err
+			}
+		}
+		return tags, nil
+	}
+	n.journal.verbosef("parse %s", n.Path)
+	tags, err := n.parseFile()
+	if err != nil {
+		return nil, err
+	}
+	n.journal.storeTags(n, key, tags)
+	n.fresh = true
+	return tags, nil
+}
+
+func (n *Note) parseFile() ([]Tag, error) {
+	data, err := n.read()
+	if err != nil {
+		return nil, err
+	}
+	if lines := conflictLines(data); len(lines) > 0 {
+		return nil, conflictError(n.Path, lines)
+	}
+	meta, front := n.frontMatter(data)
+	n.Meta = meta
+	n.Links = nil
+	var tags []Tag
+	var linked = make(map[string]bool)
+	var fenced = false
+	var headers []string
+	var continued = -1
+	// indentation and line of the enclosing list items, for the Depth and
+	// Parent of tags
+	var indents, parents []int
+	// links point at the "## HH:MM:SS" header above the tag, which export
+	// html gives the id HH:MM:SS; tags above any time header link the note
+	var anchor string
+	err = n.scan(bytes.NewReader(data), func(lineNo int, text string, nt string, ctime time.Time) error {
+		if lineNo <= front {
+			return nil
+		}
+		var kinds []string
+		var done = false
+		var priority = ""
+		var due, wake *time.Time
+		var projects, contexts []string
 		var texts []string
-		for _, w := range strings.Fields(text) {
-			switch w {
-			case "*DOING*":
-				doing = true
-				texts = append(texts, fmt.Sprintf("*[DOING](%s#%s)*", n.Path, nt))
-			case "*TODO*":
-				todo = true
-				texts = append(texts, fmt.Sprintf("*[TODO](%s#%s)*", n.Path, nt))
-			case "*LATER*":
-				later = true
-				texts = append(texts, fmt.Sprintf("*[LATER](%s#%s)*", n.Path, nt))
-			default:
+		if strings.HasPrefix(strings.TrimSpace(text), "```") {
+			fenced = !fenced
+		}
+		if ms := headerPattern.FindStringSubmatch(text); ms != nil && !fenced {
+			level := strings.IndexFunc(text, func(r rune) bool { return r != '#' })
+			for len(headers) < level {
+				headers = append(headers, "")
+			}
+			headers = headers[:level]
+			if ts := mdTimePattern.FindStringSubmatch(text); ts != nil {
+				headers[level-1] = ""
+				anchor = n.headerTime(ts[1])
+			} else {
+				headers[level-1] = strings.TrimSpace(ms[1])
+			}
+		}
+		var sections []string
+		for _, h := range headers {
+			if h != "" {
+				sections = append(sections, h)
+			}
+		}
+		section := strings.Join(sections, " > ")
+		depth, parent := 0, 0
+		if !fenced {
+			if listItemPattern.MatchString(text) {
+				indent := indentWidth(text)
+				for len(indents) > 0 && indents[len(indents)-1] >= indent {
+					indents, parents = indents[:len(indents)-1], parents[:len(parents)-1]
+				}
+				depth = len(indents)
+				if depth > 0 {
+					parent = parents[depth-1]
+				}
+				indents, parents = append(indents, indent), append(parents, lineNo)
+			} else if headerPattern.MatchString(text) || (strings.TrimSpace(text) != "" && indentWidth(text) == 0) {
+				indents, parents = nil, nil
+			}
+		}
+		if !fenced {
+			for _, fn := range n.journal.extractLinks(text) {
+				if !linked[fn] {
+					linked[fn] = true
+					n.Links = append(n.Links, fn)
+				}
+			}
+		}
+		if continued >= 0 {
+			if !fenced && n.journal.isContinuation(text) {
+				for i := continued; i < len(tags); i++ {
+					tags[i].Text += " " + strings.TrimSpace(text)
+					if tags[i].Raw != "" {
+						tags[i].Raw += " " + strings.TrimSpace(text)
+					}
+				}
+				return nil
+			}
+			continued = -1
+		}
+		inCode := fenced
+		words := wordPattern.FindAllStringIndex(text, -1)
+		marker := len(checkboxPattern.FindString(text))
+		if marker == 0 {
+			marker = len(listItemPattern.FindString(text))
+		}
+		var body []string
+		for _, loc := range words {
+			w := text[loc[0]:loc[1]]
+			var kind, prio, suffix string
+			var ok bool
+			if !inCode {
+				kind, prio, suffix, ok = n.journal.matchTag(w)
+			}
+			if strings.Count(w, "`")%2 == 1 {
+				inCode = !inCode
+			}
+			if !ok {
+				if ms := duePattern.FindStringSubmatch(w); ms != nil && !inCode {
+					d, err := time.ParseInLocation("2006-01-02", ms[1], n.journal.location())
+					if err != nil {
+						n.journal.warnf("invalid due date '%s' in '%s' line %d", ms[1], n.Path, lineNo)
+					} else {
+						due = &d
+						w = fmt.Sprintf("`@due:%s`", ms[1])
+					}
+				} else if ms := wakePattern.FindStringSubmatch(w); ms != nil && !inCode {
+					d, err := time.ParseInLocation("2006-01-02", ms[1], n.journal.location())
+					if err != nil {
+						n.journal.warnf("invalid wake date '%s' in '%s' line %d", ms[1], n.Path, lineNo)
+					} else {
+						wake = &d
+						w = fmt.Sprintf("`@wake:%s`", ms[1])
+					}
+				} else if ms := projectPattern.FindStringSubmatch(w); ms != nil && !inCode && !hasToken(projects, ms[1]) {
+					projects = append(projects, ms[1])
+				} else if ms := contextPattern.FindStringSubmatch(w); ms != nil && !inCode && !hasToken(contexts, ms[1]) {
+					contexts = append(contexts, ms[1])
+				}
 				texts = append(texts, w)
+				if loc[0] >= marker {
+					body = append(body, w)
+				}
+				continue
+			}
+			label := kind
+			if prio != "" {
+				priority = prio
+				label = fmt.Sprintf("%s:%s", kind, prio)
+			}
+			if kind == "DONE" {
+				done = true
+			} else {
+				kinds = append(kinds, kind)
 			}
+			texts = append(texts, n.journal.tagLink(kind, label, n.Path, anchor, lineNo)+suffix)
+		}
+		task, checked := false, false
+		if ms := checkboxPattern.FindStringSubmatch(text); ms != nil && !fenced {
+			task, checked = true, ms[1] != " "
+		}
+		if done || (checked && len(kinds) > 0) {
+			kinds = []string{"DONE"}
 		}
 		ftext := strings.Join(texts, " ")
-		if doing {
-			doings = append(doings, Tag{note: n, Time: ctime, LineNo: lineNo, Text: ftext})
+		var rawb strings.Builder
+		last := 0
+		for i, loc := range words {
+			rawb.WriteString(text[last:loc[0]])
+			rawb.WriteString(texts[i])
+			last = loc[1]
 		}
-		if todo {
-			todos = append(todos, Tag{note: n, Time: ctime, LineNo: lineNo, Text: ftext})
+		raw := rawb.String()
+		if raw == ftext {
+			raw = ""
 		}
-		if later {
-			laters = append(laters, Tag{note: n, Time: ctime, LineNo: lineNo, Text: ftext})
+		seen := make(map[string]bool)
+		if len(kinds) > 0 && n.journal.MultilineTags {
+			continued = len(tags)
 		}
-		lineNo++
+		for _, kind := range kinds {
+			if !seen[kind] {
+				seen[kind] = true
+				t := Tag{note: n, Time: ctime, LineNo: lineNo, Tag: kind, Priority: priority, Section: section, Due: due, Task: task, Checked: checked, Projects: projects, Contexts: contexts, Text: ftext, Raw: raw, Depth: depth, Parent: parent, body: strings.Join(body, " ")}
+				if kind == "LATER" {
+					t.Wake = wake
+				}
+				tags = append(tags, t)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	if len(doings) > 0 {
-		n.journal.Doings[n.Path] = doings
+	return tags, nil
+}
+
+// isContinuation reports whether text continues the tag on the previous
+// line: it is not blank, a header, a new list item or another tag line.
+func (j *Journal) isContinuation(text string) bool {
+	if strings.TrimSpace(text) == "" || headerPattern.MatchString(text) || listItemPattern.MatchString(text) {
+		return false
+	}
+	for _, w := range strings.Fields(text) {
+		if _, _, _, ok := j.matchTag(w); ok {
+			return false
+		}
+	}
+	return true
+}
+
+func setTags(tagMap map[string][]Tag, fn string, tags []Tag) {
+	if len(tags) > 0 {
+		tagMap[fn] = tags
 	} else {
-		delete(n.journal.Doings, n.Path)
+		delete(tagMap, fn)
+	}
+}
+
+func (n *Note) process() error {
+	if n.journal.isIndex(n.Path) {
+		return nil
+	}
+	tags, err := n.parse()
+	return n.apply(tags, err)
+}
+
+// apply records the result of parse in the journal maps.
+func (n *Note) apply(tags []Tag, err error) error {
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			n.journal.purge(n.Path)
+			return nil
+		}
+		return err
 	}
-	if len(todos) > 0 {
-		n.journal.Todos[n.Path] = todos
+	byKind := make(map[string][]Tag)
+	for _, t := range tags {
+		byKind[t.Tag] = append(byKind[t.Tag], t)
+	}
+	for _, kind := range n.journal.kinds() {
+		setTags(n.journal.tagMap(kind), n.Path, byKind[kind])
+	}
+	if n.fresh {
+		n.recordHistory(tags)
+	}
+	if len(n.Meta) > 0 {
+		n.journal.Meta[n.Path] = n.Meta
 	} else {
-		delete(n.journal.Todos, n.Path)
+		delete(n.journal.Meta, n.Path)
 	}
-	if len(laters) > 0 {
-		n.journal.Laters[n.Path] = laters
+	if len(n.Links) > 0 {
+		n.journal.Links[n.Path] = n.Links
+		n.checkLinks()
 	} else {
-		delete(n.journal.Laters, n.Path)
+		delete(n.journal.Links, n.Path)
 	}
-	now := time.Now()
-	lastYearMonth := now.Year()*12 + int(now.Month()) - 3
-	if ms := dpattern.FindAllStringSubmatch(n.Path, -1); ms != nil && len(ms) == 1 && len(ms[0]) == 6 && ms[0][1] == ms[0][3] && ms[0][2] == ms[0][4] {
-		dt := fmt.Sprintf("%s-%s-%sT00:00:00", ms[0][3], ms[0][4], ms[0][5])
-		dtime, err := time.ParseInLocation("2006-01-02T15:04:05", dt, time.Local)
-		if err != nil {
-			panic(fmt.Sprintf("error date format '%s' %+v", dt, err))
-		}
-		yearMonth := dtime.Year()*12 + int(dtime.Month()) - 1
-		delta := yearMonth - lastYearMonth
-		if delta > 0 {
-			dtg := fmt.Sprintf("%s-%s", ms[0][3], ms[0][4])
-			n.journal.Diary[dtg] = append(n.journal.Diary[dtg], []string{ms[0][5], n.Path})
+	if dtime, ok := n.journal.diaryDate(strings.TrimSuffix(n.Path, ageExt)); ok && !isArchived(n.Path) {
+		if n.journal.inDiaryWindow(dtime) {
+			dtg := dtime.Format("2006-01")
+			for _, d := range n.journal.Diary[dtg] {
+				if d[1] == n.Path {
+					return nil
+				}
+			}
+			n.journal.Diary[dtg] = append(n.journal.Diary[dtg], []string{dtime.Format("02"), n.Path})
 		}
 	}
+	return nil
 }
@@ -0,0 +1,1355 @@
+package diary
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestJournal opens a journal in a temporary directory holding files,
+// with a fake git and the log kept in the returned buffer.
+func newTestJournal(t *testing.T, files map[string]string) (*Journal, *fakeGit, *bytes.Buffer) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	j, err := OpenJournal(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	git := &fakeGit{}
+	j.SetGit(git)
+	var log bytes.Buffer
+	j.SetLog(&log, LogNormal)
+	t.Cleanup(func() {
+		j.Close()
+	})
+	return j, git, &log
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for fn, text := range files {
+		ff := filepath.Join(dir, fn)
+		if err := os.MkdirAll(filepath.Dir(ff), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(ff, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, fn string) string {
+	t.Helper()
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// lineNos returns the line numbers of the tags of fn in tagMap.
+func lineNos(tagMap map[string][]Tag, fn string) []int {
+	var nos []int
+	for _, t := range tagMap[fn] {
+		nos = append(nos, t.LineNo)
+	}
+	return nos
+}
+
+func TestProcessChangesFromGitDiff(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		"notes/a.md": "- *TODO* changed\n",
+		"notes/b.md": "- *TODO* untouched, not reparsed\n",
+		"new.md":     "- *DOING* untracked\n",
+	})
+	j.Hash = "1111111"
+	j.Todos["notes/b.md"] = []Tag{{LineNo: 1, Tag: "TODO", Text: "- *TODO* stored"}}
+	j.Todos["gone.md"] = []Tag{{LineNo: 3, Tag: "TODO", Text: "- *TODO* deleted"}}
+	git.others = "new.md\n"
+	git.diff = "M\tnotes/a.md\nD\tgone.md\n"
+
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if got := git.called("diff"); len(got) != 1 || got[0] != "diff 1111111 --name-status -M" {
+		t.Errorf("diff calls = %q", got)
+	}
+	if _, ok := j.Todos["gone.md"]; ok {
+		t.Errorf("tags of deleted note kept: %v", j.Todos["gone.md"])
+	}
+	if got := j.Todos["notes/a.md"]; len(got) != 1 || !strings.HasSuffix(got[0].Text, " changed") {
+		t.Errorf("changed note = %v", got)
+	}
+	if got := j.Todos["notes/b.md"]; len(got) != 1 || got[0].Text != "- *TODO* stored" {
+		t.Errorf("unchanged note reparsed: %v", got)
+	}
+	if got := j.Doings["new.md"]; len(got) != 1 {
+		t.Errorf("untracked note = %v", got)
+	}
+}
+
+func TestProcessChangesWithoutHashProcessesAll(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		"a.md": "- *TODO* one\n",
+		"b.md": "- *LATER* two\n",
+	})
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if len(git.called("diff")) != 0 {
+		t.Errorf("diff called without a hash: %q", git.calls)
+	}
+	if len(j.Todos["a.md"]) != 1 || len(j.Laters["b.md"]) != 1 {
+		t.Errorf("todos %v, laters %v", j.Todos, j.Laters)
+	}
+}
+
+func TestCommitStagesAndCommitsChanges(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	git.status = " M index.md\n?? a.md\n"
+	git.head = "abc123"
+	if err := j.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"add", "status", "rev-parse HEAD", "add", "commit"}
+	if len(git.calls) != len(want) {
+		t.Fatalf("calls = %q, want %q", git.calls, want)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(git.calls[i], w) {
+			t.Errorf("call %d = %q, want %q", i, git.calls[i], w)
+		}
+	}
+	if j.Hash != "abc123" {
+		t.Errorf("Hash = %q, want abc123", j.Hash)
+	}
+	if !strings.Contains(readFile(t, j.configPath()), `"Hash": "abc123"`) {
+		t.Errorf("config not written with the new Hash")
+	}
+	if len(git.called("push")) != 0 {
+		t.Errorf("commit pushed: %q", git.calls)
+	}
+}
+
+func TestCommitSkipsCleanTree(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	if err := j.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(git.called("commit")) != 0 || j.Hash != "" {
+		t.Errorf("clean tree committed: %q", git.calls)
+	}
+}
+
+func TestCommitReturnsGitError(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	git.status = " M index.md\n"
+	git.errs = map[string]error{"commit": os.ErrPermission}
+	if err := j.Commit(); err == nil {
+		t.Fatal("Commit succeeded on a failing git commit")
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, tc := range []struct{ path, want string }{
+		{"~", home},
+		{"~/journal", filepath.Join(home, "journal")},
+		{"/abs/journal/", "/abs/journal"},
+	} {
+		got, err := ExpandPath(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("ExpandPath(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+	wd, _ := os.Getwd()
+	if got, _ := ExpandPath("journal"); got != filepath.Join(wd, "journal") {
+		t.Errorf("relative path not made absolute: %q", got)
+	}
+}
+
+func TestOpenJournalFromDiaryHome(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DIARY_HOME", dir)
+	j, err := OpenJournal(os.Getenv("DIARY_HOME")+"/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.path != dir || j.configPath() != filepath.Join(dir, ".journal.json") {
+		t.Errorf("path %q, config %q", j.path, j.configPath())
+	}
+}
+
+func TestDoneClosesOnlyItsLine(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"todo.md": "# Tasks\n- *TODO* still open\n- *DONE* finished\n- *TODO* closed *DONE*\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := lineNos(j.Todos, "todo.md"); len(got) != 1 || got[0] != 2 {
+		t.Errorf("open todo lines = %v, want [2]", got)
+	}
+	if got := lineNos(j.Done, "todo.md"); len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Errorf("done lines = %v, want [3 4]", got)
+	}
+	var out bytes.Buffer
+	if err := j.RenderIndex(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "still open") || strings.Contains(out.String(), "closed") {
+		t.Errorf("index:\n%s", out.String())
+	}
+}
+
+// setNow fixes the time of j at the RFC 3339 time now.
+func setNow(t *testing.T, j *Journal, now string) {
+	t.Helper()
+	at, err := time.Parse(time.RFC3339, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.clock = func() time.Time { return at }
+}
+
+func TestDiaryWindowOfOneMonth(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/03/2024-03-02.md": "# Note\n",
+		"2024/03/2024-03-20.md": "# Note\n",
+		"2024/02/2024-02-28.md": "# Note\n",
+		"2023/12/2023-12-01.md": "# Note\n",
+	})
+	setNow(t, j, "2024-03-25T12:00:00Z")
+	j.SetDiaryMonths(1)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Diary) != 1 || len(j.Diary["2024-03"]) != 2 {
+		t.Errorf("Diary = %v, want only 2024-03", j.Diary)
+	}
+}
+
+func TestDiaryWindowFromConfig(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/03/2024-03-02.md": "# Note\n",
+		"2024/02/2024-02-28.md": "# Note\n",
+		"2024/01/2024-01-10.md": "# Note\n",
+	})
+	setNow(t, j, "2024-03-25T12:00:00Z")
+	j.DiaryMonths = 2
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := j.Diary["2024-01"]; ok || len(j.Diary) != 2 {
+		t.Errorf("Diary = %v, want 2024-02 and 2024-03", j.Diary)
+	}
+}
+
+func renderIndex(t *testing.T, j *Journal) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := j.RenderIndex(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestWaitingOnlyNote(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"blocked.md": "- *WAITING* reply from ops\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Waitings["blocked.md"]) != 1 || len(j.Todos)+len(j.Doings)+len(j.Laters) != 0 {
+		t.Fatalf("waitings %v, todos %v, doings %v, laters %v", j.Waitings, j.Todos, j.Doings, j.Laters)
+	}
+	want := "# WAITING\n\n- *[WAITING](blocked.md)* reply from ops\n"
+	if got := renderIndex(t, j); !strings.HasSuffix(got, want) {
+		t.Errorf("index:\n%s\nwant suffix:\n%s", got, want)
+	}
+}
+
+func TestTagStyles(t *testing.T) {
+	note := "- *TODO* starred\n- call #todo:A mom\n- #doing, inline\n"
+	for _, tc := range []struct {
+		style string
+		todos []int
+		doing []int
+	}{
+		{"asterisk", []int{1}, nil},
+		{"hashtag", []int{2}, []int{3}},
+		{"both", []int{1, 2}, []int{3}},
+	} {
+		t.Run(tc.style, func(t *testing.T) {
+			j, _, _ := newTestJournal(t, map[string]string{
+				".journal.json": `{"TagStyle": "` + tc.style + `"}`,
+				"mixed.md":      note,
+			})
+			if err := j.ProcessAll(); err != nil {
+				t.Fatal(err)
+			}
+			if got := lineNos(j.Todos, "mixed.md"); fmt.Sprint(got) != fmt.Sprint(tc.todos) {
+				t.Errorf("todo lines = %v, want %v", got, tc.todos)
+			}
+			if got := lineNos(j.Doings, "mixed.md"); fmt.Sprint(got) != fmt.Sprint(tc.doing) {
+				t.Errorf("doing lines = %v, want %v", got, tc.doing)
+			}
+		})
+	}
+}
+
+func TestHashtagPriorityAndLink(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"TagStyle": "hashtag"}`,
+		"mixed.md":      "- call #todo:A mom\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	tags := j.Todos["mixed.md"]
+	if len(tags) != 1 || tags[0].Priority != "A" || tags[0].Text != "- call *[TODO:A](mixed.md)* mom" {
+		t.Errorf("tags = %v", tags)
+	}
+}
+
+func TestUnknownTagStyle(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".journal.json": `{"TagStyle": "emoji"}`})
+	if _, err := OpenJournal(dir, ""); err == nil || !strings.Contains(err.Error(), "TagStyle") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestNoAutoPushRunsNoNetworkGit(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{"a.md": "- *TODO* a\n"})
+	if err := j.StartAutoPush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if calls := append(git.called("push"), git.called("pull")...); len(calls) != 0 {
+		t.Errorf("network git commands without AutoPush: %q", calls)
+	}
+}
+
+func TestAutoPushWaitsOnPush(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	j.AutoPush = true
+	if err := j.StartAutoPush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if calls := git.called("push"); len(calls) != 1 {
+		t.Errorf("push calls = %q, want one", calls)
+	}
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		var out bytes.Buffer
+		out.ReadFrom(r)
+		done <- out.String()
+	}()
+	ferr := fn()
+	os.Stdout = stdout
+	w.Close()
+	out := <-done
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+	return out
+}
+
+func TestDryRunWritesAndCommitsNothing(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{"a.md": "- *TODO* a\n"})
+	j.SetDryRun(true)
+	out := captureStdout(t, func() error {
+		if err := j.ProcessChanges(); err != nil {
+			return err
+		}
+		if err := j.Write(); err != nil {
+			return err
+		}
+		return j.Close()
+	})
+	if !strings.Contains(out, "# TODO\n\n- *[TODO](a.md)* a\n") {
+		t.Errorf("dry run output:\n%s", out)
+	}
+	entries, err := os.ReadDir(j.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("dry run created files: %v", entries)
+	}
+	for _, c := range git.calls {
+		if !strings.HasPrefix(c, "ls-files") {
+			t.Errorf("dry run ran git %q, only reads are allowed", c)
+		}
+	}
+}
+
+func TestTagSectionFromNestedHeaders(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"work.md": "- *TODO* top\n# Work\n- *TODO* in work\n## Backend\n### API\n- *TODO* in api\n## Frontend\n- *TODO* in frontend\n# Home\n- *TODO* at home\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]string{1: "", 3: "Work", 6: "Work > Backend > API", 8: "Work > Frontend", 10: "Home"}
+	tags := j.Todos["work.md"]
+	if len(tags) != len(want) {
+		t.Fatalf("tags = %v", tags)
+	}
+	for _, tag := range tags {
+		if tag.Section != want[tag.LineNo] {
+			t.Errorf("line %d section = %q, want %q", tag.LineNo, tag.Section, want[tag.LineNo])
+		}
+	}
+	if !strings.Contains(renderIndex(t, j), "in api _(Work > Backend > API)_\n") {
+		t.Errorf("index does not show the section:\n%s", renderIndex(t, j))
+	}
+}
+
+func TestTimeHeaderIsNotASection(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/01/2024-01-05.md": "# Note\n## Plans\n## 09:00:00\n- *TODO* after time\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if tags := j.Todos["2024/01/2024-01-05.md"]; len(tags) != 1 || tags[0].Section != "Note" {
+		t.Errorf("tags = %v, want section Note", tags)
+	}
+}
+
+func TestDeletedNoteTagsDisappear(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		"keep.md": "- *TODO* keep\n",
+		"gone.md": "- *TODO* gone\n- *DOING* also gone\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	git.status = "?? gone.md\n?? keep.md\n"
+	git.head = "c0ffee"
+	if err := j.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if j.Hash != "c0ffee" || len(j.Todos["gone.md"]) != 1 {
+		t.Fatalf("Hash %q, todos %v", j.Hash, j.Todos)
+	}
+	if err := os.Remove(filepath.Join(j.path, "gone.md")); err != nil {
+		t.Fatal(err)
+	}
+	git.diff = "D\tgone.md\n"
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := j.Todos["gone.md"]; ok {
+		t.Errorf("todos of deleted note kept: %v", j.Todos)
+	}
+	if _, ok := j.Doings["gone.md"]; ok {
+		t.Errorf("doings of deleted note kept: %v", j.Doings)
+	}
+	if len(j.Todos["keep.md"]) != 1 {
+		t.Errorf("todos of kept note lost: %v", j.Todos)
+	}
+}
+
+func TestNoteDeletedOutsideTheDiffIsPurged(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{"keep.md": "- *TODO* keep\n"})
+	j.Hash = "c0ffee"
+	j.Todos["uncommitted.md"] = []Tag{{LineNo: 1, Tag: "TODO", Text: "- *TODO* x"}}
+	j.Meta["uncommitted.md"] = map[string]string{"title": "x"}
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.knownPaths()) != 0 {
+		t.Errorf("known paths = %v, want none", j.knownPaths())
+	}
+}
+
+func tagClocks(tags []Tag) []string {
+	var clocks []string
+	for _, t := range tags {
+		clocks = append(clocks, t.Time.Format("15:04:05"))
+	}
+	return clocks
+}
+
+func TestTimeHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name, note string
+		want       []string
+	}{
+		{"none", "# Note\n- *TODO* a\n- *TODO* b\n", []string{"00:00:00", "00:00:00"}},
+		{"one", "# Note\n- *TODO* before\n## 09:30:00\n- *TODO* after\n", []string{"00:00:00", "09:30:00"}},
+		{"several", "# Note\n## 08:00:00\n- *TODO* a\n## 12:15\n- *TODO* b\n## 18:00:01\n- *TODO* c\n", []string{"08:00:00", "12:15:00", "18:00:01"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			j, _, log := newTestJournal(t, map[string]string{"2024/01/2024-01-05.md": tc.note})
+			if err := j.ProcessAll(); err != nil {
+				t.Fatal(err)
+			}
+			if got := tagClocks(j.Todos["2024/01/2024-01-05.md"]); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("times = %v, want %v", got, tc.want)
+			}
+			if log.Len() != 0 {
+				t.Errorf("unexpected log %q", log.String())
+			}
+		})
+	}
+}
+
+const outOfOrderNote = "# Note\n## 10:00:00\n- *TODO* a\n## 09:00:00\n- *TODO* b\n"
+
+func TestOutOfOrderTimeHeadersWarn(t *testing.T) {
+	j, _, log := newTestJournal(t, map[string]string{"2024/01/2024-01-05.md": outOfOrderNote})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := tagClocks(j.Todos["2024/01/2024-01-05.md"]); fmt.Sprint(got) != "[10:00:00 09:00:00]" {
+		t.Errorf("times = %v", got)
+	}
+	if !strings.Contains(log.String(), "time header 09:00:00 in '2024/01/2024-01-05.md' line 4 is earlier than previous 10:00:00") {
+		t.Errorf("log = %q", log.String())
+	}
+}
+
+func TestOutOfOrderTimeHeadersStrict(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{"2024/01/2024-01-05.md": outOfOrderNote})
+	j.SetStrict(true)
+	if err := j.ProcessAll(); err == nil || !strings.Contains(err.Error(), "is earlier than previous") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCustomIndexAndConfigNames(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.md":          "- *TODO* a\n",
+		"settings.json": `{"Priorities": ["H", "L"]}`,
+	})
+	j, err := OpenJournal(dir, "settings.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.SetGit(&fakeGit{})
+	j.SetLog(&bytes.Buffer{}, LogNormal)
+	j.SetIndex("tasks.md")
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dir, "tasks.md")); !strings.Contains(got, "- *[TODO](a.md)* a\n") {
+		t.Errorf("tasks.md:\n%s", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "tasks.json")); !strings.Contains(got, `"path": "a.md"`) {
+		t.Errorf("tasks.json:\n%s", got)
+	}
+	for _, fn := range []string{"index.md", "index.json", ".journal.json"} {
+		if _, err := os.Stat(filepath.Join(dir, fn)); !os.IsNotExist(err) {
+			t.Errorf("default %s written", fn)
+		}
+	}
+	if got := readFile(t, filepath.Join(dir, "settings.json")); !strings.Contains(got, `"H"`) || !strings.Contains(got, `"a.md"`) {
+		t.Errorf("settings.json:\n%s", got)
+	}
+
+	next, err := OpenJournal(dir, "settings.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next.SetGit(&fakeGit{})
+	next.SetIndex("tasks.md")
+	if err := next.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(next.knownPaths()) != 1 || len(next.Priorities) != 2 {
+		t.Errorf("known paths %v, priorities %v", next.knownPaths(), next.Priorities)
+	}
+}
+
+func TestTimezoneOfTagTimes(t *testing.T) {
+	note := map[string]string{"2024/03/2024-03-01.md": "# Note\n## 09:00:00\n- *TODO* standup\n"}
+	times := make(map[string]time.Time)
+	for _, zone := range []string{"UTC", "Asia/Jakarta"} {
+		files := map[string]string{".journal.json": `{"Timezone": "` + zone + `"}`}
+		for fn, text := range note {
+			files[fn] = text
+		}
+		j, _, _ := newTestJournal(t, files)
+		if err := j.ProcessAll(); err != nil {
+			t.Fatal(err)
+		}
+		tag := j.Todos["2024/03/2024-03-01.md"][0]
+		if got := tag.Time.Format("2006-01-02 15:04:05 MST"); got != "2024-03-01 09:00:00 "+map[string]string{"UTC": "UTC", "Asia/Jakarta": "WIB"}[zone] {
+			t.Errorf("%s: time = %s", zone, got)
+		}
+		times[zone] = tag.Time
+	}
+	if d := times["UTC"].Sub(times["Asia/Jakarta"]); d != 7*time.Hour {
+		t.Errorf("UTC - Jakarta = %s, want 7h", d)
+	}
+}
+
+func TestUnknownTimezone(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".journal.json": `{"Timezone": "Mars/Olympus"}`})
+	if _, err := OpenJournal(dir, ""); err == nil || !strings.Contains(err.Error(), "unknown Timezone 'Mars/Olympus'") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestMaxPerSection(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"MaxPerSection": 2}`,
+		"2024/03/2024-03-01.md": "# Note\n## 09:00:00\n- *DOING* first\n## 10:00:00\n- *DOING* second\n## 11:00:00\n- *DOING* third\n## 12:00:00\n- *DOING* fourth\n- *TODO* only\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	index := renderIndex(t, j)
+	doing := index[:strings.Index(index, "# TODO")]
+	if !strings.Contains(doing, "fourth") || !strings.Contains(doing, "third") || strings.Contains(doing, "second") || strings.Contains(doing, "first") {
+		t.Errorf("DOING not limited to the newest two:\n%s", doing)
+	}
+	if !strings.Contains(doing, "\n... and 2 more, see [DOING](index-doing.md)\n") {
+		t.Errorf("missing more line:\n%s", doing)
+	}
+	if strings.Contains(index[strings.Index(index, "# TODO"):], "more") {
+		t.Errorf("more line below a section within the limit:\n%s", index)
+	}
+
+	j.SetNoCommit(true)
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	listing := readFile(t, filepath.Join(j.path, "index-doing.md"))
+	for _, text := range []string{"first", "second", "third", "fourth"} {
+		if !strings.Contains(listing, text) {
+			t.Errorf("full listing misses %q:\n%s", text, listing)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(j.path, "index-todo.md")); !os.IsNotExist(err) {
+		t.Errorf("listing written for a section within the limit: %v", err)
+	}
+}
+
+func TestPlainDirectoryWithoutGit(t *testing.T) {
+	hideGitIdentity(t)
+	dir := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+	writeFiles(t, dir, map[string]string{"a.md": "- *TODO* local only\n"})
+	j, err := OpenJournal(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	var log bytes.Buffer
+	j.SetLog(&log, LogNormal)
+	j.Hash = "1111111"
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Todos["a.md"]) != 1 {
+		t.Errorf("todos = %v", j.Todos)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if index := readFile(t, filepath.Join(dir, "index.md")); !strings.Contains(index, "local only") {
+		t.Errorf("index:\n%s", index)
+	}
+	if err := j.Commit(); err != nil {
+		t.Errorf("commit: %v", err)
+	}
+	if err := j.Push(); err != nil {
+		t.Errorf("push: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); !os.IsNotExist(err) {
+		t.Errorf("repository created: %v", err)
+	}
+	if n := strings.Count(log.String(), "is not a git repository"); n != 1 {
+		t.Errorf("warned %d times:\n%s", n, log.String())
+	}
+}
+
+const wrappedTodos = "# Note\n- *TODO* single line\n- *TODO* wrapped over\n  two more\n  lines\n- next item\n*TODO* until the blank\nline\n\nafter blank\n- *TODO* stops at\n*DOING* another tag\n- *TODO* stops at header\n## 10:00\n"
+
+func TestMultilineTags(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"MultilineTags": true}`,
+		"a.md":          wrappedTodos,
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tag := range j.Todos["a.md"] {
+		got = append(got, fmt.Sprintf("%d %s", tag.LineNo, tag.Text[strings.Index(tag.Text, "* ")+2:]))
+	}
+	want := []string{"2 single line", "3 wrapped over two more lines", "7 until the blank line", "11 stops at", "13 stops at header"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("todos:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if doing := j.Doings["a.md"]; len(doing) != 1 || !strings.HasSuffix(doing[0].Text, " another tag") {
+		t.Errorf("doing = %v", doing)
+	}
+}
+
+func TestMultilineTagsOff(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{"a.md": wrappedTodos})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if tag := j.Todos["a.md"][1]; !strings.HasSuffix(tag.Text, "* wrapped over") {
+		t.Errorf("continuation joined by default: %s", tag.Text)
+	}
+}
+
+func TestRangeBoundsInclusive(t *testing.T) {
+	files := map[string]string{
+		"2024/02/2024-02-29.md": "# Note\n- *TODO* before\n",
+		"2024/03/2024-03-01.md": "# Note\n- *TODO* first day\n",
+		"2024/03/2024-03-02.md": "# Note\n- *TODO* last day\n",
+		"2024/03/2024-03-03.md": "# Note\n- *TODO* after\n",
+		"notes/plan.md":         "- *TODO* plain note\n",
+	}
+	for _, c := range []struct {
+		since, until string
+		notes        bool
+		want         string
+	}{
+		{"2024-03-01", "2024-03-02", false, "2024/03/2024-03-01.md 2024/03/2024-03-02.md"},
+		{"2024-03-01", "2024-03-02", true, "2024/03/2024-03-01.md 2024/03/2024-03-02.md notes/plan.md"},
+		{"2024-03-02", "", false, "2024/03/2024-03-02.md 2024/03/2024-03-03.md"},
+		{"", "2024-02-29", false, "2024/02/2024-02-29.md"},
+		{"", "", false, "2024/02/2024-02-29.md 2024/03/2024-03-01.md 2024/03/2024-03-02.md 2024/03/2024-03-03.md notes/plan.md"},
+	} {
+		j, _, _ := newTestJournal(t, files)
+		if err := j.SetRange(c.since, c.until, c.notes); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.ProcessAll(); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for fn := range j.Todos {
+			got = append(got, fn)
+		}
+		sort.Strings(got)
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("range %s..%s notes %t: %s, want %s", c.since, c.until, c.notes, strings.Join(got, " "), c.want)
+		}
+	}
+}
+
+func TestRangeInvalidDate(t *testing.T) {
+	j, _, _ := newTestJournal(t, nil)
+	if err := j.SetRange("2024-3-1", "", false); err == nil || !strings.Contains(err.Error(), "invalid date '2024-3-1'") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCloseWaitsForBackgroundPush(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	j.AutoPush = true
+	release := make(chan struct{})
+	git.onPush = func() { <-release }
+	git.errs = map[string]error{"push": errors.New("remote rejected")}
+	if err := j.StartAutoPush(); err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan error)
+	go func() {
+		closed <- j.Close()
+	}()
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned before the push finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-closed:
+		if err == nil || err.Error() != "remote rejected" {
+			t.Errorf("err = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return after the push finished")
+	}
+	if len(git.called("push")) != 1 {
+		t.Errorf("calls = %q", git.calls)
+	}
+}
+
+func TestCloseReleasesLockAndSavesCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.md": "- *TODO* a\n"})
+	j, err := OpenLockedJournal(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.SetGit(&fakeGit{})
+	j.SetLog(&bytes.Buffer{}, LogQuiet)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, cacheFile)); err != nil {
+		t.Errorf("cache not saved: %v", err)
+	}
+	next, err := OpenLockedJournal(dir, "")
+	if err != nil {
+		t.Fatalf("lock kept after Close: %v", err)
+	}
+	next.Close()
+}
+
+func TestCheckboxTodos(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"a.md": "- [ ] *TODO* open box\n- [x] *TODO* checked box\n* [X] *WAITING* upper case\n+ [ ] *TODO*: plus marker\n- *TODO* no box\n- [ ] plain task\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	var todos []string
+	for _, tag := range j.Todos["a.md"] {
+		todos = append(todos, fmt.Sprintf("%d %t %t", tag.LineNo, tag.Task, tag.Checked))
+	}
+	if want := []string{"1 true false", "4 true false", "5 false false"}; strings.Join(todos, "|") != strings.Join(want, "|") {
+		t.Errorf("todos = %q, want %q", todos, want)
+	}
+	if len(j.Waitings["a.md"]) != 0 {
+		t.Errorf("checked waiting kept: %v", j.Waitings["a.md"])
+	}
+	var done []string
+	for _, tag := range j.Done["a.md"] {
+		done = append(done, fmt.Sprintf("%d %t", tag.LineNo, tag.Checked))
+	}
+	if want := []string{"2 true", "3 true"}; strings.Join(done, "|") != strings.Join(want, "|") {
+		t.Errorf("done = %q, want %q", done, want)
+	}
+	index := renderIndex(t, j)
+	for _, line := range []string{"- [ ] *[TODO](a.md)* open box\n", "+ [ ] *[TODO](a.md)*: plus marker\n"} {
+		if !strings.Contains(index, line) {
+			t.Errorf("index misses %q:\n%s", line, index)
+		}
+	}
+	if strings.Contains(index, "checked box") || strings.Contains(index, "upper case") || strings.Contains(index, "plain task") {
+		t.Errorf("index:\n%s", index)
+	}
+}
+
+func TestTimeHeaderForms(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"Timezone": "UTC"}`,
+		"2024/03/2024-03-01.md": "# Note\n## 09:15\n- *TODO* short\n## 10:30:45\n- *TODO* canonical\n## NOW\n- *TODO* jotted\n",
+	})
+	ff := filepath.Join(j.path, "2024/03/2024-03-01.md")
+	written := time.Date(2024, 3, 1, 18, 20, 5, 0, time.UTC)
+	if err := os.Chtimes(ff, written, written); err != nil {
+		t.Fatal(err)
+	}
+	var first []string
+	for i, now := range []string{"2024-03-01T21:00:00Z", "2024-03-02T08:00:00Z"} {
+		setNow(t, j, now)
+		j.cache = nil
+		os.Remove(filepath.Join(j.path, cacheFile))
+		if err := j.ProcessAll(); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tag := range j.Todos["2024/03/2024-03-01.md"] {
+			got = append(got, tag.Time.Format("2006-01-02 15:04:05"))
+		}
+		if i == 0 {
+			first = got
+		} else if strings.Join(got, "|") != strings.Join(first, "|") {
+			t.Errorf("times moved with the clock: %q, then %q", first, got)
+		}
+	}
+	if want := []string{"2024-03-01 09:15:00", "2024-03-01 10:30:45", "2024-03-01 18:20:05"}; strings.Join(first, "|") != strings.Join(want, "|") {
+		t.Errorf("times = %q, want %q", first, want)
+	}
+	n, err := j.NewNote("2024/03/2024-03-01.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := n.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# Note\n## 09:15:00\n- *TODO* short\n## 10:30:45\n- *TODO* canonical\n## 18:20:05\n- *TODO* jotted\n"; rendered != want {
+		t.Errorf("render:\n%s\nwant:\n%s", rendered, want)
+	}
+}
+
+var twoDaysOfDoing = map[string]string{
+	"2024/03/2024-03-01.md": "## 09:00:00\n- *DOING* first day morning\n## 15:00:00\n- *DOING* first day afternoon\n",
+	"2024/03/2024-03-02.md": "## 08:00:00\n- *DOING* second day\n",
+}
+
+func doingSection(t *testing.T, j *Journal) string {
+	t.Helper()
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	index := renderIndex(t, j)
+	return index[:strings.Index(index, "\n# TODO")]
+}
+
+func TestGroupByDay(t *testing.T) {
+	files := map[string]string{".journal.json": `{"Timezone": "UTC", "GroupByDay": true}`}
+	for fn, text := range twoDaysOfDoing {
+		files[fn] = text
+	}
+	j, _, _ := newTestJournal(t, files)
+	want := "# DOING\n\n" +
+		"### 2024-03-02\n" +
+		"- *[DOING](2024/03/2024-03-02.md#08:00:00)* second day\n" +
+		"### 2024-03-01\n" +
+		"- *[DOING](2024/03/2024-03-01.md#15:00:00)* first day afternoon\n" +
+		"- *[DOING](2024/03/2024-03-01.md#09:00:00)* first day morning\n"
+	if got := doingSection(t, j); got != want {
+		t.Errorf("grouped:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFlatByDefault(t *testing.T) {
+	files := map[string]string{".journal.json": `{"Timezone": "UTC"}`}
+	for fn, text := range twoDaysOfDoing {
+		files[fn] = text
+	}
+	j, _, _ := newTestJournal(t, files)
+	want := "# DOING\n\n" +
+		"- *[DOING](2024/03/2024-03-02.md#08:00:00)* second day\n" +
+		"- *[DOING](2024/03/2024-03-01.md#15:00:00)* first day afternoon\n" +
+		"- *[DOING](2024/03/2024-03-01.md#09:00:00)* first day morning\n"
+	if got := doingSection(t, j); got != want {
+		t.Errorf("flat:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteNoCommit(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		config string
+		flag   bool
+	}{
+		{"flag", `{}`, true},
+		{"config", `{"NoCommit": true}`, false},
+	} {
+		j, git, _ := newTestJournal(t, map[string]string{".journal.json": c.config, "a.md": "- *TODO* a\n"})
+		j.SetNoCommit(c.flag)
+		git.status = " M index.md\n"
+		if err := j.ProcessAll(); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Write(); err != nil {
+			t.Fatal(err)
+		}
+		if index := readFile(t, filepath.Join(j.path, "index.md")); !strings.Contains(index, "*[TODO](a.md)* a") {
+			t.Errorf("%s: index:\n%s", c.name, index)
+		}
+		if len(git.called("add")) != 0 || len(git.called("commit")) != 0 {
+			t.Errorf("%s: git calls = %q", c.name, git.calls)
+		}
+	}
+}
+
+func TestRawTagLine(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"a.md": "  - *TODO*   spaced   out\n- *TODO* plain\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	tags := j.Todos["a.md"]
+	if len(tags) != 2 {
+		t.Fatalf("tags = %v", tags)
+	}
+	if tags[0].Text != "- *[TODO](a.md)* spaced out" || tags[0].Raw != "  - *[TODO](a.md)*   spaced   out" {
+		t.Errorf("text %q, raw %q", tags[0].Text, tags[0].Raw)
+	}
+	if tags[1].Raw != "" {
+		t.Errorf("raw of a plain line = %q", tags[1].Raw)
+	}
+	if index := renderIndex(t, j); !strings.Contains(index, "\n- *[TODO](a.md)* spaced out\n") {
+		t.Errorf("index:\n%s", index)
+	}
+	j.IndexRaw = true
+	if index := renderIndex(t, j); !strings.Contains(index, "\n  - *[TODO](a.md)*   spaced   out\n") {
+		t.Errorf("raw index:\n%s", index)
+	}
+}
+
+func TestUnchangedWriteStagesNothing(t *testing.T) {
+	g, _ := realGit(t)
+	g.log.level = LogQuiet
+	writeFiles(t, g.dir, map[string]string{
+		".journal.json": `{"Timezone": "UTC", "AuthorName": "Diary Bot", "AuthorEmail": "bot@example.com"}`,
+		".gitignore":    cacheFile + "\n",
+		"a.md":          "- *TODO* a\n",
+	})
+	if err := g.Add("."); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Commit("init journal", "Diary Bot", "bot@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	j, err := OpenJournal(g.dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.SetGit(g)
+	j.SetLog(&bytes.Buffer{}, LogNormal)
+	update := func() {
+		t.Helper()
+		if err := j.ProcessChanges(); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update()
+	head, err := g.RevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := j.indexPath()
+	before := readFile(t, index)
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(index, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	update()
+	if after, err := g.RevParse("HEAD"); err != nil || after != head {
+		t.Errorf("head %s, was %s: %v", after, head, err)
+	}
+	if status, err := g.Status(); err != nil || status != "" {
+		t.Errorf("status %q: %v", status, err)
+	}
+	if st, err := os.Stat(index); err != nil || !st.ModTime().Equal(old) {
+		t.Errorf("index rewritten: %v %v", st.ModTime(), err)
+	}
+	if after := readFile(t, index); after != before {
+		t.Errorf("index changed:\n%s\n%s", before, after)
+	}
+}
+
+func TestNestedTodos(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"a.md": "- *TODO* parent\n  - *TODO* child\n    - *TODO* grandchild\n  - *TODO* second child\n- *TODO* next\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	var depths []int
+	for _, tag := range j.Todos["a.md"] {
+		depths = append(depths, tag.Depth)
+	}
+	if fmt.Sprint(depths) != "[0 1 2 1 0]" {
+		t.Errorf("depths = %v", depths)
+	}
+	var out bytes.Buffer
+	j.writeTags(&out, j.Todos, 0, true)
+	want := "- *[TODO](a.md)* parent\n" +
+		"  - *[TODO](a.md)* child\n" +
+		"    - *[TODO](a.md)* grandchild\n" +
+		"  - *[TODO](a.md)* second child\n" +
+		"- *[TODO](a.md)* next\n"
+	if out.String() != want {
+		t.Errorf("index:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestNestingNeedsParentTag(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"a.md": "- *TODO* earlier\n- *DOING* x\n  - *TODO* sub of doing\n- plain item\n  - *TODO* sub of plain\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	j.writeTags(&out, j.Todos, 0, true)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if strings.HasPrefix(line, " ") {
+			t.Errorf("nested below an unrelated tag:\n%s", out.String())
+			break
+		}
+	}
+	if got := strings.Count(out.String(), "\n"); got != 3 {
+		t.Errorf("index:\n%s", out.String())
+	}
+}
+
+func TestTimeFormatHHMM(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{".journal.json": `{"Timezone": "UTC", "TimeFormat": "HH:MM"}`})
+	j.SetNoEdit(true)
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-04T09:30:45Z")
+	if err := j.CreateDiary(); err != nil {
+		t.Fatal(err)
+	}
+	fn := "2024/03/2024-03-04.md"
+	ff := filepath.Join(j.path, fn)
+	if got := readFile(t, ff); got != "# Note 2024-03-04\n\n## 09:30\n\n\n" {
+		t.Fatalf("new day = %q", got)
+	}
+	writeFiles(t, j.path, map[string]string{fn: readFile(t, ff) + "- *TODO* morning\n\n## 14:05\n- *TODO* afternoon\n"})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	tags := j.Todos[fn]
+	if len(tags) != 2 || tags[0].Time.Format("15:04:05") != "09:30:00" || tags[1].Time.Format("15:04:05") != "14:05:00" {
+		t.Fatalf("tags = %v", tags)
+	}
+	if tags[1].Text != "- *[TODO]("+fn+"#14:05)* afternoon" {
+		t.Errorf("text = %q", tags[1].Text)
+	}
+}
+
+func TestUnknownTimeFormat(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".journal.json": `{"TimeFormat": "hh:mm"}`})
+	if _, err := OpenJournal(dir, ""); err == nil || !strings.Contains(err.Error(), "unknown TimeFormat 'hh:mm'") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCRLFNote(t *testing.T) {
+	lf := "# Note\n\n## 09:00:00\n- *TODO* call bob\n- *DOING:A* review\n\n## 10:30:00\n- #waiting reply *LATER*\n"
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"Timezone": "UTC", "TagStyle": "both"}`,
+		"2024/03/2024-03-01.md": lf,
+		"2024/03/2024-03-02.md": strings.ReplaceAll(lf, "\n", "\r\n"),
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	strip := func(tags []Tag) string {
+		var got []string
+		for _, tag := range tags {
+			got = append(got, fmt.Sprintf("%d %s %s %s %q", tag.LineNo, tag.Priority, tag.Time.Format("15:04:05"), tag.Section, strings.ReplaceAll(tag.Text, "03-02", "03-01")))
+		}
+		return strings.Join(got, "\n")
+	}
+	for _, kind := range j.kinds() {
+		m := j.tagMap(kind)
+		if a, b := strip(m["2024/03/2024-03-01.md"]), strip(m["2024/03/2024-03-02.md"]); a != b {
+			t.Errorf("%s tags differ:\nLF:\n%s\nCRLF:\n%s", kind, a, b)
+		}
+	}
+	if todos := j.Todos["2024/03/2024-03-02.md"]; len(todos) != 1 || strings.Contains(todos[0].Text, "\r") {
+		t.Errorf("todos = %v", todos)
+	}
+	if len(j.Waitings["2024/03/2024-03-02.md"]) != 1 || len(j.Laters["2024/03/2024-03-02.md"]) != 1 {
+		t.Errorf("waitings %v, laters %v", j.Waitings, j.Laters)
+	}
+}
+
+func TestNoEditBuildsNoEditorCommand(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{".journal.json": `{"Timezone": "UTC"}`})
+	j.Editor = "/nonexistent/editor"
+	j.SetNoEdit(true)
+	setNow(t, j, "2024-03-04T09:30:00Z")
+	ff := filepath.Join(j.path, "2024-03-04.md")
+	cmd, remote, err := j.diaryCommand(ff, j.now())
+	if err != nil || cmd != nil || remote != nil {
+		t.Fatalf("cmd %v, remote %v, err %v", cmd, remote, err)
+	}
+	if got := readFile(t, ff); got != "# Note 2024-03-04\n\n## 09:30:00\n\n\n" {
+		t.Errorf("seeded note = %q", got)
+	}
+	// an existing note is left alone
+	if _, _, err := j.diaryCommand(ff, j.now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, ff); got != "# Note 2024-03-04\n\n## 09:30:00\n\n\n" {
+		t.Errorf("existing note = %q", got)
+	}
+}
+
+func TestProcessChangesRename(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"Timezone": "UTC"}`,
+		"2024/03/2024-03-02.md": "# Note\n\n## 09:00:00\n- *TODO* moved\n",
+	})
+	setNow(t, j, "2024-03-05T12:00:00Z")
+	j.Hash = "1111111"
+	j.Todos["2024/03/2024-03-01.md"] = []Tag{{LineNo: 4, Tag: "TODO", Text: "- *TODO* moved"}}
+	j.Diary = map[string][][]string{"2024-03": {{"01", "2024/03/2024-03-01.md"}}}
+	git.diff = "R097\t2024/03/2024-03-01.md\t2024/03/2024-03-02.md\n"
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := j.Todos["2024/03/2024-03-01.md"]; ok || len(j.Todos["2024/03/2024-03-02.md"]) != 1 {
+		t.Errorf("todos = %v", j.Todos)
+	}
+	if days := fmt.Sprint(j.Diary["2024-03"]); days != "[[02 2024/03/2024-03-02.md]]" {
+		t.Errorf("diary = %s", days)
+	}
+}
+
+func TestDiaryOnly(t *testing.T) {
+	files := map[string]string{
+		".journal.json":         `{"Timezone": "UTC"}`,
+		"2024/03/2024-03-01.md": "- *TODO* call from the diary\n",
+		"notes/plan.md":         "- *TODO* call from a note\n",
+	}
+	for _, c := range []struct {
+		diaryOnly bool
+		want      string
+	}{
+		{false, "2024/03/2024-03-01.md:1 notes/plan.md:1"},
+		{true, "2024/03/2024-03-01.md:1"},
+	} {
+		j, _, _ := newTestJournal(t, files)
+		j.SetDiaryOnly(c.diaryOnly)
+		if err := j.ProcessAll(); err != nil {
+			t.Fatal(err)
+		}
+		tags, err := j.List(ListFilter{Sort: "path"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		found, err := j.Search("call", false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got, gotFound []string
+		for _, tag := range tags {
+			got = append(got, fmt.Sprintf("%s:%d", tag.Path(), tag.LineNo))
+		}
+		for _, tag := range found {
+			gotFound = append(gotFound, fmt.Sprintf("%s:%d", tag.Path(), tag.LineNo))
+		}
+		sort.Strings(got)
+		sort.Strings(gotFound)
+		if strings.Join(got, " ") != c.want || strings.Join(gotFound, " ") != c.want {
+			t.Errorf("diary only %t: list %s, search %s, want %s", c.diaryOnly, got, gotFound, c.want)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	files := map[string]string{
+		".journal.json":           `{"MaxDepth": 2}`,
+		"a.md":                    "- *TODO* top\n",
+		"2024/03/2024-03-01.md":   "- *TODO* diary\n",
+		"docs/vendor/lib/deep.md": "- *TODO* vendored\n",
+	}
+	paths := func(j *Journal) string {
+		var got []string
+		for fn := range j.Todos {
+			got = append(got, fn)
+		}
+		sort.Strings(got)
+		return strings.Join(got, " ")
+	}
+	j, git, _ := newTestJournal(t, files)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(j); got != "2024/03/2024-03-01.md a.md" {
+		t.Errorf("todos after ProcessAll = %s", got)
+	}
+
+	// new notes below the limit are left out of the changes as well
+	j.Hash = "1111111"
+	writeFiles(t, j.path, map[string]string{"docs/vendor/lib/new.md": "- *TODO* new vendored\n", "docs/new.md": "- *TODO* new doc\n"})
+	git.others = "docs/vendor/lib/new.md\ndocs/new.md\n"
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(j); got != "2024/03/2024-03-01.md a.md docs/new.md" {
+		t.Errorf("todos after ProcessChanges = %s", got)
+	}
+
+	// the flag overrides the config, and notes now too deep are dropped
+	j.SetMaxDepth(1)
+	git.others = ""
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(j); got != "a.md docs/new.md" {
+		t.Errorf("todos at depth 1 = %s", got)
+	}
+
+	// 0 is unlimited
+	files[".journal.json"] = `{"MaxDepth": 0}`
+	j, _, _ = newTestJournal(t, files)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(j); got != "2024/03/2024-03-01.md a.md docs/vendor/lib/deep.md" {
+		t.Errorf("todos unlimited = %s", got)
+	}
+}
+
+func TestInvalidMaxDepth(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".journal.json": `{"MaxDepth": -1}`})
+	if _, err := OpenJournal(dir, ""); err == nil || !strings.Contains(err.Error(), "invalid MaxDepth -1") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestTagWithLink(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"a.md": "- *TODO* fix [login  bug *DONE* soon](https://example.com/issues/12) and ![the screen shot](shot.png), see [docs](https://example.com/a_b)\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Todos["a.md"]) != 1 || len(j.Done) != 0 {
+		t.Fatalf("todos %v, done %v", j.Todos, j.Done)
+	}
+	want := "- *[TODO](a.md)* fix [login  bug *DONE* soon](https://example.com/issues/12) and ![the screen shot](shot.png), see [docs](https://example.com/a_b)\n"
+	if index := renderIndex(t, j); !strings.Contains(index, want) {
+		t.Errorf("index without %q:\n%s", want, index)
+	}
+	if got := wordPattern.FindAllString("a [b c](d) [e](f)[g h](i). j", -1); strings.Join(got, "|") != "a|[b c](d)|[e](f)[g h](i).|j" {
+		t.Errorf("words = %q", got)
+	}
+}
@@ -0,0 +1,75 @@
+package diary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var linkPattern = regexp.MustCompile(`\[\[([^\]|]+)(?:\|[^\]]*)?\]\]`)
+var linkDatePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// resolveLink maps a wiki link target to a journal relative path. Date-only
+// targets point at the diary entry of that day.
+func (j *Journal) resolveLink(target string) string {
+	target = strings.TrimSpace(target)
+	if linkDatePattern.MatchString(target) {
+		if day, err := time.ParseInLocation("2006-01-02", target, j.location()); err == nil {
+			return j.diaryPath(day)
+		}
+	}
+	target = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(target)), "/")
+	if filepath.Ext(target) == "" {
+		target += ".md"
+	}
+	return target
+}
+
+func (j *Journal) extractLinks(text string) []string {
+	var links []string
+	for _, ms := range linkPattern.FindAllStringSubmatch(text, -1) {
+		links = append(links, j.resolveLink(ms[1]))
+	}
+	return links
+}
+
+func (n *Note) checkLinks() {
+	for _, fn := range n.Links {
+		if _, err := os.Stat(filepath.Join(n.journal.path, fn)); err != nil {
+			n.journal.warnf("%s links to missing note '%s'", n.Path, fn)
+		}
+	}
+}
+
+func (j *Journal) Backlinks(target string) []string {
+	target = j.resolveLink(target)
+	var result []string
+	for src, links := range j.Links {
+		for _, fn := range links {
+			if fn == target {
+				result = append(result, src)
+				break
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (j *Journal) PrintBacklinks(target string) error {
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	if j.json {
+		result := append([]string{}, j.Backlinks(target)...)
+		return writeResult(result)
+	}
+	for _, src := range j.Backlinks(target) {
+		fmt.Println(src)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package diary
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExtractLinks(t *testing.T) {
+	j, _, _ := newTestJournal(t, nil)
+	got := j.extractLinks("see [[projects/plan]], [[2024-03-01|friday]] and [[ notes/a.md ]] or [[/top]]")
+	want := []string{"projects/plan.md", "2024/03/2024-03-01.md", "notes/a.md", "top.md"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("extractLinks = %v, want %v", got, want)
+	}
+}
+
+func TestBacklinks(t *testing.T) {
+	j, _, log := newTestJournal(t, map[string]string{
+		"projects/plan.md":      "# Plan\n",
+		"2024/03/2024-03-01.md": "# Note\nworked on [[projects/plan]] and [[projects/plan|again]]\n",
+		"notes/ideas.md":        "[[projects/plan.md]] [[missing]]\n```\n[[fenced/out]]\n```\n",
+		"notes/other.md":        "[[2024-03-01]]\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := j.Backlinks("projects/plan"); fmt.Sprint(got) != "[2024/03/2024-03-01.md notes/ideas.md]" {
+		t.Errorf("Backlinks(projects/plan) = %v", got)
+	}
+	if got := j.Backlinks("2024-03-01"); fmt.Sprint(got) != "[notes/other.md]" {
+		t.Errorf("Backlinks(2024-03-01) = %v", got)
+	}
+	if got := j.Links["notes/ideas.md"]; fmt.Sprint(got) != "[projects/plan.md missing.md]" {
+		t.Errorf("links of ideas = %v", got)
+	}
+	if got := j.Links["2024/03/2024-03-01.md"]; len(got) != 1 {
+		t.Errorf("duplicate link kept: %v", got)
+	}
+	if !strings.Contains(log.String(), "notes/ideas.md links to missing note 'missing.md'") {
+		t.Errorf("log = %q", log)
+	}
+}
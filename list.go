@@ -0,0 +1,126 @@
+package diary
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func hasToken(tokens []string, token string) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// ListFilter selects the tags of List. Empty fields match every tag; without
+// a Type the DONE tags are left out. Since and Until are YYYY-MM-DD days,
+// both inclusive, compared with the tag time.
+type ListFilter struct {
+	Type    string
+	Project string
+	Context string
+	Since   string
+	Until   string
+	// Sort is path (the default), time, priority or due
+	Sort string
+}
+
+// List returns the tags matching f, sorted by f.Sort.
+func (j *Journal) List(f ListFilter) ([]Tag, error) {
+	f.Type = strings.ToUpper(f.Type)
+	if f.Type != "" && !j.isKind(f.Type) {
+		return nil, fmt.Errorf("unknown tag type '%s', expected one of %s", f.Type, strings.Join(j.kinds(), ", "))
+	}
+	var since, until time.Time
+	for _, b := range []struct {
+		value string
+		t     *time.Time
+	}{{f.Since, &since}, {f.Until, &until}} {
+		if b.value == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", b.value, j.location())
+		if err != nil {
+			return nil, fmt.Errorf("invalid date '%s', expected YYYY-MM-DD: %w", b.value, err)
+		}
+		*b.t = t
+	}
+	if !until.IsZero() {
+		until = until.AddDate(0, 0, 1)
+	}
+	var less func(a, b Tag) bool
+	switch f.Sort {
+	case "", "path":
+	case "time":
+		less = func(a, b Tag) bool { return a.Time.After(b.Time) }
+	case "priority":
+		less = func(a, b Tag) bool { return j.priorityRank(a.Priority) < j.priorityRank(b.Priority) }
+	case "due":
+		less = func(a, b Tag) bool {
+			if a.Due == nil || b.Due == nil {
+				return a.Due != nil
+			}
+			return a.Due.Before(*b.Due)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sort '%s', expected path, time, priority or due", f.Sort)
+	}
+	tags, err := j.collectTags()
+	if err != nil {
+		return nil, err
+	}
+	var result []Tag
+	for _, t := range tags {
+		if f.Type == "" && t.Tag == "DONE" {
+			continue
+		}
+		if f.Type != "" && t.Tag != f.Type {
+			continue
+		}
+		if f.Project != "" && !hasToken(t.Projects, f.Project) {
+			continue
+		}
+		if f.Context != "" && !hasToken(t.Contexts, f.Context) {
+			continue
+		}
+		if !since.IsZero() && t.Time.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !t.Time.Before(until) {
+			continue
+		}
+		result = append(result, t)
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		if result[a].Path() != result[b].Path() {
+			return result[a].Path() < result[b].Path()
+		}
+		return result[a].LineNo < result[b].LineNo
+	})
+	if less != nil {
+		sort.SliceStable(result, func(a, b int) bool {
+			return less(result[a], result[b])
+		})
+	}
+	return result, nil
+}
+
+func (j *Journal) PrintList(f ListFilter) error {
+	tags, err := j.List(f)
+	if err != nil {
+		return err
+	}
+	if j.json {
+		return writeResult(tagResults(tags))
+	}
+	tt := j.newTable("TAG", "NOTE", "TEXT")
+	for _, t := range tags {
+		tt.add(t.Tag, fmt.Sprintf("%s:%d", t.Path(), t.LineNo), t.Text)
+	}
+	return tt.write(os.Stdout)
+}
@@ -0,0 +1,105 @@
+package diary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProjectsAndContexts(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"a.md": "- *TODO* call bob +website @phone, mail bob@example.com at @10:30\n" +
+			"- *TODO* fix +website: header +infra @office\n" +
+			"- *DOING* review (+notatoken) @due:2024-03-01\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tag := range j.Todos["a.md"] {
+		got = append(got, strings.Join(tag.Projects, ",")+" "+strings.Join(tag.Contexts, ","))
+	}
+	if want := []string{"website phone", "website,infra office"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("projects and contexts = %q, want %q", got, want)
+	}
+	if tag := j.Doings["a.md"][0]; len(tag.Projects) != 0 || len(tag.Contexts) != 0 {
+		t.Errorf("doing projects %q, contexts %q", tag.Projects, tag.Contexts)
+	}
+	if text := j.Todos["a.md"][0].Text; !strings.HasSuffix(text, "call bob +website @phone, mail bob@example.com at @10:30") {
+		t.Errorf("tokens removed from the text: %s", text)
+	}
+}
+
+func TestListByProjectAndContext(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"a.md": "- *TODO* call bob +website @phone\n- *TODO* tidy +infra @office\n",
+		"b.md": "- *LATER* redesign +website @office\n- *TODO* +website done already *DONE*\n",
+	})
+	texts := func(f ListFilter) string {
+		tags, err := j.List(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tag := range tags {
+			got = append(got, tag.Path()+":"+tag.Tag)
+		}
+		return strings.Join(got, " ")
+	}
+	if got := texts(ListFilter{Project: "website"}); got != "a.md:TODO b.md:LATER" {
+		t.Errorf("project website = %s", got)
+	}
+	if got := texts(ListFilter{Context: "office"}); got != "a.md:TODO b.md:LATER" {
+		t.Errorf("context office = %s", got)
+	}
+	if got := texts(ListFilter{Project: "website", Context: "office"}); got != "b.md:LATER" {
+		t.Errorf("project website at office = %s", got)
+	}
+	if got := texts(ListFilter{Type: "done", Project: "website"}); got != "b.md:DONE" {
+		t.Errorf("done website = %s", got)
+	}
+	if _, err := j.List(ListFilter{Type: "someday"}); err == nil || !strings.Contains(err.Error(), "unknown tag type 'SOMEDAY'") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestListDateRangeAndSort(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         `{"Timezone": "UTC"}`,
+		"2023/12/2023-12-31.md": "# Note\n\n## 09:00:00\n- *TODO* too early +work\n",
+		"2024/01/2024-01-02.md": "# Note\n\n## 09:00:00\n- *TODO:B* second +work @due:2024-02-01\n- *LATER* not a todo +work\n",
+		"2024/01/2024-01-05.md": "# Note\n\n## 09:00:00\n- *TODO:A* third +work\n- *TODO* home +home\n\n## 18:00:00\n- *TODO:C* fourth +work @due:2024-01-20\n",
+		"2024/01/2024-01-06.md": "# Note\n\n## 00:00:00\n- *TODO* too late +work\n",
+	})
+	list := func(f ListFilter) string {
+		tags, err := j.List(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tag := range tags {
+			got = append(got, strings.Fields(tag.body)[0])
+		}
+		return strings.Join(got, " ")
+	}
+	f := ListFilter{Type: "todo", Project: "work", Since: "2024-01-01", Until: "2024-01-05"}
+	if got := list(f); got != "second third fourth" {
+		t.Errorf("by path = %s", got)
+	}
+	f.Sort = "time"
+	if got := list(f); got != "fourth third second" {
+		t.Errorf("by time = %s", got)
+	}
+	f.Sort = "priority"
+	if got := list(f); got != "third second fourth" {
+		t.Errorf("by priority = %s", got)
+	}
+	f.Sort = "due"
+	if got := list(f); got != "fourth second third" {
+		t.Errorf("by due = %s", got)
+	}
+	for _, bad := range []ListFilter{{Sort: "size"}, {Since: "2024-1-1"}, {Until: "tomorrow"}} {
+		if _, err := j.List(bad); err == nil {
+			t.Errorf("List(%+v) succeeded", bad)
+		}
+	}
+}
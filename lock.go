@@ -0,0 +1,107 @@
+package diary
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+const lockFile = ".journal.lock"
+
+var ErrLocked = errors.New("another diary process is running")
+
+var lockTimeout = 2 * time.Second
+
+// Lock takes the lock of the journal. The config was read before, so a
+// journal opened without the lock should use OpenLockedJournal instead, or
+// reload once it holds the lock.
+func (j *Journal) Lock() error {
+	if j.lock != nil {
+		return nil
+	}
+	f, err := lockDir(j.path)
+	if err != nil {
+		return err
+	}
+	j.lock = f
+	return nil
+}
+
+// lockDir takes the lock file of the journal at dir, waiting up to
+// lockTimeout for another process to release it.
+func lockDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error open lock file: %w", err)
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			return f, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, ErrLocked
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// lockFresh takes the lock and, when it was not held yet, reloads the config
+// another process may have written since the journal was opened. watch and
+// check -prune keep the journal open across such runs.
+func (j *Journal) lockFresh() error {
+	if j.lock != nil {
+		return nil
+	}
+	if err := j.Lock(); err != nil {
+		return err
+	}
+	if err := j.reload(); err != nil {
+		j.Unlock()
+		return err
+	}
+	return nil
+}
+
+// reload replaces the config fields of j with the ones on disk, keeping the
+// options set for this run.
+func (j *Journal) reload() error {
+	fresh, err := loadJournal(j.path, j.config, j.log)
+	if err != nil {
+		return err
+	}
+	dst, src := reflect.ValueOf(j).Elem(), reflect.ValueOf(fresh).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		if dst.Type().Field(i).IsExported() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	j.loc = fresh.loc
+	j.tagRe, j.hashtagRe = fresh.tagRe, fresh.hashtagRe
+	j.layoutRe, j.layoutGroups = fresh.layoutRe, fresh.layoutGroups
+	// the parse signature may have changed with the config
+	j.cache = nil
+	return nil
+}
+
+func (j *Journal) Unlock() error {
+	if j.lock == nil {
+		return nil
+	}
+	f := j.lock
+	j.lock = nil
+	if err := unlockFile(f); err != nil {
+		f.Close()
+		return fmt.Errorf("error unlock '%s': %w", f.Name(), err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package diary
+
+import "os"
+
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,101 @@
+package diary
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockContention(t *testing.T) {
+	defer func(d time.Duration) { lockTimeout = d }(lockTimeout)
+	lockTimeout = 200 * time.Millisecond
+	dir := t.TempDir()
+	first, err := OpenLockedJournal(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			j, err := OpenJournal(dir, "")
+			if err == nil {
+				err = j.Lock()
+				j.Unlock()
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if !errors.Is(err, ErrLocked) {
+			t.Errorf("goroutine %d: err = %v, want ErrLocked", i, err)
+		}
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	second, err := OpenLockedJournal(dir, "")
+	if err != nil {
+		t.Fatalf("lock not released by Close: %v", err)
+	}
+	second.Close()
+}
+
+func TestOpenLockedJournalSeesConfigOfPreviousHolder(t *testing.T) {
+	dir := t.TempDir()
+	first, err := OpenLockedJournal(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened := make(chan *Journal)
+	go func() {
+		j, err := OpenLockedJournal(dir, "")
+		if err != nil {
+			t.Error(err)
+		}
+		opened <- j
+	}()
+	// the second process blocks on the lock until the first has written
+	time.Sleep(150 * time.Millisecond)
+	first.Hash = "written-by-first"
+	if err := first.writeConfig(); err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+	second := <-opened
+	if second == nil {
+		return
+	}
+	defer second.Close()
+	if second.Hash != "written-by-first" {
+		t.Errorf("second process loaded stale config, Hash = %q", second.Hash)
+	}
+}
+
+func TestLockFreshReloadsConfig(t *testing.T) {
+	j, _, _ := newTestJournal(t, nil)
+	j.SetDryRun(true)
+	other, err := OpenJournal(j.path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Hash = "abc"
+	other.Todos["a.md"] = []Tag{{LineNo: 1, Tag: "TODO", Text: "- *TODO* a"}}
+	if err := other.writeConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.lockFresh(); err != nil {
+		t.Fatal(err)
+	}
+	defer j.Unlock()
+	if j.Hash != "abc" || len(j.Todos["a.md"]) != 1 {
+		t.Errorf("config not reloaded: Hash %q, todos %v", j.Hash, j.Todos)
+	}
+	if !j.dryRun {
+		t.Error("run option lost on reload")
+	}
+}
@@ -0,0 +1,91 @@
+package diary
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+type LogLevel int
+
+const (
+	LogQuiet LogLevel = iota
+	LogNormal
+	LogVerbose
+)
+
+// logger writes progress messages up to its level. Quiet drops everything
+// but the errors of errorf; other errors are returned to the caller and
+// never logged.
+type logger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level LogLevel
+}
+
+// defaultLog is where a new logger writes and up to which level, set by
+// SetDefaultLog. A nil out is the os.Stderr of the time.
+var defaultLog = struct {
+	sync.Mutex
+	out   io.Writer
+	level LogLevel
+}{level: LogNormal}
+
+// SetDefaultLog sends the log messages of the journals opened afterwards up
+// to level to out, including the warnings OpenJournal gives while it loads
+// the config and which SetLog comes too late for. A nil out is os.Stderr.
+func SetDefaultLog(out io.Writer, level LogLevel) {
+	defaultLog.Lock()
+	defer defaultLog.Unlock()
+	defaultLog.out = out
+	defaultLog.level = level
+}
+
+func newLogger() *logger {
+	defaultLog.Lock()
+	defer defaultLog.Unlock()
+	out := defaultLog.out
+	if out == nil {
+		out = os.Stderr
+	}
+	return &logger{out: out, level: defaultLog.level}
+}
+
+func (l *logger) logf(level LogLevel, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.level < level {
+		return
+	}
+	fmt.Fprintf(l.out, format+"\n", args...)
+}
+
+func (l *logger) enabled(level LogLevel) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.level >= level
+}
+
+// SetLog sends log messages up to level to out. The git runner shares the
+// logger, so verbose output includes every git command.
+func (j *Journal) SetLog(out io.Writer, level LogLevel) {
+	j.log.mu.Lock()
+	defer j.log.mu.Unlock()
+	j.log.out = out
+	j.log.level = level
+}
+
+func (j *Journal) infof(format string, args ...interface{}) {
+	j.log.logf(LogNormal, format, args...)
+}
+
+// errorf logs an error that has no caller to return to, such as a failed
+// rebuild of watch, at every level.
+func (j *Journal) errorf(format string, args ...interface{}) {
+	j.log.logf(LogQuiet, format, args...)
+}
+
+func (j *Journal) verbosef(format string, args ...interface{}) {
+	j.log.logf(LogVerbose, format, args...)
+}
@@ -0,0 +1,98 @@
+package diary
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLogLevels(t *testing.T) {
+	for _, c := range []struct {
+		level LogLevel
+		want  string
+	}{
+		{LogQuiet, "rebuild failed\n"},
+		{LogNormal, "indexed\nwarning: careful\nrebuild failed\n"},
+		{LogVerbose, "indexed\nwarning: careful\nrebuild failed\nparse a.md\n"},
+	} {
+		j := &Journal{log: newLogger()}
+		var out bytes.Buffer
+		j.SetLog(&out, c.level)
+		j.infof("indexed")
+		j.warnf("careful")
+		j.errorf("rebuild failed")
+		j.verbosef("parse %s", "a.md")
+		if out.String() != c.want {
+			t.Errorf("level %d logged %q, want %q", c.level, out.String(), c.want)
+		}
+	}
+}
+
+func TestVerboseProcessing(t *testing.T) {
+	j, _, log := newTestJournal(t, map[string]string{"a.md": "- *TODO* a\n"})
+	j.SetLog(log, LogVerbose)
+	j.SetNoCommit(true)
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"no index hash, process all notes", "parse a.md", "write index.md: 1 TODO", "skip commit", "indexed 1 tags in 1 notes"} {
+		if !strings.Contains(log.String(), line+"\n") {
+			t.Errorf("verbose log misses %q:\n%s", line, log)
+		}
+	}
+}
+
+func TestQuietProcessing(t *testing.T) {
+	j, _, log := newTestJournal(t, map[string]string{"a.md": "- *TODO* a\n## 10:00:00\n## 09:00:00\n"})
+	j.SetLog(log, LogQuiet)
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if log.Len() != 0 {
+		t.Errorf("quiet logged:\n%s", log)
+	}
+}
+
+func TestGitCommandsLoggedWhenVerbose(t *testing.T) {
+	g, log := realGit(t)
+	g.log.level = LogVerbose
+	if _, err := g.Status(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(log.String(), "git status") {
+		t.Errorf("log:\n%s", log)
+	}
+}
+
+func TestDefaultLogCoversOpen(t *testing.T) {
+	t.Cleanup(func() {
+		SetDefaultLog(nil, LogNormal)
+	})
+	for _, c := range []struct {
+		level LogLevel
+		warn  bool
+	}{
+		{LogQuiet, false},
+		{LogNormal, true},
+	} {
+		var out bytes.Buffer
+		SetDefaultLog(&out, c.level)
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{".journal.json": "{not json"})
+		j, err := OpenJournal(dir, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		j.verbosef("after open")
+		if got := strings.Contains(out.String(), "invalid config file '.journal.json'"); got != c.warn || strings.Contains(out.String(), "after open") {
+			t.Errorf("level %d logged %q", c.level, out.String())
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package diary
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const commitFormat = "2006-01-02 15:04:05"
+
+// commitMessage renders the CommitMessage template. {date} is the commit
+// time, {count} the number of changed files and {summary} the tags added by
+// the last ProcessChanges, e.g. "+2 todos, 1 done".
+func (j *Journal) commitMessage(count int) string {
+	tmpl := j.CommitMessage
+	if tmpl == "" {
+		tmpl = "{date}"
+	}
+	r := strings.NewReplacer("{date}", j.now().Format(commitFormat), "{count}", strconv.Itoa(count), "{summary}", j.changeSummary())
+	return strings.TrimSpace(r.Replace(tmpl))
+}
+
+// isAutoCommit reports whether msg could have been rendered from the
+// CommitMessage template.
+func (j *Journal) isAutoCommit(msg string) bool {
+	tmpl := j.CommitMessage
+	if tmpl == "" {
+		tmpl = "{date}"
+	}
+	expr := regexp.QuoteMeta(strings.TrimSpace(tmpl))
+	r := strings.NewReplacer(regexp.QuoteMeta("{date}"), `\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`, regexp.QuoteMeta("{count}"), `\d+`, regexp.QuoteMeta("{summary}"), summaryPattern)
+	// the padding stands in for the space next to an empty {summary}, which
+	// commitMessage trims
+	re, err := regexp.Compile(`^\s*` + r.Replace(expr) + `\s*$`)
+	return err == nil && re.MatchString(" "+msg+" ")
+}
+
+// summaryPattern matches what changeSummary renders, so a template of only
+// {summary} does not pass every commit as automatic.
+const summaryPattern = `(?:(?:\+\d+ [^\s,]+s|\d+ done)(?:, (?:\+\d+ [^\s,]+s|\d+ done))*)?`
+
+func (j *Journal) countAdded(added []Tag) {
+	if j.added == nil {
+		j.added = make(map[string]int)
+	}
+	for _, t := range added {
+		j.added[t.Tag]++
+	}
+}
+
+func (j *Journal) changeSummary() string {
+	var kinds []string
+	for k := range j.added {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(a, b int) bool {
+		return j.kindRank(kinds[a]) < j.kindRank(kinds[b])
+	})
+	var parts []string
+	for _, k := range kinds {
+		if k == "DONE" {
+			parts = append(parts, fmt.Sprintf("%d done", j.added[k]))
+		} else {
+			parts = append(parts, fmt.Sprintf("+%d %ss", j.added[k], strings.ToLower(k)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (j *Journal) kindRank(kind string) int {
+	for i, k := range j.kinds() {
+		if k == kind {
+			return i
+		}
+	}
+	return len(j.kinds())
+}
@@ -0,0 +1,52 @@
+package diary
+
+import "testing"
+
+func TestCommitMessageTemplate(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"Timezone": "UTC", "CommitMessage": "diary {date}: {count} files, {summary}"}`,
+		"a.md":          "- *TODO* one\n- *TODO* two\n- *TODO* three *DONE*\n- *WAITING* kept\n",
+	})
+	setNow(t, j, "2024-03-01T09:30:00Z")
+	j.Hash = "1111111"
+	j.Waitings["a.md"] = []Tag{{LineNo: 4, Tag: "WAITING", Text: "- *[WAITING](a.md)* kept"}}
+	git.diff = "M\ta.md\n"
+	git.status = " M a.md\n M index.md\n?? index.json\n"
+	if err := j.ProcessChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	want := "diary 2024-03-01 09:30:00: 3 files, +2 todos, 1 done"
+	if git.message != want {
+		t.Errorf("message = %q, want %q", git.message, want)
+	}
+	if !j.isAutoCommit(git.message) {
+		t.Errorf("own message %q not taken for automatic", git.message)
+	}
+}
+
+func TestCommitMessageDefault(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{".journal.json": `{"Timezone": "UTC"}`})
+	setNow(t, j, "2024-03-01T09:30:00Z")
+	git.status = " M a.md\n"
+	if err := j.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if git.message != "2024-03-01 09:30:00" {
+		t.Errorf("message = %q", git.message)
+	}
+}
+
+func TestCommitMessageWithoutChanges(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{".journal.json": `{"Timezone": "UTC", "CommitMessage": "{date} {summary}"}`})
+	setNow(t, j, "2024-03-01T09:30:00Z")
+	git.status = " M index.md\n"
+	if err := j.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if git.message != "2024-03-01 09:30:00" {
+		t.Errorf("message = %q", git.message)
+	}
+}
@@ -0,0 +1,119 @@
+package diary
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var diaryNamePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}\.md$`)
+
+// moveFile renames the note src to dst, with git mv when git tracks it so
+// the history follows. An untracked note, such as one created since the
+// last commit, is renamed on disk and picked up by the next commit.
+func (j *Journal) moveFile(src, dst string) error {
+	if j.hasRepo() {
+		out, err := j.git.LsFiles("--", src)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(out) != "" {
+			return j.git.Move(src, dst)
+		}
+	}
+	if err := os.Rename(filepath.Join(j.path, src), filepath.Join(j.path, dst)); err != nil {
+		return fmt.Errorf("error move '%s': %w", src, err)
+	}
+	return nil
+}
+
+// Move renames a note and rewrites every wiki link pointing at it. A diary
+// note, or a destination named like one, must land on a valid diary path.
+func (j *Journal) Move(src, dst string) error {
+	src, dst = filepath.ToSlash(filepath.Clean(src)), filepath.ToSlash(filepath.Clean(dst))
+	n, err := j.NewNote(src)
+	if err != nil {
+		return err
+	}
+	if n.Type == Diary || diaryNamePattern.MatchString(filepath.Base(dst)) {
+		if _, ok := j.diaryDate(dst); !ok {
+			return fmt.Errorf("invalid diary path '%s', expected %s", dst, j.diaryLayout())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(j.path, dst)); err == nil {
+		return fmt.Errorf("'%s' already exists", dst)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error stat '%s': %w", dst, err)
+	}
+	fmt.Printf("%s -> %s\n", src, dst)
+	if j.dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(j.path, filepath.Dir(dst)), os.ModePerm); err != nil {
+		return fmt.Errorf("error create path '%s': %w", filepath.Dir(dst), err)
+	}
+	if err := j.moveFile(src, dst); err != nil {
+		return err
+	}
+	j.moveHistory(src, dst)
+	j.purge(src)
+	if err := j.rewriteLinks(src, dst); err != nil {
+		return err
+	}
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	return j.Write()
+}
+
+// linkTarget is how a link to fn is written: the bare date for diary notes,
+// the path without extension otherwise.
+func (j *Journal) linkTarget(fn string) string {
+	if day, ok := j.diaryDate(fn); ok {
+		return day.Format("2006-01-02")
+	}
+	return strings.TrimSuffix(fn, ".md")
+}
+
+func (j *Journal) rewriteLinks(src, dst string) error {
+	return j.walkFiles(true, false, func(n *Note) error {
+		data, err := n.read()
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(string(data), "\n")
+		fenced, changed := false, false
+		for i, line := range lines {
+			if strings.HasPrefix(strings.TrimSpace(line), "```") {
+				fenced = !fenced
+			}
+			if fenced {
+				continue
+			}
+			lines[i] = linkPattern.ReplaceAllStringFunc(line, func(link string) string {
+				ms := linkPattern.FindStringSubmatch(link)
+				if j.resolveLink(ms[1]) != src {
+					return link
+				}
+				changed = true
+				return "[[" + j.linkTarget(dst) + strings.TrimPrefix(link[:len(link)-2], "[["+ms[1]) + "]]"
+			})
+		}
+		if !changed {
+			return nil
+		}
+		if n.Encrypted() {
+			j.warnf("skip encrypted note '%s', it is not rewritten", n.Path)
+			return nil
+		}
+		fmt.Printf("update links in %s\n", n.Path)
+		if err := ioutil.WriteFile(filepath.Join(j.path, n.Path), []byte(strings.Join(lines, "\n")), 0644); err != nil {
+			return fmt.Errorf("error write file '%s': %w", n.Path, err)
+		}
+		return nil
+	})
+}
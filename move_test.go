@@ -0,0 +1,112 @@
+package diary
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMoveRewritesLinks(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		"2024/03/2024-03-01.md": "# Note\n- *TODO* misplaced\n",
+		"notes/plan.md":         "see [[2024-03-01]] and [[2024-03-01|that day]], not [[2024-03-02]]\n```\n[[2024-03-01]]\n```\n",
+	})
+	git.noRepo = true
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() error { return j.Move("2024/03/2024-03-01.md", "2024/02/2024-02-29.md") })
+	if !strings.Contains(out, "2024/03/2024-03-01.md -> 2024/02/2024-02-29.md\n") || !strings.Contains(out, "update links in notes/plan.md\n") {
+		t.Errorf("output:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(j.path, "2024/03/2024-03-01.md")); !os.IsNotExist(err) {
+		t.Errorf("source kept: %v", err)
+	}
+	want := "see [[2024-02-29]] and [[2024-02-29|that day]], not [[2024-03-02]]\n```\n[[2024-03-01]]\n```\n"
+	if got := readFile(t, filepath.Join(j.path, "notes/plan.md")); got != want {
+		t.Errorf("links:\n%s\nwant:\n%s", got, want)
+	}
+	if _, ok := j.Todos["2024/03/2024-03-01.md"]; ok || len(j.Todos["2024/02/2024-02-29.md"]) != 1 {
+		t.Errorf("todos = %v", j.Todos)
+	}
+	if got := j.Links["notes/plan.md"]; len(got) != 2 || got[0] != "2024/02/2024-02-29.md" {
+		t.Errorf("links = %q", got)
+	}
+}
+
+func TestMoveUsesGitMove(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{"notes/a.md": "- *TODO* a\n"})
+	j.SetNoCommit(true)
+	git.tracked = []string{"notes/a.md"}
+	captureStdout(t, func() error { return j.Move("notes/a.md", "archive2/a.md") })
+	if calls := git.called("mv"); len(calls) != 1 || calls[0] != "mv notes/a.md archive2/a.md" {
+		t.Errorf("mv calls = %q", calls)
+	}
+}
+
+func TestMoveValidatesDiaryPath(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{
+		"2024/03/2024-03-01.md": "# Note\n",
+		"notes/a.md":            "a\n",
+		"notes/b.md":            "b\n",
+	})
+	for _, c := range []struct{ src, dst, err string }{
+		{"2024/03/2024-03-01.md", "notes/march.md", "invalid diary path 'notes/march.md'"},
+		{"notes/a.md", "2024/04/2024-03-01.md", "invalid diary path '2024/04/2024-03-01.md'"},
+		{"notes/a.md", "notes/b.md", "'notes/b.md' already exists"},
+	} {
+		if err := j.Move(c.src, c.dst); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("move %s %s: %v", c.src, c.dst, err)
+		}
+	}
+	if len(git.called("mv")) != 0 {
+		t.Errorf("calls = %q", git.calls)
+	}
+}
+
+func TestMoveUntrackedNote(t *testing.T) {
+	j, git, _ := newTestJournal(t, map[string]string{"notes/a.md": "- *TODO* a\n"})
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	captureStdout(t, func() error { return j.Move("notes/a.md", "notes/b.md") })
+	if calls := git.called("mv"); len(calls) != 0 {
+		t.Errorf("mv calls = %q", calls)
+	}
+	if got := readFile(t, filepath.Join(j.path, "notes/b.md")); got != "- *TODO* a\n" {
+		t.Errorf("moved note = %q", got)
+	}
+	if _, ok := j.Todos["notes/a.md"]; ok || len(j.Todos["notes/b.md"]) != 1 {
+		t.Errorf("todos = %v", j.Todos)
+	}
+}
+
+func TestGitMoveUntrackedNote(t *testing.T) {
+	j, g := gitJournal(t, map[string]string{
+		".journal.json": `{"AuthorName": "Diary Bot", "AuthorEmail": "bot@example.com"}`,
+		"a.md":          "- *TODO* a\n",
+	})
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, j.path, map[string]string{"new.md": "- *TODO* not committed yet\n"})
+	for _, m := range [][2]string{{"new.md", "notes/new.md"}, {"a.md", "notes/a.md"}} {
+		var err error
+		captureStdout(t, func() error {
+			err = j.Move(m[0], m[1])
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("move %s: %v", m[0], err)
+		}
+	}
+	if got := tracked(t, g); !strings.Contains(got, "notes/a.md") || !strings.Contains(got, "notes/new.md") || strings.Contains(got, " a.md") {
+		t.Errorf("committed %s", got)
+	}
+	if len(j.Todos["notes/new.md"]) != 1 || len(j.Todos["notes/a.md"]) != 1 {
+		t.Errorf("todos = %v", j.Todos)
+	}
+}
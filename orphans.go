@@ -0,0 +1,90 @@
+package diary
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// lineHasTag reports whether line still carries a tag of kind. A DONE tag
+// also comes from any tag on a checked task line.
+func (j *Journal) lineHasTag(line, kind string) bool {
+	checked := false
+	if ms := checkboxPattern.FindStringSubmatch(line); ms != nil {
+		checked = ms[1] != " "
+	}
+	for _, w := range wordPattern.FindAllString(line, -1) {
+		if k, _, _, ok := j.matchTag(w); ok && (k == kind || (kind == "DONE" && checked)) {
+			return true
+		}
+	}
+	return false
+}
+
+// orphanTags checks every stored tag against the line it points at and
+// returns the paths of the notes whose stored tags have drifted and the
+// number of orphaned tags, printing each of them.
+func (j *Journal) orphanTags() ([]string, int, error) {
+	lines := make(map[string][]string)
+	orphans := make(map[string]bool)
+	count := 0
+	for _, kind := range j.kinds() {
+		m := j.tagMap(kind)
+		var paths []string
+		for fn := range m {
+			paths = append(paths, fn)
+		}
+		sort.Strings(paths)
+		for _, fn := range paths {
+			text, ok := lines[fn]
+			if !ok {
+				n, err := j.NewNote(fn)
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				} else if err != nil {
+					return nil, 0, err
+				}
+				data, err := n.read()
+				if err != nil {
+					return nil, 0, err
+				}
+				text = strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+				lines[fn] = text
+			}
+			for _, t := range m[fn] {
+				if t.LineNo < 1 || t.LineNo > len(text) || !j.lineHasTag(text[t.LineNo-1], kind) {
+					fmt.Printf("%s:%d: orphaned %s tag, the line no longer has it\n", fn, t.LineNo, kind)
+					orphans[fn] = true
+					count++
+				}
+			}
+		}
+	}
+	var result []string
+	for fn := range orphans {
+		result = append(result, fn)
+	}
+	sort.Strings(result)
+	return result, count, nil
+}
+
+// pruneOrphans reprocesses the notes with orphaned tags, which drops them, and
+// writes the index.
+func (j *Journal) pruneOrphans(paths []string) error {
+	if err := j.lockFresh(); err != nil {
+		return err
+	}
+	for _, fn := range paths {
+		j.forgetTags(fn)
+		n, err := j.NewNote(fn)
+		if err != nil {
+			return err
+		}
+		if err := n.process(); err != nil {
+			return err
+		}
+	}
+	return j.Write()
+}
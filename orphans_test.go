@@ -0,0 +1,41 @@
+package diary
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPruneOrphanTags(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"a.md": "- *TODO* kept\n- *TODO* removed\n- *LATER* moved\n",
+	})
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	// the edit is not processed, so the stored tags point at lines without them
+	writeFiles(t, j.path, map[string]string{"a.md": "- *TODO* kept\n- removed, no tag\n\n- *LATER* moved\n"})
+	var err error
+	out := captureStdout(t, func() error {
+		err = j.Check(false, false)
+		return nil
+	})
+	if err == nil || err.Error() != "check found 2 problem(s)" {
+		t.Errorf("err = %v", err)
+	}
+	if want := "a.md:2: orphaned TODO tag, the line no longer has it\na.md:3: orphaned LATER tag, the line no longer has it\n"; out != want {
+		t.Errorf("check:\n%s\nwant:\n%s", out, want)
+	}
+
+	captureStdout(t, func() error { return j.Check(false, true) })
+	if len(j.Todos["a.md"]) != 1 || len(j.Laters["a.md"]) != 1 || j.Laters["a.md"][0].LineNo != 4 {
+		t.Errorf("todos %v, laters %v", j.Todos, j.Laters)
+	}
+	if index := readFile(t, filepath.Join(j.path, "index.md")); strings.Contains(index, "removed") {
+		t.Errorf("index:\n%s", index)
+	}
+	if out := captureStdout(t, func() error { return j.Check(false, false) }); out != "" {
+		t.Errorf("check after prune:\n%s", out)
+	}
+}
@@ -0,0 +1,127 @@
+package diary
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var markupPattern = regexp.MustCompile(`\*?\[([^\]]*)\]\([^)]*\)\*?`)
+
+// Notifier delivers a reminder, a title and a body of one line per item.
+type Notifier interface {
+	Notify(title, body string) error
+}
+
+type commandNotifier struct {
+	name string
+	args func(title, body string) []string
+}
+
+func (c commandNotifier) Notify(title, body string) error {
+	var stderr strings.Builder
+	cmd := exec.Command(c.name, c.args(title, body)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("error run %s: %w\n%s", c.name, err, msg)
+		}
+		return fmt.Errorf("error run %s: %w", c.name, err)
+	}
+	return nil
+}
+
+type writerNotifier struct {
+	out io.Writer
+}
+
+func (w writerNotifier) Notify(title, body string) error {
+	_, err := fmt.Fprintf(w.out, "%s\n%s\n", title, body)
+	return err
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+var notifiers = map[string]Notifier{
+	"notify-send": commandNotifier{name: "notify-send", args: func(title, body string) []string {
+		return []string{title, body}
+	}},
+	"osascript": commandNotifier{name: "osascript", args: func(title, body string) []string {
+		return []string{"-e", "display notification " + appleScriptString(body) + " with title " + appleScriptString(title)}
+	}},
+	"stdout": writerNotifier{out: os.Stdout},
+}
+
+func validateNotifier(name string) error {
+	if _, ok := notifiers[name]; name != "" && !ok {
+		return fmt.Errorf("unknown Notifier '%s' in config, expected notify-send, osascript or stdout", name)
+	}
+	return nil
+}
+
+// SetNotifier makes remind deliver through n instead of the Notifier from
+// the config.
+func (j *Journal) SetNotifier(n Notifier) {
+	j.notifier = n
+}
+
+// notify is the notifier remind uses: the one set by SetNotifier, Notifier
+// from the config, or else notify-send on Linux when installed, osascript
+// on macOS and stdout anywhere else.
+func (j *Journal) notify() Notifier {
+	if j.notifier != nil {
+		return j.notifier
+	}
+	if j.Notifier != "" {
+		return notifiers[j.Notifier]
+	}
+	switch runtime.GOOS {
+	case "linux":
+		if _, err := exec.LookPath("notify-send"); err == nil {
+			return notifiers["notify-send"]
+		}
+	case "darwin":
+		return notifiers["osascript"]
+	}
+	return notifiers["stdout"]
+}
+
+// plainText is tag text without the index links and code spans, for a
+// notification.
+func plainText(text string) string {
+	text = markupPattern.ReplaceAllString(text, "$1")
+	text = strings.ReplaceAll(text, "`", "")
+	return strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(text), "-*+"))
+}
+
+// Remind sends one notification listing the open tags that are overdue or
+// due today. Nothing is sent when none are, so it can run from cron.
+func (j *Journal) Remind() error {
+	today := startOfDay(j.now())
+	tags, err := j.dueBefore(today.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	overdue := 0
+	var lines []string
+	for _, t := range tags {
+		if t.Due.Before(today) {
+			overdue++
+			lines = append(lines, fmt.Sprintf("%dd late: %s", daysBetween(*t.Due, today), plainText(t.Text)))
+		} else {
+			lines = append(lines, "today: "+plainText(t.Text))
+		}
+	}
+	title := fmt.Sprintf("diary: %d overdue, %d due today", overdue, len(tags)-overdue)
+	return j.notify().Notify(title, strings.Join(lines, "\n"))
+}
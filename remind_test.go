@@ -0,0 +1,87 @@
+package diary
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeNotifier records the notifications sent.
+type fakeNotifier struct {
+	sent []string
+	err  error
+}
+
+func (f *fakeNotifier) Notify(title, body string) error {
+	f.sent = append(f.sent, title+"\n"+body)
+	return f.err
+}
+
+func TestRemind(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"Timezone": "UTC"}`,
+		"due.md": "- *TODO* pay rent @due:2024-02-28\n" +
+			"- *DOING:A* ship +release @due:2024-03-01\n" +
+			"- *TODO* tomorrow @due:2024-03-02\n" +
+			"- *DONE* paid @due:2024-02-01\n" +
+			"- *LATER* no due date\n",
+	})
+	setNow(t, j, "2024-03-01T18:00:00Z")
+	n := &fakeNotifier{}
+	j.SetNotifier(n)
+	if err := j.Remind(); err != nil {
+		t.Fatal(err)
+	}
+	want := "diary: 1 overdue, 1 due today\n2d late: TODO pay rent @due:2024-02-28\ntoday: DOING:A ship +release @due:2024-03-01"
+	if len(n.sent) != 1 || n.sent[0] != want {
+		t.Errorf("sent = %q, want %q", n.sent, want)
+	}
+}
+
+func TestRemindNothingDue(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"Timezone": "UTC"}`,
+		"due.md":        "- *TODO* next week @due:2024-03-08\n",
+	})
+	setNow(t, j, "2024-03-01T18:00:00Z")
+	n := &fakeNotifier{err: errors.New("not called")}
+	j.SetNotifier(n)
+	if err := j.Remind(); err != nil || len(n.sent) != 0 {
+		t.Errorf("err %v, sent %q", err, n.sent)
+	}
+}
+
+func TestRemindNotifierError(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{"due.md": "- *TODO* late @due:2020-01-01\n"})
+	j.SetNotifier(&fakeNotifier{err: errors.New("no display")})
+	if err := j.Remind(); err == nil || err.Error() != "no display" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestNotifierConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".journal.json": `{"Notifier": "growl"}`})
+	if _, err := OpenJournal(dir, ""); err == nil || !strings.Contains(err.Error(), "unknown Notifier 'growl'") {
+		t.Errorf("err = %v", err)
+	}
+	if got := appleScriptString(`say "hi" \o/`); got != `"say \"hi\" \\o/"` {
+		t.Errorf("appleScriptString = %s", got)
+	}
+}
+
+func TestRemindAcrossDST(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"Timezone": "Europe/Berlin"}`,
+		"due.md":        "- *TODO* across the change @due:2024-03-30\n",
+	})
+	setNow(t, j, "2024-04-01T10:00:00+02:00")
+	n := &fakeNotifier{}
+	j.SetNotifier(n)
+	if err := j.Remind(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "diary: 1 overdue, 0 due today\n2d late: TODO across the change @due:2024-03-30"; len(n.sent) != 1 || n.sent[0] != want {
+		t.Errorf("sent = %q, want %q", n.sent, want)
+	}
+}
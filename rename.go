@@ -0,0 +1,142 @@
+package diary
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// RenameTag rewrites every old tag in the journal, archive included, as new
+// and renames it in the config: a custom tag type is replaced in CustomTags,
+// a built-in one stays known but its sections move to new. Renaming to an
+// existing tag type merges the two and needs force.
+func (j *Journal) RenameTag(old, new string, force bool) error {
+	old, new = strings.ToUpper(old), strings.ToUpper(new)
+	if !j.isKind(old) {
+		return fmt.Errorf("unknown tag type '%s', expected one of %s", old, strings.Join(j.kinds(), ", "))
+	}
+	if !customTagPattern.MatchString(new) {
+		return fmt.Errorf("invalid tag type '%s', expected upper case letters, digits or '_'", new)
+	}
+	if old == new {
+		return nil
+	}
+	exists := j.isKind(new)
+	if exists && !force {
+		return fmt.Errorf("tag type '%s' already exists, use -force to merge '%s' into it", new, old)
+	}
+	if err := j.renameTagFiles(old, new); err != nil {
+		return err
+	}
+	if j.dryRun {
+		return nil
+	}
+	var custom []string
+	for _, t := range j.CustomTags {
+		if t != old {
+			custom = append(custom, t)
+		} else if !exists {
+			custom = append(custom, new)
+		}
+	}
+	if !exists && !j.isCustom(old) {
+		custom = append(custom, new)
+	}
+	j.CustomTags = custom
+	var sections []Section
+	for _, s := range j.Sections {
+		if s.Tag == old {
+			if exists && j.hasSection(new) {
+				continue
+			}
+			s.Tag = new
+			if s.Title == old {
+				s.Title = new
+			}
+		}
+		sections = append(sections, s)
+	}
+	j.Sections = sections
+	j.compileTagPatterns()
+	if err := j.ProcessAll(); err != nil {
+		return err
+	}
+	return j.Write()
+}
+
+func (j *Journal) isCustom(kind string) bool {
+	for _, t := range j.CustomTags {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *Journal) hasSection(kind string) bool {
+	for _, s := range j.Sections {
+		if s.Tag == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *Journal) renameTagFiles(old, new string) error {
+	return j.walkFiles(true, false, func(n *Note) error {
+		data, err := n.read()
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(string(data), "\n")
+		fenced, changed := false, false
+		for i, line := range lines {
+			if strings.HasPrefix(strings.TrimSpace(line), "```") {
+				fenced = !fenced
+			}
+			if fenced {
+				continue
+			}
+			inCode := false
+			lines[i] = wordPattern.ReplaceAllStringFunc(line, func(w string) string {
+				if inCode {
+					if strings.Count(w, "`")%2 == 1 {
+						inCode = false
+					}
+					return w
+				}
+				if strings.Count(w, "`")%2 == 1 {
+					inCode = true
+				}
+				kind, prio, suffix, ok := j.matchTag(w)
+				if !ok || kind != old {
+					return w
+				}
+				changed = true
+				if prio != "" {
+					prio = ":" + prio
+				}
+				if strings.HasPrefix(w, "#") {
+					return "#" + strings.ToLower(new) + prio + suffix
+				}
+				return "*" + new + prio + "*" + suffix
+			})
+		}
+		if !changed {
+			return nil
+		}
+		if n.Encrypted() {
+			j.warnf("skip encrypted note '%s', it is not rewritten", n.Path)
+			return nil
+		}
+		fmt.Printf("rename %s in %s\n", old, n.Path)
+		if j.dryRun {
+			return nil
+		}
+		if err := ioutil.WriteFile(filepath.Join(j.path, n.Path), []byte(strings.Join(lines, "\n")), 0644); err != nil {
+			return fmt.Errorf("error write file '%s': %w", n.Path, err)
+		}
+		return nil
+	})
+}
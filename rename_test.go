@@ -0,0 +1,85 @@
+package diary
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenameTag(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"TagStyle": "both"}`,
+		"a.md":          "- *LATER* read a book\n- *LATER:A*, urgent-ish\n- #later:B hashtag\n- `*LATER*` in code\n```\n*LATER* fenced\n```\n- *TODO* untouched\n",
+		"archive/b.md":  "- *LATER* archived\n",
+	})
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() error { return j.RenameTag("later", "someday", false) })
+	if out != "rename LATER in a.md\nrename LATER in archive/b.md\n" {
+		t.Errorf("output:\n%s", out)
+	}
+	want := "- *SOMEDAY* read a book\n- *SOMEDAY:A*, urgent-ish\n- #someday:B hashtag\n- `*LATER*` in code\n```\n*LATER* fenced\n```\n- *TODO* untouched\n"
+	if got := readFile(t, filepath.Join(j.path, "a.md")); got != want {
+		t.Errorf("a.md:\n%s\nwant:\n%s", got, want)
+	}
+	if got := readFile(t, filepath.Join(j.path, "archive/b.md")); got != "- *SOMEDAY* archived\n" {
+		t.Errorf("archive/b.md:\n%s", got)
+	}
+
+	var config struct {
+		CustomTags []string
+		Sections   []Section
+	}
+	if err := json.Unmarshal([]byte(readFile(t, j.configPath())), &config); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(config.CustomTags, ",") != "SOMEDAY" {
+		t.Errorf("custom tags = %q", config.CustomTags)
+	}
+	var sections []string
+	for _, s := range config.Sections {
+		sections = append(sections, s.Tag+"="+s.Title)
+	}
+	if got := strings.Join(sections, " "); got != "DOING=DOING TODO=TODO SOMEDAY=SOMEDAY WAITING=WAITING" {
+		t.Errorf("sections = %s", got)
+	}
+	if got := j.Custom["SOMEDAY"]["a.md"]; len(got) != 3 || got[1].Priority != "A" || got[2].Priority != "B" {
+		t.Errorf("somedays = %v", got)
+	}
+	if len(j.Laters) != 0 {
+		t.Errorf("laters = %v", j.Laters)
+	}
+	index := readFile(t, filepath.Join(j.path, "index.md"))
+	if !strings.Contains(index, "# SOMEDAY\n") || strings.Contains(index, "# LATER") {
+		t.Errorf("index:\n%s", index)
+	}
+	if data := readFile(t, filepath.Join(j.path, "index.json")); !strings.Contains(data, `"somedays"`) || strings.Contains(data, `"laters"`) {
+		t.Errorf("index.json:\n%s", data)
+	}
+}
+
+func TestRenameTagToExisting(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{"a.md": "- *LATER* a\n- *TODO* b\n"})
+	j.SetNoCommit(true)
+	if err := j.RenameTag("LATER", "TODO", false); err == nil || !strings.Contains(err.Error(), "use -force to merge 'LATER' into it") {
+		t.Errorf("err = %v", err)
+	}
+	if got := readFile(t, filepath.Join(j.path, "a.md")); got != "- *LATER* a\n- *TODO* b\n" {
+		t.Errorf("rewritten without force:\n%s", got)
+	}
+	captureStdout(t, func() error { return j.RenameTag("LATER", "TODO", true) })
+	if got := j.Todos["a.md"]; len(got) != 2 {
+		t.Errorf("todos after merge = %v", got)
+	}
+	for _, s := range j.Sections {
+		if s.Tag == "LATER" {
+			t.Errorf("LATER section kept: %v", j.Sections)
+		}
+	}
+	if err := j.RenameTag("NOPE", "X", false); err == nil || !strings.Contains(err.Error(), "unknown tag type 'NOPE'") {
+		t.Errorf("err = %v", err)
+	}
+}
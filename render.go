@@ -0,0 +1,123 @@
+package diary
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// wordPattern splits a line into words, keeping a markdown link with
+// spaces in its label as one word so nothing inside it is taken for a tag
+// and its spacing survives.
+var wordPattern = regexp.MustCompile(`(?:[^\s\[]*!?\[[^\]]*\]\([^)\s]*\))+\S*|\S+`)
+
+// Render returns the note with normalized formatting: time headers in
+// TimeFormat with "now" expanded to the modification time, tags in the canonical form of the
+// configured TagStyle and a single trailing newline. Front matter and
+// fenced code are kept verbatim.
+func (n *Note) Render() (string, error) {
+	data, err := n.read()
+	if err != nil {
+		return "", err
+	}
+	_, front := n.frontMatter(data)
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	var out strings.Builder
+	fenced := false
+	for i, line := range lines {
+		if i >= front {
+			if strings.HasPrefix(strings.TrimSpace(line), "```") {
+				fenced = !fenced
+			} else if !fenced {
+				line = n.journal.renderLine(line, n.modTime)
+			}
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	return out.String(), nil
+}
+
+func (j *Journal) renderLine(line string, now time.Time) string {
+	if ms := mdTimePattern.FindStringSubmatch(line); ms != nil {
+		return "## " + j.headerTime(ms[1], now)
+	}
+	inCode := false
+	return wordPattern.ReplaceAllStringFunc(line, func(w string) string {
+		if inCode {
+			if strings.Count(w, "`")%2 == 1 {
+				inCode = false
+			}
+			return w
+		}
+		if strings.Count(w, "`")%2 == 1 {
+			inCode = true
+		}
+		kind, prio, suffix, ok := j.matchTag(w)
+		if !ok {
+			return w
+		}
+		if prio != "" {
+			prio = ":" + prio
+		}
+		if j.TagStyle == "hashtag" {
+			return "#" + strings.ToLower(kind) + prio + suffix
+		}
+		return "*" + kind + prio + "*" + suffix
+	})
+}
+
+// Format renders the given notes, or every note when none are given. With
+// write set, changed notes are rewritten in place and their paths printed;
+// otherwise the rendered notes go to stdout.
+func (j *Journal) Format(paths []string, write bool) error {
+	var notes []*Note
+	if len(paths) == 0 {
+		err := j.walkNotes(func(n *Note) error {
+			notes = append(notes, n)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, fn := range paths {
+		n, err := j.NewNote(fn)
+		if err != nil {
+			return err
+		}
+		notes = append(notes, n)
+	}
+	for _, n := range notes {
+		rendered, err := n.Render()
+		if err != nil {
+			return err
+		}
+		if !write {
+			fmt.Print(rendered)
+			continue
+		}
+		data, err := n.read()
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(data, []byte(rendered)) {
+			continue
+		}
+		if n.Encrypted() {
+			j.warnf("skip encrypted note '%s', it is not rewritten", n.Path)
+			continue
+		}
+		ff := filepath.Join(j.path, n.Path)
+		if err := ioutil.WriteFile(ff, []byte(rendered), 0644); err != nil {
+			return fmt.Errorf("error write file '%s': %w", n.Path, err)
+		}
+		fmt.Println(n.Path)
+	}
+	return nil
+}
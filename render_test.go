@@ -0,0 +1,61 @@
+package diary
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const unformattedNote = "---\r\ntitle: standup\r\n## 10:00\r\n---\r\n# Standup\r\n## 09:00\r\n- #todo call bob\r\n- *DOING:A* review\r\n- #Waiting, reply from ops\r\n```sh\r\n## 10:00\r\n- #todo inside fence\r\n```\r\nsee `#todo` in code and [#todo list](a.md)\r\n## 11:30:15\r\n- #later:B tidy up\r\n\r\n\r\n"
+
+const formattedNote = "---\ntitle: standup\n## 10:00\n---\n# Standup\n## 09:00:00\n- *TODO* call bob\n- *DOING:A* review\n- *WAITING*, reply from ops\n```sh\n## 10:00\n- #todo inside fence\n```\nsee `#todo` in code and [#todo list](a.md)\n## 11:30:15\n- *LATER:B* tidy up\n"
+
+func TestRenderRoundTrip(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"TagStyle": "both"}`,
+		"a.md":          unformattedNote,
+	})
+	n, err := j.NewNote("a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := n.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != formattedNote {
+		t.Errorf("render:\n%q\nwant:\n%q", got, formattedNote)
+	}
+
+	out := captureStdout(t, func() error { return j.Format(nil, true) })
+	if out != "a.md\n" {
+		t.Errorf("fmt -w printed %q", out)
+	}
+	if data := readFile(t, filepath.Join(j.path, "a.md")); data != formattedNote {
+		t.Errorf("rewritten:\n%q", data)
+	}
+	if out := captureStdout(t, func() error { return j.Format(nil, true) }); out != "" {
+		t.Errorf("second fmt -w changed %q", out)
+	}
+	if out := captureStdout(t, func() error { return j.Format([]string{"a.md"}, false) }); out != formattedNote {
+		t.Errorf("fmt printed:\n%q", out)
+	}
+}
+
+func TestRenderHashtagStyle(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"TagStyle": "hashtag"}`,
+		"a.md":          "- #TODO:A: call\n- *TODO* kept\n",
+	})
+	n, err := j.NewNote("a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := n.Render()
+	if err != nil || got != "- #todo:A: call\n- *TODO* kept\n" {
+		t.Errorf("render = %q, %v", got, err)
+	}
+	writeFiles(t, j.path, map[string]string{"a.md": got})
+	if tags, err := n.parse(); err != nil || len(tags) != 1 || tags[0].Priority != "A" {
+		t.Errorf("tags = %v, %v", tags, err)
+	}
+}
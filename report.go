@@ -0,0 +1,139 @@
+package diary
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const reportDir = "reports"
+
+var reportLinkPattern = regexp.MustCompile(`(\*\[[^\]]+\]\()`)
+
+// Report writes the digest of month, in YYYY-MM form: tag counts, the items
+// completed, the todos still open and the diary days of the month.
+func (j *Journal) Report(w io.Writer, month string) error {
+	start, err := time.ParseInLocation("2006-01", month, j.location())
+	if err != nil {
+		return fmt.Errorf("invalid month '%s', expected YYYY-MM: %w", month, err)
+	}
+	end := start.AddDate(0, 1, 0)
+	inMonth := func(t Tag) bool {
+		return !t.Time.Before(start) && t.Time.Before(end)
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Report %s\n\n## Counts\n\n| Tag | Count |\n| --- | ---: |\n", month)
+	for _, kind := range j.kinds() {
+		count := 0
+		for _, t := range j.storedKind(kind) {
+			if inMonth(t) {
+				count++
+			}
+		}
+		fmt.Fprintf(&out, "| %s | %d |\n", kind, count)
+	}
+	out.WriteString("\n## Completed\n\n")
+	j.writeReportTags(&out, j.storedKind("DONE"), inMonth)
+	out.WriteString("\n## Carried over\n\n")
+	j.writeReportTags(&out, j.storedKind("TODO"), inMonth)
+	out.WriteString("\n## Days\n\n")
+	days, err := j.monthDays(month)
+	if err != nil {
+		return err
+	}
+	for _, fn := range days {
+		n, err := j.NewNote(fn)
+		if err != nil {
+			return err
+		}
+		data, err := n.read()
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(fn), ageExt), ".md")
+		fmt.Fprintf(&out, "- [%s](../%s) %s\n", name, filepath.ToSlash(fn), noteTitle(data, ""))
+	}
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return fmt.Errorf("error write report: %w", err)
+	}
+	return nil
+}
+
+func (j *Journal) storedKind(kind string) []Tag {
+	var tags []Tag
+	for _, n := range j.tagMap(kind) {
+		tags = append(tags, n...)
+	}
+	sort.Slice(tags, func(a, b int) bool {
+		if !tags[a].Time.Equal(tags[b].Time) {
+			return tags[a].Time.Before(tags[b].Time)
+		}
+		if tags[a].Path() != tags[b].Path() {
+			return tags[a].Path() < tags[b].Path()
+		}
+		return tags[a].LineNo < tags[b].LineNo
+	})
+	return tags
+}
+
+// writeReportTags lists the tags passing keep, with the tag links made
+// relative to the reports folder.
+func (j *Journal) writeReportTags(out io.Writer, tags []Tag, keep func(Tag) bool) {
+	for _, t := range tags {
+		if keep(t) {
+			fmt.Fprintf(out, "%s\n", reportLinkPattern.ReplaceAllString(t.Text, "$1../"))
+		}
+	}
+}
+
+// monthDays returns the diary notes of month, from j.Diary when the month is
+// inside the diary window, or by walking the notes otherwise.
+func (j *Journal) monthDays(month string) ([]string, error) {
+	var days []string
+	if entries, ok := j.Diary[month]; ok {
+		for _, d := range entries {
+			days = append(days, d[1])
+		}
+	} else {
+		err := j.walkTree(true, func(n *Note) error {
+			if n.Type == Diary && n.Time.Format("2006-01") == month {
+				days = append(days, n.Path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+func (j *Journal) WriteReport(month string, stdout bool) error {
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	if stdout || j.dryRun {
+		return j.Report(os.Stdout, month)
+	}
+	var out bytes.Buffer
+	if err := j.Report(&out, month); err != nil {
+		return err
+	}
+	fn := filepath.Join(reportDir, month+".md")
+	if err := os.MkdirAll(filepath.Join(j.path, reportDir), os.ModePerm); err != nil {
+		return fmt.Errorf("error create path '%s': %w", reportDir, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(j.path, fn), out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error write report: %w", err)
+	}
+	fmt.Println(fn)
+	return nil
+}
@@ -0,0 +1,80 @@
+package diary
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var reportFixtures = map[string]string{
+	".journal.json":         `{"Timezone": "UTC"}`,
+	"2024/02/2024-02-28.md": "# Note 2024-02-28\n## 09:00:00\n- *TODO* from february\n",
+	"2024/03/2024-03-01.md": "# Note 2024-03-01\n## 09:00:00\n- *TODO* call bob\n- *DONE* shipped\n",
+	"2024/03/2024-03-05.md": "# Standup\n## 10:00:00\n- *DOING* review\n- *TODO* write docs *DONE*\n",
+	"2024/04/2024-04-01.md": "# Note 2024-04-01\n## 09:00:00\n- *TODO* from april\n",
+}
+
+const wantReport = `# Report 2024-03
+
+## Counts
+
+| Tag | Count |
+| --- | ---: |
+| DOING | 1 |
+| TODO | 1 |
+| LATER | 0 |
+| WAITING | 0 |
+| DONE | 2 |
+
+## Completed
+
+- *[DONE](../2024/03/2024-03-01.md#09:00:00)* shipped
+- *[TODO](../2024/03/2024-03-05.md#10:00:00)* write docs *[DONE](../2024/03/2024-03-05.md#10:00:00)*
+
+## Carried over
+
+- *[TODO](../2024/03/2024-03-01.md#09:00:00)* call bob
+
+## Days
+
+- [2024-03-01](../2024/03/2024-03-01.md) Note 2024-03-01
+- [2024-03-05](../2024/03/2024-03-05.md) Standup
+`
+
+func TestReport(t *testing.T) {
+	j, _, _ := newTestJournal(t, reportFixtures)
+	setNow(t, j, "2024-04-02T12:00:00Z")
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() error { return j.WriteReport("2024-03", true) })
+	if out != wantReport {
+		t.Errorf("report:\n%s\nwant:\n%s", out, wantReport)
+	}
+	if again := captureStdout(t, func() error { return j.WriteReport("2024-03", true) }); again != out {
+		t.Errorf("report not deterministic:\n%s", again)
+	}
+
+	if out := captureStdout(t, func() error { return j.WriteReport("2024-03", false) }); out != "reports/2024-03.md\n" {
+		t.Errorf("output = %q", out)
+	}
+	if got := readFile(t, filepath.Join(j.path, "reports/2024-03.md")); got != wantReport {
+		t.Errorf("written report:\n%s", got)
+	}
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	for fn := range j.Todos {
+		if strings.HasPrefix(fn, reportDir+"/") {
+			t.Errorf("report indexed: %v", j.Todos[fn])
+		}
+	}
+}
+
+func TestReportInvalidMonth(t *testing.T) {
+	j, _, _ := newTestJournal(t, nil)
+	if err := j.WriteReport("2024-3", true); err == nil || !strings.Contains(err.Error(), "invalid month '2024-3'") {
+		t.Errorf("err = %v", err)
+	}
+}
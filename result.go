@@ -0,0 +1,109 @@
+package diary
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// The result types are what the report commands print with -json. They are
+// kept apart from Tag and friends so the output stays stable when those
+// change.
+
+type TagResult struct {
+	Tag      string `json:",omitempty"`
+	Priority string `json:",omitempty"`
+	Path     string
+	Line     int
+	Time     string
+	Due      string   `json:",omitempty"`
+	Wake     string   `json:",omitempty"`
+	Projects []string `json:",omitempty"`
+	Contexts []string `json:",omitempty"`
+	Text     string
+}
+
+type OverdueResult struct {
+	TagResult
+	DaysLate int
+}
+
+type AgedResult struct {
+	TagResult
+	Days int
+}
+
+type StatResult struct {
+	Month string
+	Tag   string
+	Count int
+}
+
+type StreakResult struct {
+	Current int
+	Longest int
+}
+
+type WordResult struct {
+	Month string
+	Words int
+}
+
+type FileWordResult struct {
+	Path  string
+	Words int
+}
+
+type LeadTimeResult struct {
+	Done        int
+	Open        int
+	AverageDays float64
+	MedianDays  float64
+}
+
+type HourResult struct {
+	Hour  int
+	Count int
+}
+
+type ChangeResult struct {
+	Path    string
+	Status  string
+	Added   []TagResult
+	Removed []TagResult
+}
+
+// SetJSON makes the report commands print their result as JSON instead of
+// a table.
+func (j *Journal) SetJSON(json bool) {
+	j.json = json
+}
+
+func tagResult(t Tag) TagResult {
+	r := TagResult{Tag: t.Tag, Priority: t.Priority, Path: t.Path(), Line: t.LineNo, Time: t.Time.Format(time.RFC3339), Projects: t.Projects, Contexts: t.Contexts, Text: t.Text}
+	if t.Due != nil {
+		r.Due = t.Due.Format("2006-01-02")
+	}
+	if t.Wake != nil {
+		r.Wake = t.Wake.Format("2006-01-02")
+	}
+	return r
+}
+
+func tagResults(tags []Tag) []TagResult {
+	result := []TagResult{}
+	for _, t := range tags {
+		result = append(result, tagResult(t))
+	}
+	return result
+}
+
+func writeResult(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("error write json: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package diary
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// jsonKeys decodes the JSON array out and returns the sorted keys of each
+// object.
+func jsonKeys(t *testing.T, out string) ([]map[string]interface{}, []string) {
+	t.Helper()
+	var rows []map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &rows); err != nil {
+		t.Fatalf("%v in:\n%s", err, out)
+	}
+	var keys []string
+	for _, row := range rows {
+		var ks []string
+		for k := range row {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+		keys = append(keys, strings.Join(ks, ","))
+	}
+	return rows, keys
+}
+
+func TestStatsJSON(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/01/2024-01-05.md": "# Note\n\n## 09:00:00\n- *TODO* a\n- *TODO* b\n- *DONE* c\n",
+	})
+	j.SetJSON(true)
+	rows, keys := jsonKeys(t, captureStdout(t, j.PrintStats))
+	if len(rows) != 1 || keys[0] != "Count,Month,Tag" {
+		t.Fatalf("rows %v, keys %q", rows, keys)
+	}
+	if rows[0]["Month"] != "2024-01" || rows[0]["Tag"] != "TODO" || rows[0]["Count"] != 2.0 {
+		t.Errorf("row = %v", rows[0])
+	}
+}
+
+func TestOverdueJSON(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"due.md": "- *TODO:A* late @due:2024-02-28\n- *TODO* not yet @due:2024-03-09\n",
+	})
+	setNow(t, j, "2024-03-01T18:00:00Z")
+	j.SetJSON(true)
+	rows, keys := jsonKeys(t, captureStdout(t, j.PrintOverdue))
+	if len(rows) != 1 || keys[0] != "DaysLate,Due,Line,Path,Priority,Tag,Text,Time" {
+		t.Fatalf("rows %v, keys %q", rows, keys)
+	}
+	row := rows[0]
+	if row["DaysLate"] != 2.0 || row["Due"] != "2024-02-28" || row["Path"] != "due.md" || row["Line"] != 1.0 || row["Tag"] != "TODO" || row["Priority"] != "A" {
+		t.Errorf("row = %v", row)
+	}
+}
+
+func TestEmptyResultIsArray(t *testing.T) {
+	j, _, _ := newTestJournal(t, nil)
+	j.SetJSON(true)
+	if out := captureStdout(t, j.PrintOverdue); strings.TrimSpace(out) != "[]" {
+		t.Errorf("out = %q", out)
+	}
+}
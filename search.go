@@ -0,0 +1,113 @@
+package diary
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (t Tag) Path() string {
+	if t.note == nil {
+		return ""
+	}
+	return t.note.Path
+}
+
+func searchPattern(query string, ignoreCase bool) (*regexp.Regexp, error) {
+	expr := regexp.QuoteMeta(query)
+	if len(query) >= 2 && strings.HasPrefix(query, "/") && strings.HasSuffix(query, "/") {
+		expr = query[1 : len(query)-1]
+	}
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("error parse query '%s': %w", query, err)
+	}
+	return re, nil
+}
+
+// Search returns every line matching query, newest first.
+func (j *Journal) Search(query string, ignoreCase bool, archive bool) ([]Tag, error) {
+	re, err := searchPattern(query, ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	var result []Tag
+	err = j.walkTree(archive, func(n *Note) error {
+		data, err := n.read()
+		if err != nil {
+			return err
+		}
+		return n.scan(bytes.NewReader(data), func(lineNo int, text string, nt string, ctime time.Time) error {
+			if re.MatchString(text) {
+				result = append(result, Tag{note: n, Time: ctime, LineNo: lineNo, Text: text})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		if !result[a].Time.Equal(result[b].Time) {
+			return result[a].Time.After(result[b].Time)
+		}
+		if result[a].Path() != result[b].Path() {
+			return result[a].Path() < result[b].Path()
+		}
+		return result[a].LineNo < result[b].LineNo
+	})
+	return result, nil
+}
+
+// pageTags returns at most limit tags after skipping offset, all the rest when
+// limit is zero.
+func pageTags(tags []Tag, offset, limit int) []Tag {
+	if offset >= len(tags) {
+		return nil
+	}
+	tags = tags[offset:]
+	if limit > 0 && limit < len(tags) {
+		tags = tags[:limit]
+	}
+	return tags
+}
+
+// PrintSearch prints the page of matching lines given by offset and limit
+// as a table or, with porcelain set, as "file:line:time:text" records with
+// the RFC 3339 time of the entry, ended by a newline or by a NUL byte when
+// null is set.
+func (j *Journal) PrintSearch(query string, ignoreCase, archive, porcelain, null bool, offset, limit int) error {
+	if offset < 0 || limit < 0 {
+		return fmt.Errorf("invalid -offset %d or -limit %d, expected zero or more", offset, limit)
+	}
+	tags, err := j.Search(query, ignoreCase, archive)
+	if err != nil {
+		return err
+	}
+	tags = pageTags(tags, offset, limit)
+	if porcelain || null {
+		end := "\n"
+		if null {
+			end = "\x00"
+		}
+		for _, t := range tags {
+			fmt.Printf("%s:%d:%s:%s%s", t.Path(), t.LineNo, t.Time.Format(time.RFC3339), t.Text, end)
+		}
+		return nil
+	}
+	if j.json {
+		return writeResult(tagResults(tags))
+	}
+	tt := j.newTable("NOTE", "TIME", "TEXT")
+	for _, t := range tags {
+		tt.add(fmt.Sprintf("%s:%d", t.Path(), t.LineNo), t.Time.Format("2006-01-02 15:04:05"), t.Text)
+	}
+	return tt.write(os.Stdout)
+}
@@ -0,0 +1,109 @@
+package diary
+
+import (
+	"strings"
+	"testing"
+)
+
+func searchFiles() map[string]string {
+	return map[string]string{
+		"2024/01/2024-01-05.md":         "# Note\n\n## 09:00:00\nCall Alice\n\n## 10:00:00\nlunch\ncall bob\n",
+		"2024/01/2024-01-06.md":         "# Note\n\n## 08:00:00\nno match\n",
+		"archive/2023/05/2023-05-01.md": "# Note\n\n## 08:00:00\ncall carol\n",
+	}
+}
+
+func TestSearchPlainQuery(t *testing.T) {
+	j, _, _ := newTestJournal(t, searchFiles())
+	tags, err := j.Search("call", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Text != "call bob" || tags[0].LineNo != 8 || tags[0].Time.Format("15:04") != "10:00" {
+		t.Errorf("Search = %v", tags)
+	}
+}
+
+func TestSearchIgnoreCaseNewestFirst(t *testing.T) {
+	j, _, _ := newTestJournal(t, searchFiles())
+	tags, err := j.Search("call", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0].Text != "call bob" || tags[1].Text != "Call Alice" {
+		t.Errorf("Search = %v", tags)
+	}
+}
+
+func TestSearchRegexpAndArchive(t *testing.T) {
+	j, _, _ := newTestJournal(t, searchFiles())
+	tags, err := j.Search("/^call (bob|carol)$/", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[1].Path() != "archive/2023/05/2023-05-01.md" {
+		t.Errorf("Search = %v", tags)
+	}
+	if _, err := j.Search("/(/", false, false); err == nil {
+		t.Error("invalid regexp accepted")
+	}
+}
+
+func TestSearchPorcelain(t *testing.T) {
+	files := searchFiles()
+	files[".journal.json"] = `{"Timezone": "UTC"}`
+	j, _, _ := newTestJournal(t, files)
+	out := captureStdout(t, func() error {
+		return j.PrintSearch("call", true, false, true, false, 0, 0)
+	})
+	want := "2024/01/2024-01-05.md:8:2024-01-05T10:00:00Z:call bob\n2024/01/2024-01-05.md:4:2024-01-05T09:00:00Z:Call Alice\n"
+	if out != want {
+		t.Errorf("porcelain = %q, want %q", out, want)
+	}
+	out = captureStdout(t, func() error {
+		return j.PrintSearch("call", true, false, false, true, 0, 1)
+	})
+	if want := "2024/01/2024-01-05.md:8:2024-01-05T10:00:00Z:call bob\x00"; out != want {
+		t.Errorf("null = %q, want %q", out, want)
+	}
+}
+
+func TestSearchPaging(t *testing.T) {
+	files := map[string]string{".journal.json": `{"Timezone": "UTC"}`}
+	for _, d := range []string{"01", "02", "03", "04", "05"} {
+		files["2024/01/2024-01-"+d+".md"] = "# Note\n\n## 09:00:00\nmatch " + d + "\n"
+	}
+	j, _, _ := newTestJournal(t, files)
+	page := func(offset, limit int) string {
+		out := captureStdout(t, func() error {
+			return j.PrintSearch("match", false, false, true, false, offset, limit)
+		})
+		var days []string
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			if line != "" {
+				days = append(days, line[len(line)-2:])
+			}
+		}
+		return strings.Join(days, " ")
+	}
+	for _, tc := range []struct {
+		offset, limit int
+		want          string
+	}{
+		{0, 0, "05 04 03 02 01"},
+		{0, 2, "05 04"},
+		{2, 2, "03 02"},
+		{4, 2, "01"},
+		{5, 2, ""},
+		{9, 0, ""},
+		{3, 0, "02 01"},
+		{0, 9, "05 04 03 02 01"},
+	} {
+		if got := page(tc.offset, tc.limit); got != tc.want {
+			t.Errorf("offset %d limit %d = %q, want %q", tc.offset, tc.limit, got, tc.want)
+		}
+	}
+	if err := j.PrintSearch("match", false, false, true, false, -1, 0); err == nil {
+		t.Error("negative offset accepted")
+	}
+}
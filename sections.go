@@ -0,0 +1,138 @@
+package diary
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var builtinKinds = []string{"DOING", "TODO", "LATER", "WAITING", "DONE"}
+var customTagPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+var linkPlaceholderPattern = regexp.MustCompile(`\{(\w*)\}`)
+
+type Section struct {
+	Tag   string
+	Title string
+	// Order overrides IndexOrder for the section
+	Order string `json:",omitempty"`
+}
+
+func defaultSections() []Section {
+	return []Section{{Tag: "DOING", Title: "DOING"}, {Tag: "TODO", Title: "TODO"}, {Tag: "LATER", Title: "LATER"}, {Tag: "WAITING", Title: "WAITING"}}
+}
+
+// kinds returns the built-in tag kinds followed by the CustomTags from the
+// config.
+func (j *Journal) kinds() []string {
+	return append(append([]string{}, builtinKinds...), j.CustomTags...)
+}
+
+func (j *Journal) isKind(kind string) bool {
+	for _, k := range j.kinds() {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *Journal) validateSections() error {
+	for _, t := range j.CustomTags {
+		if !customTagPattern.MatchString(t) {
+			return fmt.Errorf("invalid tag type '%s' in CustomTags, expected upper case letters, digits or '_'", t)
+		}
+	}
+	for i, t := range j.CustomTags {
+		for _, k := range j.kinds()[:len(builtinKinds)+i] {
+			if k == t {
+				return fmt.Errorf("duplicate tag type '%s' in CustomTags", t)
+			}
+		}
+	}
+	if !validOrder(j.IndexOrder) {
+		return fmt.Errorf("unknown IndexOrder '%s' in config, expected desc or asc", j.IndexOrder)
+	}
+	for _, s := range j.Sections {
+		if !j.isKind(s.Tag) {
+			return fmt.Errorf("unknown tag type '%s' in Sections, expected one of %s", s.Tag, strings.Join(j.kinds(), ", "))
+		}
+		if !validOrder(s.Order) {
+			return fmt.Errorf("unknown Order '%s' for section %s, expected desc or asc", s.Order, s.Title)
+		}
+	}
+	for kind, format := range j.LinkFormats {
+		if !j.isKind(kind) {
+			return fmt.Errorf("unknown tag type '%s' in LinkFormats, expected one of %s", kind, strings.Join(j.kinds(), ", "))
+		}
+		if !strings.Contains(format, "{path}") {
+			return fmt.Errorf("link format '%s' for %s has no {path}", format, kind)
+		}
+		for _, ms := range linkPlaceholderPattern.FindAllStringSubmatch(format, -1) {
+			switch ms[1] {
+			case "path", "time", "line", "tag":
+			default:
+				return fmt.Errorf("unknown placeholder '%s' in link format for %s, expected {path}, {time}, {line} or {tag}", ms[0], kind)
+			}
+		}
+	}
+	return nil
+}
+
+func validOrder(order string) bool {
+	return order == "" || order == "desc" || order == "asc"
+}
+
+// ascending reports whether section s lists its tags oldest first, by its
+// Order or else IndexOrder.
+func (j *Journal) ascending(s Section) bool {
+	if s.Order != "" {
+		return s.Order == "asc"
+	}
+	return j.IndexOrder == "asc"
+}
+
+// tagLink is the link a tag of kind gets in the index, from LinkFormats or
+// "*[{tag}]({path}#{time})*" by default, dropping the anchor when the tag
+// has no time header above it.
+func (j *Journal) tagLink(kind, label, path, anchor string, lineNo int) string {
+	format, ok := j.LinkFormats[kind]
+	if !ok {
+		if anchor == "" {
+			return fmt.Sprintf("*[%s](%s)*", label, path)
+		}
+		return fmt.Sprintf("*[%s](%s#%s)*", label, path, anchor)
+	}
+	return strings.NewReplacer("{path}", path, "{time}", anchor, "{line}", fmt.Sprint(lineNo), "{tag}", label).Replace(format)
+}
+
+func (j *Journal) compileTagPatterns() {
+	kinds := j.kinds()
+	j.tagRe = regexp.MustCompile(`^\*(` + strings.Join(kinds, "|") + `)(?::(\w+))?\*([:,.;!?]*)$`)
+	j.hashtagRe = regexp.MustCompile(`^#((?i)` + strings.ToLower(strings.Join(kinds, "|")) + `)(?::(\w+))?([:,.;!?]*)$`)
+}
+
+// tagMap returns the per-note tag map for kind. Built-in kinds have their own
+// fields, custom kinds are kept in Custom.
+func (j *Journal) tagMap(kind string) map[string][]Tag {
+	switch kind {
+	case "DOING":
+		return j.Doings
+	case "TODO":
+		return j.Todos
+	case "LATER":
+		return j.Laters
+	case "WAITING":
+		return j.Waitings
+	case "DONE":
+		return j.Done
+	}
+	if j.Custom == nil {
+		j.Custom = make(map[string]map[string][]Tag)
+	}
+	m, ok := j.Custom[kind]
+	if !ok {
+		m = make(map[string][]Tag)
+		j.Custom[kind] = m
+	}
+	return m
+}
@@ -0,0 +1,252 @@
+package diary
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const customSectionsConfig = `{
+	"CustomTags": ["SOMEDAY"],
+	"Sections": [
+		{"Tag": "TODO", "Title": "Next up"},
+		{"Tag": "SOMEDAY", "Title": "Someday maybe"},
+		{"Tag": "DOING", "Title": "In progress"}
+	]
+}`
+
+func TestCustomSectionOrderAndTitles(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": customSectionsConfig,
+		"a.md":          "- *DOING* now\n- *TODO* next\n- *SOMEDAY* dream\n- *LATER* not listed\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Next up\n\n- *[TODO](a.md)* next\n\n# Someday maybe\n\n- *[SOMEDAY](a.md)* dream\n\n# In progress\n\n- *[DOING](a.md)* now\n"
+	if got := renderIndex(t, j); got != want {
+		t.Errorf("index:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestIndexJSONFollowsSections(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": customSectionsConfig,
+		"a.md":          "---\ntitle: A\n---\n- *DOING* now\n- *SOMEDAY* dream\n- *SOMEDAY* another\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := j.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var ix map[string]json.RawMessage
+	if err := json.Unmarshal(out.Bytes(), &ix); err != nil {
+		t.Fatal(err)
+	}
+	var somedays []indexEntry
+	if err := json.Unmarshal(ix["somedays"], &somedays); err != nil || len(somedays) != 2 || somedays[0].Tag != "SOMEDAY" {
+		t.Errorf("somedays = %s", ix["somedays"])
+	}
+	if _, ok := ix["laters"]; ok {
+		t.Errorf("section not configured in index.json: %s", out.String())
+	}
+	if strings.Index(out.String(), `"todos"`) > strings.Index(out.String(), `"somedays"`) || strings.Index(out.String(), `"somedays"`) > strings.Index(out.String(), `"doings"`) || !strings.Contains(out.String(), `"notes"`) {
+		t.Errorf("keys not in section order:\n%s", out.String())
+	}
+}
+
+func TestDefaultIndexJSONKeys(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{"a.md": "- *TODO* a\n"})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := j.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "doings": [],
+  "todos": [
+    {
+      "path": "a.md",
+      "line": 1,
+      "tag": "TODO",
+      "time": "` + j.Todos["a.md"][0].Time.Format("2006-01-02T15:04:05Z07:00") + `",
+      "text": "- *[TODO](a.md)* a"
+    }
+  ],
+  "laters": [],
+  "waitings": []
+}
+`
+	if out.String() != want {
+		t.Errorf("index.json:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestStatsCountCustomTags(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         customSectionsConfig,
+		"2024/01/2024-01-05.md": "# Note\n- *SOMEDAY* a\n- *SOMEDAY* b\n- *TODO* c\n",
+	})
+	tc, err := j.CountTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tc) != 2 || tc[0] != (TagCount{"2024-01 SOMEDAY", 2}) {
+		t.Errorf("CountTags = %v", tc)
+	}
+}
+
+func TestUnknownSectionTag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".journal.json": `{"Sections": [{"Tag": "SOMEDAY", "Title": "x"}]}`})
+	if _, err := OpenJournal(dir, ""); err == nil || !strings.Contains(err.Error(), "unknown tag type 'SOMEDAY' in Sections") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestLinkFormats(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"LinkFormats": {"TODO": "[{tag}]({path}#L{line})", "DOING": "**{tag}** <{path}#{time}>"}}`,
+		"a.md":          "# Note\n## 09:00:00\n- *TODO* github anchor\n- *DOING* plain\n- *LATER* default\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ got, want string }{
+		{j.Todos["a.md"][0].Text, "- [TODO](a.md#L3) github anchor"},
+		{j.Doings["a.md"][0].Text, "- **DOING** <a.md#09:00:00> plain"},
+		{j.Laters["a.md"][0].Text, "- *[LATER](a.md#09:00:00)* default"},
+	} {
+		if c.got != c.want {
+			t.Errorf("text = %q, want %q", c.got, c.want)
+		}
+	}
+}
+
+func TestInvalidLinkFormats(t *testing.T) {
+	for _, c := range []struct{ formats, err string }{
+		{`{"NOPE": "{path}"}`, "unknown tag type 'NOPE' in LinkFormats"},
+		{`{"TODO": "[{tag}](#{line})"}`, "link format '[{tag}](#{line})' for TODO has no {path}"},
+		{`{"TODO": "[{tag}]({path}#{hour})"}`, "unknown placeholder '{hour}' in link format for TODO"},
+	} {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{".journal.json": `{"LinkFormats": ` + c.formats + `}`})
+		if _, err := OpenJournal(dir, ""); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: err = %v", c.formats, err)
+		}
+	}
+}
+
+func TestIndexOrder(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"Timezone": "UTC", "IndexOrder": "asc", "Sections": [` +
+			`{"Tag": "TODO", "Title": "TODO"}, {"Tag": "DOING", "Title": "DOING", "Order": "desc"}]}`,
+		"2024/03/2024-03-01.md": "## 09:00:00\n- *TODO* first\n- *DOING* first doing\n",
+		"2024/03/2024-03-02.md": "## 09:00:00\n- *TODO* second\n- *DOING* second doing\n",
+		"2024/03/2024-03-03.md": "## 09:00:00\n- *TODO* third\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	index := renderIndex(t, j)
+	order := func(words ...string) bool {
+		last := -1
+		for _, w := range words {
+			i := strings.Index(index, w+"\n")
+			if i < 0 || i < last {
+				return false
+			}
+			last = i
+		}
+		return true
+	}
+	if !order("* first", "* second", "* third") {
+		t.Errorf("TODO not oldest first:\n%s", index)
+	}
+	if !order("second doing", "first doing") {
+		t.Errorf("DOING not newest first:\n%s", index)
+	}
+}
+
+func TestIndexOrderLimitKeepsNewest(t *testing.T) {
+	files := map[string]string{
+		"2024/03/2024-03-01.md": "## 09:00:00\n- *TODO* oldest\n",
+		"2024/03/2024-03-02.md": "## 09:00:00\n- *TODO* middle\n",
+		"2024/03/2024-03-03.md": "## 09:00:00\n- *TODO:A* urgent newest\n",
+	}
+	for _, order := range []string{"asc", "desc"} {
+		files[".journal.json"] = `{"Timezone": "UTC", "IndexOrder": "` + order + `"}`
+		j, _, _ := newTestJournal(t, files)
+		if err := j.ProcessAll(); err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		more := j.writeTags(&out, j.Todos, 2, order == "asc")
+		want := "- *[TODO:A](2024/03/2024-03-03.md#09:00:00)* urgent newest\n- *[TODO](2024/03/2024-03-02.md#09:00:00)* middle\n"
+		if out.String() != want || more != 1 {
+			t.Errorf("%s: %d more, index:\n%s\nwant:\n%s", order, more, out.String(), want)
+		}
+	}
+}
+
+func TestSplitIndex(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"SplitIndex": true, "Sections": [{"Tag": "DOING", "Title": "In progress"}, {"Tag": "TODO", "Title": "Next up"}]}`,
+		"index.md":      "# DOING\n\n- *[DOING](a.md)* stale combined index\n",
+		"a.md":          "- *DOING* now\n- *TODO* next\n",
+		"notes/todo.md": "- *TODO* a note named like a split file\n",
+	})
+	j.SetNoCommit(true)
+	for i := 0; i < 2; i++ {
+		if err := j.ProcessAll(); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(j.Doings) != 1 || len(j.Todos) != 2 || j.Todos["notes/todo.md"] == nil {
+		t.Errorf("doings %v, todos %v", j.Doings, j.Todos)
+	}
+	if got, want := readFile(t, filepath.Join(j.path, "doing.md")), splitMarker+"\n# In progress\n\n- *[DOING](a.md)* now\n"; got != want {
+		t.Errorf("doing.md:\n%s\nwant:\n%s", got, want)
+	}
+	todo := readFile(t, filepath.Join(j.path, "todo.md"))
+	for _, want := range []string{splitMarker + "\n# Next up\n\n", "- *[TODO](a.md)* next\n", "- *[TODO](notes/todo.md)* a note named like a split file\n"} {
+		if !strings.Contains(todo, want) {
+			t.Errorf("todo.md without %q:\n%s", want, todo)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(j.path, "index.md")); !os.IsNotExist(err) {
+		t.Errorf("combined index left: %v", err)
+	}
+}
+
+func TestSplitIndexKeepsNotes(t *testing.T) {
+	note := "# Todo\n\n- buy milk\n"
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"SplitIndex": true}`,
+		"a.md":          "- *DOING* now\n",
+		"todo.md":       note,
+	})
+	j.SetNoCommit(true)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Write(); err == nil || err.Error() != "'todo.md' is a note, not a split index file: rename it, or turn SplitIndex off" {
+		t.Errorf("err = %v", err)
+	}
+	if got := readFile(t, filepath.Join(j.path, "todo.md")); got != note {
+		t.Errorf("note overwritten:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(j.path, "doing.md")); !os.IsNotExist(err) {
+		t.Errorf("split files written: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package diary
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Snoozed returns the LATER tags whose wake date is after the day of now,
+// soonest first. They stay out of the index until then.
+func (j *Journal) Snoozed(now time.Time) ([]Tag, error) {
+	tags, err := j.collectTags()
+	if err != nil {
+		return nil, err
+	}
+	today := startOfDay(now)
+	var result []Tag
+	for _, t := range tags {
+		if t.Wake != nil && t.Wake.After(today) {
+			result = append(result, t)
+		}
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		if !result[a].Wake.Equal(*result[b].Wake) {
+			return result[a].Wake.Before(*result[b].Wake)
+		}
+		if result[a].Path() != result[b].Path() {
+			return result[a].Path() < result[b].Path()
+		}
+		return result[a].LineNo < result[b].LineNo
+	})
+	return result, nil
+}
+
+func (j *Journal) PrintSnoozed() error {
+	tags, err := j.Snoozed(j.now())
+	if err != nil {
+		return err
+	}
+	if j.json {
+		return writeResult(tagResults(tags))
+	}
+	tt := j.newTable("WAKE", "NOTE", "TEXT")
+	for _, t := range tags {
+		tt.add(t.Wake.Format("2006-01-02"), fmt.Sprintf("%s:%d", t.Path(), t.LineNo), t.Text)
+	}
+	return tt.write(os.Stdout)
+}
@@ -0,0 +1,59 @@
+package diary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSnoozeWakesAtMidnightInTimezone(t *testing.T) {
+	j, _, log := newTestJournal(t, map[string]string{
+		".journal.json": `{"Timezone": "Asia/Jakarta"}`,
+		"a.md":          "- *LATER* renew passport @wake:2024-03-05\n- *LATER* read a book\n- *TODO* not snoozed @wake:2024-03-09\n- *LATER* typo @wake:2024-13-01\n",
+	})
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(log.String(), "invalid wake date '2024-13-01' in 'a.md' line 4") {
+		t.Errorf("log:\n%s", log)
+	}
+	for _, c := range []struct {
+		now     string
+		snoozed bool
+	}{
+		// 23:59:59 and midnight in Jakarta, UTC+7
+		{"2024-03-04T16:59:59Z", true},
+		{"2024-03-04T17:00:00Z", false},
+	} {
+		setNow(t, j, c.now)
+		index := renderIndex(t, j)
+		if strings.Contains(index, "renew passport") == c.snoozed {
+			t.Errorf("%s: passport in the index %t:\n%s", c.now, !c.snoozed, index)
+		}
+		if !strings.Contains(index, "read a book") || !strings.Contains(index, "not snoozed") || !strings.Contains(index, "typo") {
+			t.Errorf("%s: index misses unsnoozed tags:\n%s", c.now, index)
+		}
+		tags, err := j.Snoozed(j.now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.snoozed != (len(tags) == 1) || (c.snoozed && tags[0].Wake.Format("2006-01-02 MST") != "2024-03-05 WIB") {
+			t.Errorf("%s: snoozed = %v", c.now, tags)
+		}
+	}
+}
+
+func TestPrintSnoozed(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json": `{"Timezone": "UTC"}`,
+		"a.md":          "- *LATER* second @wake:2024-04-01\n- *LATER* first @wake:2024-03-10\n",
+	})
+	setNow(t, j, "2024-03-01T12:00:00Z")
+	out := captureStdout(t, j.PrintSnoozed)
+	want := "" +
+		"WAKE        NOTE    TEXT\n" +
+		"2024-03-10  a.md:2  - *[LATER](a.md)* first `@wake:2024-03-10`\n" +
+		"2024-04-01  a.md:1  - *[LATER](a.md)* second `@wake:2024-04-01`\n"
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
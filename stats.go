@@ -0,0 +1,137 @@
+package diary
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportKinds are the open tag kinds the reports count, every kind but DONE
+// including the CustomTags.
+func (j *Journal) reportKinds() []string {
+	var kinds []string
+	for _, k := range j.kinds() {
+		if k != "DONE" {
+			kinds = append(kinds, k)
+		}
+	}
+	return kinds
+}
+
+func (j *Journal) isReportKind(kind string) bool {
+	return kind != "DONE" && j.isKind(kind)
+}
+
+func (j *Journal) CountTags() (TagCounts, error) {
+	tags, err := j.collectTags()
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, t := range tags {
+		if j.isReportKind(t.Tag) {
+			counts[fmt.Sprintf("%s %s", t.Time.Format("2006-01"), t.Tag)]++
+		}
+	}
+	var keys []string
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var tc TagCounts
+	for _, k := range keys {
+		tc = append(tc, TagCount{Tag: k, Count: counts[k]})
+	}
+	sort.Stable(sort.Reverse(tc))
+	return tc, nil
+}
+
+func (j *Journal) PrintStats() error {
+	tc, err := j.CountTags()
+	if err != nil {
+		return err
+	}
+	if j.json {
+		result := []StatResult{}
+		for _, c := range tc {
+			month, kind, _ := strings.Cut(c.Tag, " ")
+			result = append(result, StatResult{Month: month, Tag: kind, Count: c.Count})
+		}
+		return writeResult(result)
+	}
+	t := j.newTable("#", "MONTH", "TAG", "COUNT")
+	t.alignRight(0, 3)
+	for i, c := range tc {
+		month, kind, _ := strings.Cut(c.Tag, " ")
+		t.add(strconv.Itoa(i+1), month, kind, strconv.Itoa(c.Count))
+	}
+	return t.write(os.Stdout)
+}
+
+// Streaks counts consecutive days with a diary entry, archived entries
+// included. The current streak ends today, or yesterday when today has no
+// entry yet.
+func (j *Journal) Streaks(now time.Time) (current, longest int, err error) {
+	days := make(map[string]bool)
+	err = j.walkTree(true, func(n *Note) error {
+		if n.Type == Diary {
+			days[n.Time.Format("2006-01-02")] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	current, longest = streaks(days, startOfDay(now.In(j.location())))
+	return current, longest, nil
+}
+
+func streaks(days map[string]bool, today time.Time) (current, longest int) {
+	var keys []string
+	for d := range days {
+		keys = append(keys, d)
+	}
+	sort.Strings(keys)
+	run := 0
+	var prev time.Time
+	for _, k := range keys {
+		d, err := time.ParseInLocation("2006-01-02", k, today.Location())
+		if err != nil {
+			continue
+		}
+		if run > 0 && prev.AddDate(0, 0, 1).Equal(d) {
+			run++
+		} else {
+			run = 1
+		}
+		prev = d
+		if run > longest {
+			longest = run
+		}
+	}
+	end := today
+	if !days[end.Format("2006-01-02")] {
+		end = end.AddDate(0, 0, -1)
+	}
+	for days[end.Format("2006-01-02")] {
+		current++
+		end = end.AddDate(0, 0, -1)
+	}
+	return current, longest
+}
+
+func (j *Journal) PrintStreaks() error {
+	current, longest, err := j.Streaks(j.now())
+	if err != nil {
+		return err
+	}
+	if j.json {
+		return writeResult(StreakResult{Current: current, Longest: longest})
+	}
+	fmt.Printf("current streak: %d days\n", current)
+	fmt.Printf("longest streak: %d days\n", longest)
+	return nil
+}
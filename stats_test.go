@@ -0,0 +1,76 @@
+package diary
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountTagsPerMonth(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2024/01/2024-01-05.md": "# Note\n\n## 09:00:00\n- *TODO* a\n- *TODO* b\n- *DOING* c\n- *DONE* d\n",
+		"2024/02/2024-02-01.md": "# Note\n\n## 09:00:00\n- *TODO* e\n- *LATER* f\n- *LATER* g\n- *LATER* h\n",
+	})
+	tc, err := j.CountTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TagCounts{
+		{"2024-02 LATER", 3},
+		{"2024-01 TODO", 2},
+		{"2024-01 DOING", 1},
+		{"2024-02 TODO", 1},
+	}
+	if len(tc) != len(want) {
+		t.Fatalf("CountTags = %v, want %v", tc, want)
+	}
+	for i := range want {
+		if tc[i] != want[i] {
+			t.Errorf("CountTags[%d] = %v, want %v", i, tc[i], want[i])
+		}
+	}
+}
+
+func dayset(days ...string) map[string]bool {
+	set := make(map[string]bool)
+	for _, d := range days {
+		set[d] = true
+	}
+	return set
+}
+
+func TestStreaks(t *testing.T) {
+	today := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name             string
+		days             map[string]bool
+		current, longest int
+	}{
+		{"empty", dayset(), 0, 0},
+		{"single day today", dayset("2024-03-10"), 1, 1},
+		{"single day long ago", dayset("2024-01-01"), 0, 1},
+		{"ending yesterday", dayset("2024-03-07", "2024-03-08", "2024-03-09"), 3, 3},
+		{"ending two days ago", dayset("2024-03-07", "2024-03-08"), 0, 2},
+		{"gaps", dayset("2024-02-01", "2024-02-02", "2024-02-03", "2024-02-04", "2024-03-01", "2024-03-09", "2024-03-10"), 2, 4},
+		{"across months", dayset("2024-02-28", "2024-02-29", "2024-03-01"), 0, 3},
+	} {
+		current, longest := streaks(tc.days, today)
+		if current != tc.current || longest != tc.longest {
+			t.Errorf("%s: streaks = %d, %d, want %d, %d", tc.name, current, longest, tc.current, tc.longest)
+		}
+	}
+}
+
+func TestStreaksIncludeArchive(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"archive/2024/03/2024-03-08.md": "# Note\n",
+		"2024/03/2024-03-09.md":         "# Note\n",
+		"notes/2024-03-10.md":           "not a diary note\n",
+	})
+	current, longest, err := j.Streaks(time.Date(2024, 3, 10, 20, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != 2 || longest != 2 {
+		t.Errorf("Streaks = %d, %d, want 2, 2", current, longest)
+	}
+}
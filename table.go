@@ -0,0 +1,115 @@
+package diary
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+var kindColors = map[string]string{"DOING": "33", "TODO": "31", "LATER": "34", "WAITING": "35", "DONE": "32"}
+
+// table aligns report output in columns. Cells naming a tag kind are
+// colored when color is on; the last column is cut to the terminal width.
+type table struct {
+	header []string
+	right  []bool
+	rows   [][]string
+	width  int
+	color  bool
+	plain  bool
+}
+
+func (j *Journal) newTable(header ...string) *table {
+	t := &table{header: header, right: make([]bool, len(header)), plain: j.plain}
+	if !j.plain && isTerminal(os.Stdout) {
+		t.width = terminalWidth(os.Stdout)
+		t.color = os.Getenv("NO_COLOR") == ""
+	}
+	return t
+}
+
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+// alignRight right aligns the given columns, typically numbers.
+func (t *table) alignRight(cols ...int) {
+	for _, c := range cols {
+		t.right[c] = true
+	}
+}
+
+func (t *table) add(cols ...string) {
+	t.rows = append(t.rows, cols)
+}
+
+// write prints the table. Plain tables are tab separated without a header,
+// for use by other programs.
+func (t *table) write(w io.Writer) error {
+	if t.plain {
+		for _, r := range t.rows {
+			if _, err := fmt.Fprintln(w, strings.Join(r, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	widths := make([]int, len(t.header))
+	for _, r := range append([][]string{t.header}, t.rows...) {
+		for i, c := range r {
+			if i < len(widths) && utf8.RuneCountInString(c) > widths[i] {
+				widths[i] = utf8.RuneCountInString(c)
+			}
+		}
+	}
+	for _, r := range append([][]string{t.header}, t.rows...) {
+		if _, err := fmt.Fprintln(w, t.line(r, widths)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *table) line(r []string, widths []int) string {
+	var out strings.Builder
+	used := 0
+	for i, c := range r {
+		if i > 0 {
+			out.WriteString("  ")
+			used += 2
+		}
+		n := utf8.RuneCountInString(c)
+		last := i == len(r)-1
+		if last && t.width > 0 && used+n > t.width && t.width-used > 1 {
+			c = string([]rune(c)[:t.width-used-1]) + "…"
+			n = t.width - used
+		}
+		pad := ""
+		if i < len(widths) && n < widths[i] && (!last || t.right[i]) {
+			pad = strings.Repeat(" ", widths[i]-n)
+		}
+		if t.right[i] {
+			out.WriteString(pad)
+		}
+		out.WriteString(t.colorize(c))
+		if !t.right[i] {
+			out.WriteString(pad)
+		}
+		used += n + len(pad)
+	}
+	return strings.TrimRight(out.String(), " ")
+}
+
+func (t *table) colorize(c string) string {
+	if !t.color {
+		return c
+	}
+	code, ok := kindColors[c]
+	if !ok {
+		return c
+	}
+	return "\x1b[" + code + "m" + c + "\x1b[0m"
+}
@@ -0,0 +1,79 @@
+package diary
+
+import (
+	"bytes"
+	"testing"
+)
+
+func tableOutput(t *testing.T, tb *table) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := tb.write(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func sampleTable() *table {
+	tb := &table{header: []string{"KIND", "COUNT", "TEXT"}, right: make([]bool, 3)}
+	tb.alignRight(1)
+	tb.add("TODO", "12", "call bob")
+	tb.add("WAITING", "3", "reply from ops about the outage")
+	return tb
+}
+
+func TestTableAlignment(t *testing.T) {
+	want := "" +
+		"KIND     COUNT  TEXT\n" +
+		"TODO        12  call bob\n" +
+		"WAITING      3  reply from ops about the outage\n"
+	if got := tableOutput(t, sampleTable()); got != want {
+		t.Errorf("table:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTableCutsLastColumnToWidth(t *testing.T) {
+	tb := sampleTable()
+	tb.width = 30
+	want := "" +
+		"KIND     COUNT  TEXT\n" +
+		"TODO        12  call bob\n" +
+		"WAITING      3  reply from op…\n"
+	got := tableOutput(t, tb)
+	if got != want {
+		t.Errorf("table:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTableColor(t *testing.T) {
+	tb := sampleTable()
+	tb.color = true
+	want := "" +
+		"KIND     COUNT  TEXT\n" +
+		"\x1b[31mTODO\x1b[0m        12  call bob\n" +
+		"\x1b[35mWAITING\x1b[0m      3  reply from ops about the outage\n"
+	if got := tableOutput(t, tb); got != want {
+		t.Errorf("table:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestTablePlain(t *testing.T) {
+	tb := sampleTable()
+	tb.plain = true
+	want := "TODO\t12\tcall bob\nWAITING\t3\treply from ops about the outage\n"
+	if got := tableOutput(t, tb); got != want {
+		t.Errorf("table:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestNewTableWithoutTerminal(t *testing.T) {
+	j, _, _ := newTestJournal(t, nil)
+	tb := j.newTable("KIND")
+	if tb.color || tb.width != 0 || tb.plain {
+		t.Errorf("table on a pipe: color %t, width %d, plain %t", tb.color, tb.width, tb.plain)
+	}
+	j.SetPlain(true)
+	if tb := j.newTable("KIND"); !tb.plain {
+		t.Error("plain journal makes a formatted table")
+	}
+}
@@ -0,0 +1,81 @@
+package diary
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const defaultStaleDays = 14
+
+// agedKinds are the tag kinds that go stale when left open.
+var agedKinds = map[string]bool{"DOING": true, "TODO": true}
+
+// staleDays is the age in days from which an open tag is stale, StaleDays
+// from the config or 14.
+func (j *Journal) staleDays() int {
+	if j.StaleDays > 0 {
+		return j.StaleDays
+	}
+	return defaultStaleDays
+}
+
+// tagAge is the number of days from the day of t to the day of now, both
+// taken in the journal timezone.
+func (j *Journal) tagAge(t Tag, now time.Time) int {
+	from := startOfDay(t.Time.In(j.location()))
+	to := startOfDay(now.In(j.location()))
+	return daysBetween(from, to)
+}
+
+// isStale reports whether t is an open DOING or TODO at least days old.
+func (j *Journal) isStale(t Tag, now time.Time, days int) bool {
+	return agedKinds[t.Tag] && j.tagAge(t, now) >= days
+}
+
+// Stale returns the stale DOING and TODO tags, oldest first.
+func (j *Journal) Stale(now time.Time) ([]Tag, error) {
+	tags, err := j.collectTags()
+	if err != nil {
+		return nil, err
+	}
+	var result []Tag
+	for _, t := range tags {
+		if j.isStale(t, now, j.staleDays()) {
+			result = append(result, t)
+		}
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		if !result[a].Time.Equal(result[b].Time) {
+			return result[a].Time.Before(result[b].Time)
+		}
+		if result[a].Path() != result[b].Path() {
+			return result[a].Path() < result[b].Path()
+		}
+		return result[a].LineNo < result[b].LineNo
+	})
+	return result, nil
+}
+
+func (j *Journal) PrintStale() error {
+	now := j.now()
+	tags, err := j.Stale(now)
+	if err != nil {
+		return err
+	}
+	if j.json {
+		result := []AgedResult{}
+		for _, t := range tags {
+			result = append(result, AgedResult{TagResult: tagResult(t), Days: j.tagAge(t, now)})
+		}
+		return writeResult(result)
+	}
+	tt := j.newTable("AGE", "TAG", "NOTE", "TEXT")
+	tt.alignRight(0)
+	for _, t := range tags {
+		tt.add(strconv.Itoa(j.tagAge(t, now))+"d", t.Tag, fmt.Sprintf("%s:%d", t.Path(), t.LineNo), t.Text)
+	}
+	return tt.write(os.Stdout)
+}
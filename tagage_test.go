@@ -0,0 +1,95 @@
+package diary
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// now is 2024-03-16 03:00 in Jakarta but still 2024-03-15 in UTC, so the
+// ages below only straddle the threshold when taken in the journal timezone
+const staleNow = "2024-03-15T20:00:00Z"
+
+func newStaleJournal(t *testing.T, config string) *Journal {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":         config,
+		"2024/03/2024-03-01.md": "## 09:00:00\n- *TODO* oldest\n- *DONE* finished\n",
+		"2024/03/2024-03-02.md": "## 09:00:00\n- *DOING* exactly at the threshold\n- *LATER* not aged\n",
+		"2024/03/2024-03-03.md": "## 09:00:00\n- *TODO* a day short\n",
+	})
+	j.SetNoCommit(true)
+	setNow(t, j, staleNow)
+	if err := j.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestStaleThreshold(t *testing.T) {
+	j := newStaleJournal(t, `{"Timezone": "Asia/Jakarta"}`)
+	tags, err := j.Stale(j.now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tag := range tags {
+		got = append(got, tag.Tag+" "+tag.body)
+	}
+	if want := "TODO oldest|DOING exactly at the threshold"; strings.Join(got, "|") != want {
+		t.Errorf("stale = %q, want %q", got, want)
+	}
+
+	j.SetJSON(true)
+	out := captureStdout(t, j.PrintStale)
+	if !strings.Contains(out, `"Days": 15`) || !strings.Contains(out, `"Days": 14`) || strings.Contains(out, `"Days": 13`) {
+		t.Errorf("json:\n%s", out)
+	}
+}
+
+func TestStaleUsesTimezone(t *testing.T) {
+	// in UTC the same notes are a day younger, the DOING drops below 14 days
+	j := newStaleJournal(t, `{"Timezone": "UTC"}`)
+	tags, err := j.Stale(j.now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].body != "oldest" {
+		t.Errorf("stale = %v", tags)
+	}
+}
+
+func TestStaleDaysConfig(t *testing.T) {
+	j := newStaleJournal(t, `{"Timezone": "Asia/Jakarta", "StaleDays": 13}`)
+	tags, err := j.Stale(j.now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 3 || tags[2].body != "a day short" {
+		t.Errorf("stale = %v", tags)
+	}
+}
+
+func TestStaleIndexMarker(t *testing.T) {
+	j := newStaleJournal(t, `{"Timezone": "Asia/Jakarta", "StaleDays": 14}`)
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	index := readFile(t, filepath.Join(j.path, "index.md"))
+	for _, want := range []string{"oldest _(open since 2024-03-01)_", "exactly at the threshold _(open since 2024-03-02)_"} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index without %q:\n%s", want, index)
+		}
+	}
+	if strings.Contains(index, "a day short _(open since") || strings.Contains(index, "not aged _(open since") {
+		t.Errorf("index marks young tags:\n%s", index)
+	}
+
+	// without StaleDays in the config the index is left unmarked
+	j = newStaleJournal(t, `{"Timezone": "Asia/Jakarta"}`)
+	if err := j.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if index := readFile(t, filepath.Join(j.path, "index.md")); strings.Contains(index, "open since") {
+		t.Errorf("index marked without StaleDays:\n%s", index)
+	}
+}
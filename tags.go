@@ -0,0 +1,80 @@
+package diary
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type WeekCount struct {
+	Week   string
+	Counts map[string]int
+	Total  int
+}
+
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+func (j *Journal) WeeklyTags(since time.Time) ([]WeekCount, error) {
+	tags, err := j.collectTags()
+	if err != nil {
+		return nil, err
+	}
+	weeks := make(map[string]*WeekCount)
+	for _, t := range tags {
+		if !j.isReportKind(t.Tag) || t.Time.Before(since) {
+			continue
+		}
+		w := isoWeek(t.Time)
+		wc, ok := weeks[w]
+		if !ok {
+			wc = &WeekCount{Week: w, Counts: make(map[string]int)}
+			weeks[w] = wc
+		}
+		wc.Counts[t.Tag]++
+		wc.Total++
+	}
+	var result []WeekCount
+	for _, wc := range weeks {
+		result = append(result, *wc)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Week < result[b].Week
+	})
+	return result, nil
+}
+
+func (j *Journal) PrintWeeklyTags(sinceWeeks int) error {
+	var since time.Time
+	if sinceWeeks > 0 {
+		since = startOfDay(j.now()).AddDate(0, 0, -7*sinceWeeks)
+	}
+	weeks, err := j.WeeklyTags(since)
+	if err != nil {
+		return err
+	}
+	if j.json {
+		if weeks == nil {
+			weeks = []WeekCount{}
+		}
+		return writeResult(weeks)
+	}
+	kinds := j.reportKinds()
+	t := j.newTable(append(append([]string{"WEEK"}, kinds...), "TOTAL")...)
+	for i := range kinds {
+		t.alignRight(i + 1)
+	}
+	t.alignRight(len(kinds) + 1)
+	for _, wc := range weeks {
+		row := []string{wc.Week}
+		for _, k := range kinds {
+			row = append(row, strconv.Itoa(wc.Counts[k]))
+		}
+		t.add(append(row, strconv.Itoa(wc.Total))...)
+	}
+	return t.write(os.Stdout)
+}
@@ -0,0 +1,50 @@
+package diary
+
+import (
+	"testing"
+	"time"
+)
+
+func TestISOWeekAcrossYearRollover(t *testing.T) {
+	for day, want := range map[string]string{
+		"2020-12-31": "2020-W53",
+		"2021-01-03": "2020-W53",
+		"2021-01-04": "2021-W01",
+		"2024-12-29": "2024-W52",
+		"2024-12-30": "2025-W01",
+		"2026-01-01": "2026-W01",
+	} {
+		d, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isoWeek(d); got != want {
+			t.Errorf("isoWeek(%s) = %s, want %s", day, got, want)
+		}
+	}
+}
+
+func TestWeeklyTagsAcrossYearRollover(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		"2020/12/2020-12-31.md": "# Note\n\n## 23:59:59\n- *TODO* a\n",
+		"2021/01/2021-01-03.md": "# Note\n\n## 10:00:00\n- *DOING* b\n- *DONE* not counted\n",
+		"2021/01/2021-01-04.md": "# Note\n\n## 00:00:00\n- *LATER* c\n- *WAITING* d\n",
+	})
+	weeks, err := j.WeeklyTags(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weeks) != 2 {
+		t.Fatalf("weeks = %v", weeks)
+	}
+	if w := weeks[0]; w.Week != "2020-W53" || w.Total != 2 || w.Counts["TODO"] != 1 || w.Counts["DOING"] != 1 {
+		t.Errorf("first week = %v", w)
+	}
+	if w := weeks[1]; w.Week != "2021-W01" || w.Total != 2 || w.Counts["LATER"] != 1 || w.Counts["WAITING"] != 1 {
+		t.Errorf("second week = %v", w)
+	}
+	since := time.Date(2021, 1, 4, 0, 0, 0, 0, time.Local)
+	if weeks, err := j.WeeklyTags(since); err != nil || len(weeks) != 1 || weeks[0].Week != "2021-W01" {
+		t.Errorf("weeks since %s = %v, %v", since, weeks, err)
+	}
+}
@@ -0,0 +1,67 @@
+package diary
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// templateDir holds the diary templates: template.md renders a new day,
+// entry.md is appended for each later entry of the same day. Without them
+// the built-in "# Note YYYY-MM-DD" and "## HH:MM:SS" scaffold is used, the
+// title following TitleFormat.
+const templateDir = ".journal"
+
+const defaultTitleFormat = "Note {{date}}"
+
+func (j *Journal) diaryTemplate(exists bool) (string, bool, error) {
+	name := "template.md"
+	if exists {
+		name = "entry.md"
+	}
+	data, err := ioutil.ReadFile(filepath.Join(j.path, templateDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("error read template '%s': %w", name, err)
+	}
+	return string(data), true, nil
+}
+
+func (j *Journal) renderTemplate(tmpl string, now time.Time) string {
+	return strings.NewReplacer(
+		"{{date}}", now.Format("2006-01-02"),
+		"{{time}}", now.Format(j.timeLayout()),
+		"{{weekday}}", now.Weekday().String(),
+		"{{isoweek}}", isoWeek(now),
+	).Replace(tmpl)
+}
+
+// appendTemplate writes the rendered template at the end of ff and returns
+// the last line, where the cursor goes.
+func (j *Journal) appendTemplate(ff string, now time.Time, exists bool, tmpl string) (int, error) {
+	var data []byte
+	if exists {
+		var err error
+		data, err = ioutil.ReadFile(ff)
+		if err != nil {
+			return 0, fmt.Errorf("error read file '%s': %w", ff, err)
+		}
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+	}
+	data = append(data, j.renderTemplate(tmpl, now)...)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	if err := ioutil.WriteFile(ff, data, 0644); err != nil {
+		return 0, fmt.Errorf("error write file '%s': %w", ff, err)
+	}
+	return bytes.Count(data, []byte("\n")), nil
+}
@@ -0,0 +1,66 @@
+package diary
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	j := &Journal{}
+	now := time.Date(2024, 3, 4, 9, 30, 5, 0, time.UTC)
+	got := j.renderTemplate("# {{weekday}} {{date}} ({{isoweek}})\n## {{time}}\nTop 3:\n{{unknown}}\n", now)
+	if want := "# Monday 2024-03-04 (2024-W10)\n## 09:30:05\nTop 3:\n{{unknown}}\n"; got != want {
+		t.Errorf("rendered:\n%s\nwant:\n%s", got, want)
+	}
+	j.TimeFormat = "HH:MM"
+	if got := j.renderTemplate("{{time}}", now); got != "09:30" {
+		t.Errorf("HH:MM time = %q", got)
+	}
+}
+
+func TestDiaryTemplates(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{
+		".journal.json":        `{"Timezone": "UTC"}`,
+		".journal/template.md": "# {{weekday}} {{date}}\n\nGratitude:\n\n## {{time}}\n",
+		".journal/entry.md":    "\n## {{time}}\nTop 3:",
+	})
+	j.SetNoEdit(true)
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-04T09:30:00Z")
+	if err := j.CreateDiary(); err != nil {
+		t.Fatal(err)
+	}
+	ff := filepath.Join(j.path, "2024/03/2024-03-04.md")
+	if got, want := readFile(t, ff), "# Monday 2024-03-04\n\nGratitude:\n\n## 09:30:00\n"; got != want {
+		t.Errorf("new day:\n%s\nwant:\n%s", got, want)
+	}
+	tmpl, ok, err := j.diaryTemplate(true)
+	if err != nil || !ok {
+		t.Fatalf("entry template %t, %v", ok, err)
+	}
+	line, err := j.appendTemplate(ff, time.Date(2024, 3, 4, 14, 0, 0, 0, time.UTC), true, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Monday 2024-03-04\n\nGratitude:\n\n## 09:30:00\n\n## 14:00:00\nTop 3:\n"
+	if got := readFile(t, ff); got != want {
+		t.Errorf("entry:\n%s\nwant:\n%s", got, want)
+	}
+	if line != 8 {
+		t.Errorf("cursor line = %d", line)
+	}
+}
+
+func TestDiaryWithoutTemplate(t *testing.T) {
+	j, _, _ := newTestJournal(t, map[string]string{".journal.json": `{"Timezone": "UTC"}`})
+	j.SetNoEdit(true)
+	j.SetNoCommit(true)
+	setNow(t, j, "2024-03-04T09:30:00Z")
+	if err := j.CreateDiary(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readFile(t, filepath.Join(j.path, "2024/03/2024-03-04.md")), "# Note 2024-03-04\n\n## 09:30:00\n\n\n"; got != want {
+		t.Errorf("scaffold:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package diary
+
+import "os"
+
+func terminalWidth(f *os.File) int {
+	return 0
+}
+
+func interactive(f *os.File) bool {
+	return isTerminal(f)
+}
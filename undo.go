@@ -0,0 +1,35 @@
+package diary
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Undo drops the last commit when its message matches the CommitMessage
+// template, keeping its changes staged. Other commits are left alone.
+func (j *Journal) Undo() error {
+	if !j.hasRepo() {
+		return fmt.Errorf("nothing to undo, '%s' is not a git repository", j.path)
+	}
+	msg, err := j.git.LastMessage()
+	if err != nil {
+		return err
+	}
+	msg = strings.TrimSpace(msg)
+	if !j.isAutoCommit(msg) {
+		return fmt.Errorf("last commit '%s' was not made by diary, refusing to undo", msg)
+	}
+	fmt.Printf("undo commit %s\n", msg)
+	if j.dryRun {
+		return nil
+	}
+	if err := j.git.ResetSoft("HEAD~1"); err != nil {
+		return err
+	}
+	hash, err := j.git.RevParse("HEAD")
+	if err != nil {
+		return err
+	}
+	j.Hash = strings.TrimSpace(hash)
+	return j.writeConfig()
+}
@@ -0,0 +1,72 @@
+package diary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUndoAutoCommit(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	j.Hash = "1111111"
+	git.message = "2024-03-01 09:00:00"
+	git.head = "2222222"
+	out := captureStdout(t, j.Undo)
+	if out != "undo commit 2024-03-01 09:00:00\n" {
+		t.Errorf("output = %q", out)
+	}
+	if calls := git.called("reset"); len(calls) != 1 || calls[0] != "reset --soft HEAD~1" {
+		t.Errorf("reset calls = %q", calls)
+	}
+	if j.Hash != "2222222" {
+		t.Errorf("hash = %s", j.Hash)
+	}
+	if config := readFile(t, j.configPath()); !strings.Contains(config, `"Hash": "2222222"`) {
+		t.Errorf("config:\n%s", config)
+	}
+}
+
+func TestUndoRefusesManualCommit(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	j.Hash = "1111111"
+	git.message = "Fix typo in the standup notes"
+	if err := j.Undo(); err == nil || !strings.Contains(err.Error(), "was not made by diary") {
+		t.Errorf("err = %v", err)
+	}
+	if len(git.called("reset")) != 0 || j.Hash != "1111111" {
+		t.Errorf("calls %q, hash %s", git.calls, j.Hash)
+	}
+}
+
+func TestUndoDryRun(t *testing.T) {
+	j, git, _ := newTestJournal(t, nil)
+	j.SetDryRun(true)
+	git.message = "2024-03-01 09:00:00"
+	captureStdout(t, j.Undo)
+	if len(git.called("reset")) != 0 {
+		t.Errorf("calls = %q", git.calls)
+	}
+}
+
+func TestIsAutoCommit(t *testing.T) {
+	for _, c := range []struct {
+		tmpl, msg string
+		auto      bool
+	}{
+		{"", "2024-03-01 09:00:00", true},
+		{"", "2024-03-01 09:00:00 and more", false},
+		{"{summary}", "+2 todos, 1 done", true},
+		{"{summary}", "+1 dailys", true},
+		{"{summary}", "Fix typo", false},
+		{"{summary}", "Merge branch 'main'", false},
+		{"{date} {summary}", "2024-03-01 09:00:00", true},
+		{"{date} {summary}", "2024-03-01 09:00:00 +1 todos", true},
+		{"{date} {summary}", "2024-03-01 09:00:00 fix the link", false},
+		{"diary: {count} files", "diary: 3 files", true},
+		{"diary: {count} files", "diary: some files", false},
+	} {
+		j := &Journal{CommitMessage: c.tmpl}
+		if got := j.isAutoCommit(c.msg); got != c.auto {
+			t.Errorf("isAutoCommit(%q) with %q = %t", c.msg, c.tmpl, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package diary
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func (j *Journal) watchDebounce() time.Duration {
+	if j.WatchDebounce > 0 {
+		return time.Duration(j.WatchDebounce) * time.Millisecond
+	}
+	return 500 * time.Millisecond
+}
+
+func (j *Journal) addWatchDirs(w *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" || path == filepath.Join(j.path, editDir) {
+			return filepath.SkipDir
+		}
+		if rel, err := filepath.Rel(j.path, path); err == nil && j.tooDeep(rel) {
+			return filepath.SkipDir
+		}
+		if err := w.Add(path); err != nil {
+			return fmt.Errorf("error watch '%s': %w", path, err)
+		}
+		return nil
+	})
+}
+
+func (j *Journal) watchRelevant(path string) bool {
+	rel, err := filepath.Rel(j.path, path)
+	if err != nil {
+		return false
+	}
+	for _, p := range strings.Split(rel, string(filepath.Separator)) {
+		if p == ".git" || p == templateDir || p == editDir {
+			return false
+		}
+	}
+	return j.isNoteFile(rel) && !j.isIndex(rel) && !j.ignored(rel)
+}
+
+func (j *Journal) rebuild() error {
+	if err := j.lockFresh(); err != nil {
+		return err
+	}
+	defer j.Unlock()
+	if err := j.ProcessChanges(); err != nil {
+		return err
+	}
+	return j.Write()
+}
+
+func (j *Journal) Watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error create watcher: %w", err)
+	}
+	defer w.Close()
+	if err := j.addWatchDirs(w, j.path); err != nil {
+		return err
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	debounce := j.watchDebounce()
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Op&fsnotify.Create != 0 {
+				if st, err := os.Stat(ev.Name); err == nil && st.IsDir() && filepath.Base(ev.Name) != ".git" {
+					if err := j.addWatchDirs(w, ev.Name); err != nil {
+						j.errorf("%v", err)
+					}
+				}
+			}
+			if j.watchRelevant(ev.Name) {
+				timer.Reset(debounce)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			j.errorf("error watch: %v", err)
+		case <-timer.C:
+			if err := j.rebuild(); err != nil {
+				j.errorf("%v", err)
+			}
+		case <-sig:
+			return j.rebuild()
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package diary
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// wordCount counts the words of a note, leaving out front matter, headers,
+// list markers, checkboxes and tag markers.
+func (n *Note) wordCount() (int, error) {
+	data, err := n.read()
+	if err != nil {
+		return 0, err
+	}
+	_, front := n.frontMatter(data)
+	count := 0
+	for i, line := range strings.Split(string(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))), "\n") {
+		if i < front || headerPattern.MatchString(line) {
+			continue
+		}
+		line = checkboxPattern.ReplaceAllString(line, "")
+		for _, w := range strings.Fields(listItemPattern.ReplaceAllString(line, "")) {
+			if _, _, _, ok := n.journal.matchTag(w); !ok {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// fileWords counts the words of every diary note, archived ones included.
+func (j *Journal) fileWords() (map[string]int, error) {
+	counts := make(map[string]int)
+	err := j.walkTree(true, func(n *Note) error {
+		if n.Type != Diary {
+			return nil
+		}
+		c, err := n.wordCount()
+		if err != nil {
+			return err
+		}
+		counts[n.Path] = c
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// WordStats returns the diary word count per month, keyed YYYY-MM.
+func (j *Journal) WordStats() (map[string]int, error) {
+	files, err := j.fileWords()
+	if err != nil {
+		return nil, err
+	}
+	months := make(map[string]int)
+	for fn, c := range files {
+		n, err := j.NewNote(fn)
+		if err != nil {
+			return nil, err
+		}
+		months[n.Time.Format("2006-01")] += c
+	}
+	return months, nil
+}
+
+func (j *Journal) PrintWordStats(perFile bool) error {
+	files, err := j.fileWords()
+	if err != nil {
+		return err
+	}
+	if perFile {
+		var paths []string
+		for fn := range files {
+			paths = append(paths, fn)
+		}
+		sort.Slice(paths, func(a, b int) bool {
+			if files[paths[a]] != files[paths[b]] {
+				return files[paths[a]] > files[paths[b]]
+			}
+			return paths[a] < paths[b]
+		})
+		if j.json {
+			result := []FileWordResult{}
+			for _, fn := range paths {
+				result = append(result, FileWordResult{Path: fn, Words: files[fn]})
+			}
+			return writeResult(result)
+		}
+		t := j.newTable("WORDS", "NOTE")
+		t.alignRight(0)
+		for _, fn := range paths {
+			t.add(strconv.Itoa(files[fn]), fn)
+		}
+		return t.write(os.Stdout)
+	}
+	months, err := j.WordStats()
+	if err != nil {
+		return err
+	}
+	var keys []string
+	total, largest := 0, 0
+	for k, c := range months {
+		keys = append(keys, k)
+		total += c
+		if c > largest {
+			largest = c
+		}
+	}
+	sort.Strings(keys)
+	if j.json {
+		result := []WordResult{}
+		for _, k := range keys {
+			result = append(result, WordResult{Month: k, Words: months[k]})
+		}
+		return writeResult(result)
+	}
+	t := j.newTable("MONTH", "WORDS", "")
+	t.alignRight(1)
+	for _, k := range keys {
+		bar := 0
+		if largest > 0 {
+			bar = months[k] * 40 / largest
+		}
+		t.add(k, strconv.Itoa(months[k]), strings.Repeat("#", bar))
+	}
+	if err := t.write(os.Stdout); err != nil {
+		return err
+	}
+	perDay := 0
+	if len(files) > 0 {
+		perDay = total / len(files)
+	}
+	fmt.Printf("total %d words in %d days, %d words per day\n", total, len(files), perDay)
+	return nil
+}
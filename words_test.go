@@ -0,0 +1,46 @@
+package diary
+
+import "testing"
+
+var wordFixtures = map[string]string{
+	// 3 + 4 words
+	"2024/03/2024-03-01.md": "# Note 2024-03-01\n## 09:00:00\n- *TODO* call bob today\n1. wrote the release notes\n",
+	// 5 words, the front matter left out
+	"2024/03/2024-03-02.md": "---\ntitle: a long title here\n---\n# Note\r\n- [x] *DONE* shipped it *#later* twice\r\nok\r\n",
+	// 2 words
+	"archive/2024/01/2024-01-05.md": "# Note\nold entry\n",
+	"notes/plan.md":                 "# Plan\nnot a diary note at all\n",
+}
+
+func TestWordStats(t *testing.T) {
+	j, _, _ := newTestJournal(t, wordFixtures)
+	months, err := j.WordStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 2 || months["2024-03"] != 12 || months["2024-01"] != 2 {
+		t.Errorf("months = %v", months)
+	}
+}
+
+func TestPrintWordStats(t *testing.T) {
+	j, _, _ := newTestJournal(t, wordFixtures)
+	out := captureStdout(t, func() error { return j.PrintWordStats(false) })
+	want := "" +
+		"MONTH    WORDS\n" +
+		"2024-01      2  ######\n" +
+		"2024-03     12  ########################################\n" +
+		"total 14 words in 3 days, 4 words per day\n"
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+	out = captureStdout(t, func() error { return j.PrintWordStats(true) })
+	want = "" +
+		"WORDS  NOTE\n" +
+		"    7  2024/03/2024-03-01.md\n" +
+		"    5  2024/03/2024-03-02.md\n" +
+		"    2  archive/2024/01/2024-01-05.md\n"
+	if out != want {
+		t.Errorf("per file:\n%s\nwant:\n%s", out, want)
+	}
+}